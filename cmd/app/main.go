@@ -3,21 +3,40 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
 	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
+	"subscription-service/internal/budget"
+	"subscription-service/internal/cache"
 	"subscription-service/internal/config"
+	"subscription-service/internal/health"
+	"subscription-service/internal/logging"
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/migrations"
+	"subscription-service/internal/notify"
+	"subscription-service/internal/outbox"
+	"subscription-service/internal/pricing"
+	"subscription-service/internal/ratelimit"
+	"subscription-service/internal/reminder"
 	"subscription-service/internal/repository"
 	"subscription-service/internal/service"
+	"subscription-service/internal/tracing"
+	"subscription-service/internal/webhook"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -25,17 +44,40 @@ import (
 func main() {
 	_ = godotenv.Load()
 
-	cfg := config.Load()
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		os.Exit(printConfig(args[2:]))
+	}
+
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
 
-	level, err := zerolog.ParseLevel(cfg.LogLevel)
+	level, err := zerolog.ParseLevel(cfg.App.LogLevel)
 	if err != nil {
 		level = zerolog.InfoLevel
 	}
 	zerolog.SetGlobalLevel(level)
+	log.Logger = log.Hook(tracing.LogHook{})
+	zerolog.DefaultContextLogger = &log.Logger
 	log.Info().Msg("Starting subscriptions service")
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "subscription-service",
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not set up tracing")
+	}
+
+	dsn := cfg.DB.DSN()
 
 	var db *sql.DB
 	for i := 0; i < 10; i++ {
@@ -46,58 +88,388 @@ func main() {
 		if err == nil {
 			break
 		}
+		if db != nil {
+			_ = db.Close()
+		}
 		wait := time.Duration(2*i+1) * time.Second
-		log.Warn().Err(err).Msgf("DB connect failed, retrying in %s", wait)
+		log.Warn().Err(err).Dur("retry_in", wait).Msg("DB connect failed, retrying")
 		time.Sleep(wait)
 	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not connect to DB")
 	}
 	defer db.Close()
+	configurePool(db, cfg.DB)
+
+	repoOpts := []repository.Option{repository.WithQueryTimeout(cfg.DB.QueryTimeout)}
+	if replicaDSN := cfg.DB.ReplicaDSN(); replicaDSN != "" {
+		// Not pinged: an unavailable replica only costs a fallback to the primary.
+		replica, err := sql.Open("postgres", replicaDSN)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not open read replica")
+		}
+		defer replica.Close()
+		configurePool(replica, cfg.DB)
+		metrics.RegisterReplicaDB(replica)
+		repoOpts = append(repoOpts, repository.WithReplica(replica))
+		log.Info().Msg("Routing reads to replica")
+	}
+
+	jwtCfg := auth.JWTConfig{
+		HS256Secret: []byte(cfg.Auth.JWTSecret),
+		Issuer:      cfg.Auth.JWTIssuer,
+		Audience:    cfg.Auth.JWTAudience,
+	}
+	if cfg.Auth.JWTJWKSFile != "" {
+		jwtCfg.RS256Keys, err = auth.LoadJWKS(cfg.Auth.JWTJWKSFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not load JWKS")
+		}
+	}
+	verifier, err := auth.NewJWTVerifier(jwtCfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not configure JWT authentication")
+	}
+
+	schemaVersion, err := migrations.Latest()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not determine expected schema version")
+	}
+	checker := health.NewChecker(cfg.Health.CheckTimeout)
+	checker.Add("database", health.PingDB(db))
+	checker.Add("migrations", health.MigrationVersion(db, schemaVersion))
+
+	metrics.RegisterDB(db, repository.NewPGStatsRepo(db))
 
-	repo := repository.NewPGRepo(db)
-	svc := service.NewSubscriptionService(repo)
+	repo := metrics.InstrumentRepo(repository.NewPGRepo(db, repoOpts...))
+	scheduleRepo := repository.NewPGPriceScheduleRepo(db)
+	if cfg.Cache.Backend == "memory" {
+		store := cache.NewLRU(cfg.Cache.Size)
+		repo = cache.NewRepo(repo, store, cfg.Cache.TTL)
+		scheduleRepo = cache.NewPriceScheduleRepo(scheduleRepo, store)
+	}
+	svc := service.NewTracedService(service.NewSubscriptionService(repo,
+		service.WithDuplicatePolicy(service.DuplicatePolicy(cfg.Subscriptions.DuplicatePolicy))))
 	handler := api.NewHandler(svc)
 
+	keySvc := service.NewAPIKeyService(repository.NewPGAPIKeyRepo(db))
+	keyHandler := api.NewAPIKeyHandler(keySvc)
+	authenticate := api.Authenticate(verifier, keySvc)
+
+	webhookRepo := repository.NewPGWebhookRepo(db)
+	webhookHandler := api.NewWebhookHandler(service.NewWebhookService(webhookRepo, service.WithPrivateWebhookURLs(cfg.Webhooks.AllowPrivate)))
+
+	reportHandler := api.NewReportHandler(service.NewReportService(repository.NewPGReportRepo(db, repoOpts...)))
+
+	catalogHandler := api.NewCatalogHandler(service.NewCatalogService(repository.NewPGCatalogRepo(db, repoOpts...)))
+	budgetRepo := repository.NewPGBudgetRepo(db)
+	budgetHandler := api.NewBudgetHandler(service.NewBudgetService(budgetRepo))
+
+	canRead := api.RequireScope(auth.ScopeSubscriptionsRead)
+	canWrite := api.RequireScope(auth.ScopeSubscriptionsWrite)
+	canReport := api.RequireScope(auth.ScopeReportsRead)
+	canManageWebhooks := api.RequireScope(auth.ScopeWebhooksManage)
+	canManageBudgets := api.RequireScope(auth.ScopeBudgetsManage)
+	canManageCatalog := api.RequireScope(auth.ScopeCatalogManage)
+
+	readLimit := api.RateLimit(ratelimit.New(ratelimit.Limit{Rate: cfg.RateLimit.ReadRPS, Burst: cfg.RateLimit.ReadBurst}))
+	writeLimit := api.RateLimit(ratelimit.New(ratelimit.Limit{Rate: cfg.RateLimit.WriteRPS, Burst: cfg.RateLimit.WriteBurst}))
+	totalLimit := api.RateLimit(ratelimit.New(ratelimit.Limit{Rate: cfg.RateLimit.TotalRPS, Burst: cfg.RateLimit.TotalBurst}))
+	ipLimit := api.RateLimitByIP(ratelimit.New(ratelimit.Limit{Rate: cfg.RateLimit.IPRPS, Burst: cfg.RateLimit.IPBurst}))
+
 	r := chi.NewRouter()
+	r.Use(tracing.Middleware, logging.Middleware(log.Logger), metrics.Middleware)
+
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 
+	r.Get("/livez", checker.Live)
+	r.Get("/readyz", checker.Ready)
+
 	r.Get("/docs/openapi.yaml", handler.OpenAPIDoc)
 
 	r.Route("/subscriptions", func(r chi.Router) {
-		r.Post("/", handler.CreateSubscription)
-		r.Get("/", handler.ListSubscriptions)
-		r.Get("/total", handler.GetTotalCost)
-		r.Get("/{id}", handler.GetSubscriptionByID)
-		r.Put("/{id}", handler.UpdateSubscription)
-		r.Delete("/{id}", handler.DeleteSubscription)
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, api.ReadYourWrites, authenticate, api.RequireTenant)
+		r.With(writeLimit, canWrite).Post("/", handler.CreateSubscription)
+		r.With(readLimit, canRead).Get("/", handler.ListSubscriptions)
+		r.With(totalLimit, canReport).Get("/total", handler.GetTotalCost)
+		r.With(readLimit, canRead).Get("/tags", handler.ListTags)
+		r.With(writeLimit, canWrite).Post("/tags", handler.TagSubscriptions)
+		r.With(writeLimit, canWrite).Delete("/tags/{tag}", handler.DeleteTag)
+		r.With(readLimit, canRead).Get("/{id}", handler.GetSubscriptionByID)
+		r.With(writeLimit, canWrite).Put("/{id}", handler.UpdateSubscription)
+		r.With(writeLimit, canWrite).Delete("/{id}", handler.DeleteSubscription)
+		r.With(readLimit, canRead).Get("/{id}/members", handler.ListMembers)
+		r.With(writeLimit, canWrite).Put("/{id}/members", handler.SetMembers)
+		r.With(readLimit, canRead).Get("/{id}/price-schedule", handler.ListPriceSchedule)
+		r.With(writeLimit, canWrite).Put("/{id}/price-schedule", handler.SetPriceSchedule)
+		r.With(writeLimit, canWrite).Put("/{id}/tags", handler.SetTags)
+	})
+
+	r.Route("/reports", func(r chi.Router) {
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, api.ReadYourWrites, authenticate, api.RequireTenant, canReport)
+		r.With(totalLimit).Get("/forecast", reportHandler.GetForecast)
+		r.With(totalLimit).Get("/duplicates", reportHandler.GetDuplicates)
+		r.With(totalLimit).Get("/price-anomalies", reportHandler.GetPriceAnomalies)
+		r.With(totalLimit).Get("/top", reportHandler.GetTop)
+		r.With(totalLimit).Get("/churn", reportHandler.GetChurn)
+		r.With(totalLimit).Get("/allocation", reportHandler.GetAllocation)
+	})
+
+	r.Route("/users", func(r chi.Router) {
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, api.ReadYourWrites, authenticate, api.RequireTenant)
+		r.With(readLimit, canRead).Get("/{user_id}/summary", reportHandler.GetUserSummary)
+	})
+
+	r.Route("/catalog", func(r chi.Router) {
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, authenticate, api.RequireTenant)
+		r.With(readLimit, canRead).Get("/", catalogHandler.List)
+		r.With(writeLimit, canManageCatalog).Put("/{service_name}", catalogHandler.SetPrice)
+		r.With(writeLimit, canManageCatalog).Delete("/{service_name}", catalogHandler.Delete)
+	})
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, authenticate, api.RequireTenant, canManageWebhooks)
+		r.With(writeLimit).Post("/", webhookHandler.CreateWebhook)
+		r.With(readLimit).Get("/{id}/deliveries", webhookHandler.ListDeliveries)
+	})
+
+	r.Route("/budgets", func(r chi.Router) {
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, authenticate, api.RequireTenant, canManageBudgets)
+		r.With(writeLimit).Post("/", budgetHandler.CreateBudget)
+		r.With(readLimit).Get("/{id}/status", budgetHandler.GetStatus)
 	})
 
+	r.Route("/admin/api-keys", func(r chi.Router) {
+		r.Use(api.RequestTimeout(cfg.App.RequestTimeout), ipLimit, authenticate, api.RequireTenant, api.RequireAdmin)
+		r.With(writeLimit).Post("/", keyHandler.CreateAPIKey)
+		r.With(readLimit).Get("/", keyHandler.ListAPIKeys)
+		r.With(writeLimit).Delete("/{id}", keyHandler.RevokeAPIKey)
+	})
+
+	workersCtx, stopWorkers := context.WithCancel(log.Logger.WithContext(context.Background()))
+	var workers sync.WaitGroup
+	dispatcher := webhook.NewDispatcher(webhookRepo, webhook.Config{
+		BatchSize:    cfg.Webhooks.BatchSize,
+		PollInterval: cfg.Webhooks.PollInterval,
+		Timeout:      cfg.Webhooks.Timeout,
+		RetryBase:    cfg.Webhooks.RetryBase,
+		RetryMax:     cfg.Webhooks.RetryMax,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		DisableAfter: cfg.Webhooks.DisableAfter,
+		Lease:        cfg.Webhooks.Lease,
+		AllowPrivate: cfg.Webhooks.AllowPrivate,
+	})
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(workersCtx)
+	}()
+
+	notifier, err := newNotifier(cfg.Notify)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not set up notifications")
+	}
+
+	if cfg.Reminders.Enabled {
+		leadDays, _ := cfg.Reminders.LeadDayList()
+		scheduler := reminder.NewScheduler(repository.NewPGReminderRepo(db), notifier, reminder.Config{
+			LeadDays:  leadDays,
+			Interval:  cfg.Reminders.Interval,
+			BatchSize: cfg.Reminders.BatchSize,
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			scheduler.Run(workersCtx)
+		}()
+	}
+
+	if cfg.Budgets.AlertsEnabled {
+		thresholds, _ := cfg.Budgets.ThresholdList()
+		evaluator := budget.NewEvaluator(budgetRepo, notifier, budget.Config{
+			Thresholds: thresholds,
+			Interval:   cfg.Budgets.Interval,
+			BatchSize:  cfg.Budgets.BatchSize,
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			evaluator.Run(workersCtx)
+		}()
+	}
+
+	if cfg.PriceSchedule.Enabled {
+		applier := pricing.NewApplier(scheduleRepo, pricing.Config{
+			Interval:  cfg.PriceSchedule.Interval,
+			BatchSize: cfg.PriceSchedule.BatchSize,
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			applier.Run(workersCtx)
+		}()
+	}
+
+	sinks := cfg.Outbox.SinkList()
+	if !slices.Contains(sinks, "webhooks") {
+		log.Warn().Msg("OUTBOX_SINKS does not include webhooks, registered webhooks will not be delivered")
+	}
+	if len(sinks) > 0 {
+		sink, closeSink, err := newEventSink(cfg.Outbox, webhookRepo)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not set up event sinks")
+		}
+		defer closeSink()
+		relay := outbox.NewRelay(repository.NewPGOutboxRepo(db), sink, outbox.Config{
+			BatchSize:    cfg.Outbox.BatchSize,
+			PollInterval: cfg.Outbox.PollInterval,
+			RetryBase:    time.Second,
+			RetryMax:     cfg.Outbox.RetryMax,
+			Lease:        cfg.Outbox.Lease,
+			Retention:    cfg.Outbox.Retention,
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			relay.Run(workersCtx)
+		}()
+		log.Info().Strs("sinks", sinks).Msg("Outbox relay started")
+	}
+
 	srv := &http.Server{
-		Addr:    ":" + cfg.AppPort,
+		Addr:    fmt.Sprintf(":%d", cfg.App.Port),
 		Handler: r,
 	}
 
+	// /metrics is served on its own listener so that it can stay on the
+	// internal network; the public router never exposes it.
+	var metricsSrv *http.Server
+	if cfg.App.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsSrv = &http.Server{Addr: cfg.App.MetricsAddr, Handler: mux}
+		go func() {
+			log.Info().Str("addr", metricsSrv.Addr).Msg("Metrics server listening")
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Metrics server failed")
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		log.Info().Msgf("HTTP server listening on %s", srv.Addr)
+		log.Info().Str("addr", srv.Addr).Msg("HTTP server listening")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("HTTP server failed")
 		}
 	}()
 
 	<-stop
-	log.Info().Msg("Shutting down server")
+	checker.SetDraining()
+	log.Info().Dur("drain_delay", cfg.Health.DrainDelay).Msg("Shutting down server, readiness is failing")
+	time.Sleep(cfg.Health.DrainDelay)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Server Shutdown Failed")
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("Metrics server shutdown failed")
+		}
+	}
+	stopWorkers()
+	workers.Wait()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Flushing traces failed")
+	}
 	log.Info().Msg("Server exited properly")
 }
+
+func newEventSink(cfg config.OutboxConfig, webhooks repository.WebhookRepo) (outbox.Sink, func(), error) {
+	var sinks []outbox.Sink
+	var closers []func() error
+	closeAll := func() {
+		for _, c := range closers {
+			_ = c()
+		}
+	}
+	for _, name := range cfg.SinkList() {
+		switch name {
+		case "webhooks":
+			sinks = append(sinks, webhook.NewSink(webhooks))
+		case "stdout":
+			sinks = append(sinks, outbox.NewWriterSink(os.Stdout))
+		case "file":
+			f, err := outbox.OpenFileSink(cfg.File)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			sinks = append(sinks, f)
+			closers = append(closers, f.Close)
+		case "http":
+			sinks = append(sinks, outbox.NewHTTPSink(cfg.HTTPURL, cfg.SinkTimeout))
+		case "nats":
+			n, err := outbox.NewNATSSink(cfg.NATSURL, cfg.NATSSubject, cfg.SinkTimeout)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			sinks = append(sinks, n)
+			closers = append(closers, n.Close)
+		case "kafka":
+			k, err := outbox.NewKafkaSink(cfg.KafkaBrokerList(), cfg.KafkaTopic, cfg.SinkTimeout)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			sinks = append(sinks, k)
+			closers = append(closers, k.Close)
+		}
+	}
+	return outbox.Multi(sinks...), closeAll, nil
+}
+
+func newNotifier(cfg config.NotifyConfig) (notify.Notifier, error) {
+	if cfg.Channel == "smtp" {
+		return notify.NewSMTPNotifier(notify.SMTPConfig{
+			Addr:      cfg.SMTPAddr,
+			Username:  cfg.SMTPUsername,
+			Password:  cfg.SMTPPassword,
+			From:      cfg.SMTPFrom,
+			Recipient: cfg.EmailRecipient,
+			Timeout:   cfg.SMTPTimeout,
+		})
+	}
+	return notify.LogNotifier{}, nil
+}
+
+func configurePool(db *sql.DB, cfg config.DBConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+func printConfig(args []string) int {
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if perr := cfg.Print(os.Stdout); perr != nil {
+		fmt.Fprintln(os.Stderr, perr)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type APIKeyHandler struct {
+	svc service.APIKeyService
+}
+
+func NewAPIKeyHandler(svc service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{svc: svc}
+}
+
+type createAPIKeyReq struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type createAPIKeyResp struct {
+	*model.APIKey
+	Key string `json:"key"`
+}
+
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var in createAPIKeyReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	k, raw, err := h.svc.CreateKey(r.Context(), service.CreateAPIKeyInput{Name: in.Name, Scopes: in.Scopes})
+	if err != nil {
+		if err == service.ErrInvalid {
+			respondErr(w, http.StatusBadRequest, "name required, scopes must be a non-empty list of known scopes")
+			return
+		}
+		respondErr(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().
+		Str("api_key_id", k.ID).
+		Str("name", k.Name).
+		Strs("scopes", k.Scopes).
+		Msg("api key created")
+	writeJSON(w, http.StatusCreated, createAPIKeyResp{APIKey: k, Key: raw})
+}
+
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.svc.ListKeys(r.Context())
+	if err != nil {
+		respondErr(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if keys == nil {
+		keys = []*model.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+	if err := h.svc.RevokeKey(r.Context(), id); err != nil {
+		if err == repository.ErrNotFound {
+			respondErr(w, http.StatusNotFound, "not found")
+			return
+		}
+		respondErr(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().Str("api_key_id", id).Msg("api key revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
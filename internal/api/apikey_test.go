@@ -0,0 +1,130 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeyService) CreateKey(ctx context.Context, in service.CreateAPIKeyInput) (*model.APIKey, string, error) {
+	args := m.Called(ctx, in)
+	if k, ok := args.Get(0).(*model.APIKey); ok {
+		return k, args.String(1), args.Error(2)
+	}
+	return nil, "", args.Error(2)
+}
+func (m *mockAPIKeyService) ListKeys(ctx context.Context) ([]*model.APIKey, error) {
+	args := m.Called(ctx)
+	if keys, ok := args.Get(0).([]*model.APIKey); ok {
+		return keys, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockAPIKeyService) RevokeKey(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+func (m *mockAPIKeyService) Authenticate(ctx context.Context, key string) (*auth.Principal, error) {
+	args := m.Called(ctx, key)
+	if p, ok := args.Get(0).(*auth.Principal); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func newAdminRouter(t *testing.T, keys *mockAPIKeyService) http.Handler {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: testSecret})
+	assert.NoError(t, err)
+	h := api.NewAPIKeyHandler(keys)
+
+	r := chi.NewRouter()
+	r.Route("/admin/api-keys", func(r chi.Router) {
+		r.Use(api.Authenticate(v, keys), api.RequireTenant, api.RequireAdmin)
+		r.Post("/", h.CreateAPIKey)
+		r.Get("/", h.ListAPIKeys)
+		r.Delete("/{id}", h.RevokeAPIKey)
+	})
+	r.Route("/reports", func(r chi.Router) {
+		r.Use(api.Authenticate(v, keys), api.RequireTenant, api.RequireScope(auth.ScopeReportsRead))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	})
+	return r
+}
+
+func TestCreateAPIKey_AdminOnly(t *testing.T) {
+	keys := new(mockAPIKeyService)
+	router := newAdminRouter(t, keys)
+	orgID := uuid.New().String()
+
+	created := &model.APIKey{ID: uuid.New().String(), Name: "batch", Prefix: "sk_12345678", Scopes: []string{auth.ScopeReportsRead}}
+	keys.On("CreateKey", mock.Anything, service.CreateAPIKeyInput{Name: "batch", Scopes: []string{auth.ScopeReportsRead}}).
+		Return(created, "sk_12345678secret", nil)
+
+	body, _ := json.Marshal(map[string]any{"name": "batch", "scopes": []string{auth.ScopeReportsRead}})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewReader(body))
+	req.Header.Set("Authorization", bearer(t, jwt.MapClaims{"sub": "u1", "org_id": orgID, "exp": time.Now().Add(time.Hour).Unix()}))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewReader(body))
+	req.Header.Set("Authorization", bearer(t, jwt.MapClaims{"sub": "u1", "org_id": orgID, "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var got map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	assert.Equal(t, "sk_12345678secret", got["key"])
+	assert.Equal(t, created.ID, got["id"])
+	keys.AssertExpectations(t)
+}
+
+func TestAuthenticate_APIKeyScopes(t *testing.T) {
+	keys := new(mockAPIKeyService)
+	router := newAdminRouter(t, keys)
+	orgID := uuid.New().String()
+
+	keys.On("Authenticate", mock.Anything, "sk_reports").
+		Return(&auth.Principal{Subject: "apikey:1", APIKeyID: "1", OrgID: orgID, Scopes: []string{auth.ScopeReportsRead}}, nil)
+	keys.On("Authenticate", mock.Anything, "sk_read").
+		Return(&auth.Principal{Subject: "apikey:2", APIKeyID: "2", OrgID: orgID, Scopes: []string{auth.ScopeSubscriptionsRead}}, nil)
+	keys.On("Authenticate", mock.Anything, "sk_revoked").Return(nil, auth.ErrInvalidAPIKey)
+
+	cases := []struct {
+		key  string
+		path string
+		want int
+	}{
+		{"sk_reports", "/reports", http.StatusOK},
+		{"sk_read", "/reports", http.StatusForbidden},
+		{"sk_revoked", "/reports", http.StatusUnauthorized},
+		{"sk_reports", "/admin/api-keys", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "ApiKey "+tc.key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.want, w.Code, "%s %s", tc.key, tc.path)
+	}
+}
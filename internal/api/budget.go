@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type BudgetHandler struct {
+	svc service.BudgetService
+}
+
+func NewBudgetHandler(svc service.BudgetService) *BudgetHandler {
+	return &BudgetHandler{svc: svc}
+}
+
+type createBudgetReq struct {
+	UserID *string `json:"user_id,omitempty"`
+	Amount int64   `json:"amount"`
+}
+
+func (h *BudgetHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	var in createBudgetReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	b, err := h.svc.CreateBudget(r.Context(), service.CreateBudgetInput{UserID: in.UserID, Amount: in.Amount})
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "amount must be positive, user_id must be uuid")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		case repository.ErrConflict:
+			respondErr(w, http.StatusConflict, "budget already exists")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	log := zerolog.Ctx(r.Context()).Info().Str("budget_id", b.ID).Int64("amount", b.Amount)
+	if b.UserID != nil {
+		log = log.Str("user_id", *b.UserID)
+	}
+	log.Msg("budget created")
+	w.Header().Set("Location", "/budgets/"+b.ID)
+	writeJSON(w, http.StatusCreated, b)
+}
+
+// GetStatus reports the budget against the projected spend of ?month=MM-YYYY,
+// the current month by default.
+func (h *BudgetHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if m := r.URL.Query().Get("month"); m != "" {
+		var err error
+		if month, err = parseMonthYear(m); err != nil {
+			respondErr(w, http.StatusBadRequest, "month must be MM-YYYY")
+			return
+		}
+	}
+
+	status, err := h.svc.Status(r.Context(), id, month)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockBudgetService struct {
+	mock.Mock
+}
+
+func (m *mockBudgetService) CreateBudget(ctx context.Context, in service.CreateBudgetInput) (*model.Budget, error) {
+	args := m.Called(ctx, in)
+	if b, ok := args.Get(0).(*model.Budget); ok {
+		return b, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockBudgetService) Status(ctx context.Context, id string, month time.Time) (*model.BudgetStatus, error) {
+	args := m.Called(ctx, id, month)
+	if s, ok := args.Get(0).(*model.BudgetStatus); ok {
+		return s, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func newBudgetRouter(t *testing.T, svc *mockBudgetService) http.Handler {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: testSecret})
+	assert.NoError(t, err)
+	h := api.NewBudgetHandler(svc)
+
+	r := chi.NewRouter()
+	r.Route("/budgets", func(r chi.Router) {
+		r.Use(api.Authenticate(v, nil), api.RequireTenant, api.RequireScope(auth.ScopeBudgetsManage))
+		r.Post("/", h.CreateBudget)
+		r.Get("/{id}/status", h.GetStatus)
+	})
+	return r
+}
+
+func TestCreateBudget(t *testing.T) {
+	svc := new(mockBudgetService)
+	router := newBudgetRouter(t, svc)
+
+	created := &model.Budget{ID: uuid.New().String(), Amount: 50000}
+	svc.On("CreateBudget", mock.Anything, service.CreateBudgetInput{Amount: 50000}).Return(created, nil).Once()
+	svc.On("CreateBudget", mock.Anything, service.CreateBudgetInput{Amount: 50000}).Return(nil, repository.ErrConflict).Once()
+
+	for _, want := range []int{http.StatusCreated, http.StatusConflict} {
+		req := httptest.NewRequest(http.MethodPost, "/budgets", bytes.NewReader([]byte(`{"amount":50000}`)))
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code)
+		if want == http.StatusCreated {
+			assert.Equal(t, "/budgets/"+created.ID, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestGetBudgetStatus(t *testing.T) {
+	svc := new(mockBudgetService)
+	router := newBudgetRouter(t, svc)
+
+	id := uuid.New().String()
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.On("Status", mock.Anything, id, march).
+		Return(&model.BudgetStatus{BudgetID: id, Month: march, Amount: 1000, Used: 800, Remaining: 200, Percent: 80}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/budgets/"+id+"/status?month=03-2025", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got model.BudgetStatus
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	assert.Equal(t, int64(200), got.Remaining)
+
+	req = httptest.NewRequest(http.MethodGet, "/budgets/"+id+"/status?month=2025-03", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+type CatalogHandler struct {
+	svc service.CatalogService
+}
+
+func NewCatalogHandler(svc service.CatalogService) *CatalogHandler {
+	return &CatalogHandler{svc: svc}
+}
+
+type setCatalogPriceReq struct {
+	Price int `json:"price"`
+}
+
+// SetPrice creates or replaces the catalog price of {service_name}.
+func (h *CatalogHandler) SetPrice(w http.ResponseWriter, r *http.Request) {
+	var in setCatalogPriceReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	e, err := h.svc.SetPrice(r.Context(), chi.URLParam(r, "service_name"), in.Price)
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "service_name must not be empty, price must not be negative")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().Str("service_name", e.ServiceName).Int("price", e.Price).Msg("catalog price set")
+	writeJSON(w, http.StatusOK, e)
+}
+
+func (h *CatalogHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePage(r.URL.Query())
+	entries, err := h.svc.List(r.Context(), limit, offset)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	if entries == nil {
+		entries = []*model.CatalogEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (h *CatalogHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "service_name")
+	if err := h.svc.Delete(r.Context(), name); err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().Str("service_name", name).Msg("catalog price deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockCatalogService struct {
+	mock.Mock
+}
+
+func (m *mockCatalogService) SetPrice(ctx context.Context, serviceName string, price int) (*model.CatalogEntry, error) {
+	args := m.Called(ctx, serviceName, price)
+	if e, ok := args.Get(0).(*model.CatalogEntry); ok {
+		return e, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockCatalogService) List(ctx context.Context, limit, offset int) ([]*model.CatalogEntry, error) {
+	args := m.Called(ctx, limit, offset)
+	if e, ok := args.Get(0).([]*model.CatalogEntry); ok {
+		return e, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockCatalogService) Delete(ctx context.Context, serviceName string) error {
+	return m.Called(ctx, serviceName).Error(0)
+}
+
+func newCatalogRouter(t *testing.T, svc *mockCatalogService) http.Handler {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: testSecret})
+	assert.NoError(t, err)
+	h := api.NewCatalogHandler(svc)
+
+	r := chi.NewRouter()
+	r.Route("/catalog", func(r chi.Router) {
+		r.Use(api.Authenticate(v, nil), api.RequireTenant)
+		r.Get("/", h.List)
+		r.With(api.RequireScope(auth.ScopeCatalogManage)).Put("/{service_name}", h.SetPrice)
+		r.With(api.RequireScope(auth.ScopeCatalogManage)).Delete("/{service_name}", h.Delete)
+	})
+	return r
+}
+
+func TestSetCatalogPrice(t *testing.T) {
+	svc := new(mockCatalogService)
+	router := newCatalogRouter(t, svc)
+	svc.On("SetPrice", mock.Anything, "Yandex Plus", 299).Return(&model.CatalogEntry{ServiceName: "Yandex Plus", Price: 299}, nil)
+	svc.On("SetPrice", mock.Anything, "Netflix", -1).Return(nil, service.ErrInvalid)
+
+	body, _ := json.Marshal(map[string]any{"price": 299})
+	req := httptest.NewRequest(http.MethodPut, "/catalog/Yandex%20Plus", bytes.NewReader(body))
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/catalog/Netflix", bytes.NewReader([]byte(`{"price":-1}`)))
+	req.Header.Set("Authorization", adminToken(t))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteCatalogPrice_NotFound(t *testing.T) {
+	svc := new(mockCatalogService)
+	router := newCatalogRouter(t, svc)
+	svc.On("Delete", mock.Anything, "Netflix").Return(repository.ErrNotFound)
+
+	req := httptest.NewRequest(http.MethodDelete, "/catalog/Netflix", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
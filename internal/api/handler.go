@@ -4,16 +4,20 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"subscription-service/internal/model"
 	"subscription-service/internal/repository"
 	"subscription-service/internal/service"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
 type Handler struct {
@@ -43,6 +47,14 @@ func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, http.StatusBadRequest, "user_id must be uuid")
 		return
 	}
+	if in.CostCenter != nil && utf8.RuneCountInString(strings.TrimSpace(*in.CostCenter)) > service.MaxCostCenterLength {
+		respondErr(w, http.StatusBadRequest, "cost_center must be at most "+strconv.Itoa(service.MaxCostCenterLength)+" characters")
+		return
+	}
+	if in.BillingPeriod != 0 && !slices.Contains(model.BillingPeriods, in.BillingPeriod) {
+		respondErr(w, http.StatusBadRequest, billingPeriodMessage)
+		return
+	}
 	startDate, err := parseMonthYear(in.StartDate)
 	if err != nil {
 		respondErr(w, http.StatusBadRequest, "start_date must be MM-YYYY")
@@ -63,14 +75,24 @@ func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	}
 
 	created, err := h.svc.CreateSubscription(r.Context(), service.CreateInput{
-		ServiceName: in.ServiceName,
-		Price:       in.Price,
-		UserID:      in.UserID,
-		StartDate:   startDate,
-		EndDate:     endDatePtr,
+		ServiceName:   in.ServiceName,
+		Price:         in.Price,
+		UserID:        in.UserID,
+		StartDate:     startDate,
+		EndDate:       endDatePtr,
+		CostCenter:    in.CostCenter,
+		BillingPeriod: in.BillingPeriod,
 	})
 	if err != nil {
-		log.Error().Err(err).Msg("CreateSubscription failed")
+		if err == service.ErrForbidden {
+			respondErr(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		if err == service.ErrOverlap {
+			respondErr(w, http.StatusConflict, overlapMessage)
+			return
+		}
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("create subscription failed")
 		respondErr(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -78,9 +100,14 @@ func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Location", "/subscriptions/"+created.ID)
 
-	log.Info().
-		Msgf("A subscription to %s was added for user %s from %s to %s for %v units",
-			created.ServiceName, created.UserID, created.StartDate.Format("2006-01-02"), created.EndDate.Format("2006-01-02"), created.Price)
+	zerolog.Ctx(r.Context()).Info().
+		Str("subscription_id", created.ID).
+		Str("service_name", created.ServiceName).
+		Str("user_id", created.UserID).
+		Str("start_date", created.StartDate.Format("2006-01-02")).
+		Str("end_date", created.EndDate.Format("2006-01-02")).
+		Int("price", created.Price).
+		Msg("subscription created")
 
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(created)
@@ -98,6 +125,10 @@ func (h *Handler) GetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
 			respondErr(w, http.StatusNotFound, "not found")
 			return
 		}
+		if err == service.ErrForbidden {
+			respondErr(w, http.StatusForbidden, "forbidden")
+			return
+		}
 		respondErr(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -113,23 +144,20 @@ func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	if s := q.Get("service_name"); s != "" {
 		filter.ServiceName = &s
 	}
-	limit := 50
-	if l := q.Get("limit"); l != "" {
-		if vi, err := strconv.Atoi(l); err == nil && vi > 0 && vi <= 1000 {
-			limit = vi
-		}
+	if c := q.Get("cost_center"); c != "" {
+		filter.CostCenter = &c
 	}
-	offset := 0
-	if o := q.Get("offset"); o != "" {
-		if vi, err := strconv.Atoi(o); err == nil && vi >= 0 {
-			offset = vi
-		}
+	if t := q.Get("tag"); t != "" {
+		filter.Tag = &t
 	}
-	filter.Limit = limit
-	filter.Offset = offset
+	filter.Limit, filter.Offset = parsePage(q)
 
 	subs, err := h.svc.ListSubscriptions(r.Context(), filter)
 	if err != nil {
+		if err == service.ErrForbidden {
+			respondErr(w, http.StatusForbidden, "forbidden")
+			return
+		}
 		respondErr(w, http.StatusInternalServerError, "internal error")
 		return
 	}
@@ -156,6 +184,14 @@ func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, http.StatusBadRequest, "user_id must be uuid")
 		return
 	}
+	if in.CostCenter != nil && utf8.RuneCountInString(strings.TrimSpace(*in.CostCenter)) > service.MaxCostCenterLength {
+		respondErr(w, http.StatusBadRequest, "cost_center must be at most "+strconv.Itoa(service.MaxCostCenterLength)+" characters")
+		return
+	}
+	if in.BillingPeriod != 0 && !slices.Contains(model.BillingPeriods, in.BillingPeriod) {
+		respondErr(w, http.StatusBadRequest, billingPeriodMessage)
+		return
+	}
 	startDate, err := parseMonthYear(in.StartDate)
 	if err != nil {
 		respondErr(w, http.StatusBadRequest, "start_date must be MM-YYYY")
@@ -176,23 +212,41 @@ func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	}
 
 	updated, err := h.svc.UpdateSubscription(r.Context(), id, service.UpdateInput{
-		ServiceName: in.ServiceName,
-		Price:       in.Price,
-		UserID:      in.UserID,
-		StartDate:   startDate,
-		EndDate:     endDatePtr,
+		ServiceName:   in.ServiceName,
+		Price:         in.Price,
+		UserID:        in.UserID,
+		StartDate:     startDate,
+		EndDate:       endDatePtr,
+		CostCenter:    in.CostCenter,
+		BillingPeriod: in.BillingPeriod,
 	})
 	if err != nil {
 		if err == repository.ErrNotFound {
 			respondErr(w, http.StatusNotFound, "not found")
 			return
 		}
+		if err == service.ErrForbidden {
+			respondErr(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		if err == service.ErrOverlap {
+			respondErr(w, http.StatusConflict, overlapMessage)
+			return
+		}
+		if err == service.ErrShares {
+			respondErr(w, http.StatusBadRequest, "price does not fit the member shares: fixed amounts may not exceed it and must equal it when no member pays a share_percent")
+			return
+		}
 		respondErr(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
-	log.Info().
-		Msgf("The subscription for user %s was updated: %s for %v units", updated.UserID, updated.ServiceName, updated.Price)
+	zerolog.Ctx(r.Context()).Info().
+		Str("subscription_id", updated.ID).
+		Str("service_name", updated.ServiceName).
+		Str("user_id", updated.UserID).
+		Int("price", updated.Price).
+		Msg("subscription updated")
 	writeJSON(w, http.StatusOK, updated)
 }
 
@@ -203,28 +257,279 @@ func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sub, err := h.svc.GetByID(r.Context(), id)
+	sub, err := h.svc.DeleteSubscription(r.Context(), id)
 	if err != nil {
 		if err == repository.ErrNotFound {
 			respondErr(w, http.StatusNotFound, "not found")
 			return
 		}
+		if err == service.ErrForbidden {
+			respondErr(w, http.StatusForbidden, "forbidden")
+			return
+		}
 		respondErr(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 
-	if err := h.svc.DeleteSubscription(r.Context(), id); err != nil {
-		if err == repository.ErrNotFound {
+	zerolog.Ctx(r.Context()).Info().
+		Str("subscription_id", sub.ID).
+		Str("user_id", sub.UserID).
+		Msg("subscription deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type setMembersReq struct {
+	Members []model.Member `json:"members"`
+}
+
+// SetMembers replaces the members sharing the cost of {id}; an empty list
+// stops sharing.
+func (h *Handler) SetMembers(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+	var in setMembersReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	members, err := h.svc.SetMembers(r.Context(), id, in.Members)
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "each member needs a uuid user_id and either share_percent or amount")
+		case service.ErrShares:
+			respondErr(w, http.StatusBadRequest, "shares must add up to 100% of the price left after fixed amounts, or amounts to the price")
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().Str("subscription_id", id).Int("members", len(members)).Msg("subscription members set")
+	writeJSON(w, http.StatusOK, members)
+}
+
+func (h *Handler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+
+	members, err := h.svc.ListMembers(r.Context(), id)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
 			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	if members == nil {
+		members = []model.Member{}
+	}
+	writeJSON(w, http.StatusOK, members)
+}
+
+type scheduledPriceReq struct {
+	Price         int    `json:"price"`
+	EffectiveFrom string `json:"effective_from"`
+}
+
+type setPriceScheduleReq struct {
+	Prices []scheduledPriceReq `json:"prices"`
+}
+
+// SetPriceSchedule replaces the future prices of {id}; an empty list
+// cancels them.
+func (h *Handler) SetPriceSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+	var in setPriceScheduleReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	prices := make([]model.ScheduledPrice, 0, len(in.Prices))
+	for _, p := range in.Prices {
+		from, err := parseMonthYear(p.EffectiveFrom)
+		if err != nil {
+			respondErr(w, http.StatusBadRequest, "effective_from must be MM-YYYY")
 			return
 		}
-		respondErr(w, http.StatusInternalServerError, "internal error")
+		prices = append(prices, model.ScheduledPrice{Price: p.Price, EffectiveFrom: from})
+	}
+
+	out, err := h.svc.SetPriceSchedule(r.Context(), id, prices)
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "at most "+strconv.Itoa(service.MaxScheduledPrices)+
+				" prices >= 0, one per month, starting after the current month and not after end_date")
+		case service.ErrShares:
+			respondErr(w, http.StatusBadRequest, "a scheduled price does not fit the member shares")
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().Str("subscription_id", id).Int("prices", len(out)).Msg("price schedule set")
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *Handler) ListPriceSchedule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+
+	prices, err := h.svc.ListPriceSchedule(r.Context(), id)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	if prices == nil {
+		prices = []model.ScheduledPrice{}
+	}
+	writeJSON(w, http.StatusOK, prices)
+}
+
+type setTagsReq struct {
+	Tags []string `json:"tags"`
+}
+
+// SetTags replaces the tags of {id}; an empty list removes them all.
+func (h *Handler) SetTags(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+	var in setTagsReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	tags, err := h.svc.SetTags(r.Context(), id, in.Tags)
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, tagsMessage)
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().Str("subscription_id", id).Strs("tags", tags).Msg("subscription tags set")
+	writeJSON(w, http.StatusOK, tags)
+}
+
+type tagSubscriptionsReq struct {
+	SubscriptionIDs []string `json:"subscription_ids"`
+	Add             []string `json:"add"`
+	Remove          []string `json:"remove"`
+}
+
+// TagSubscriptions adds and removes tags on several subscriptions at once.
+func (h *Handler) TagSubscriptions(w http.ResponseWriter, r *http.Request) {
+	var in tagSubscriptionsReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
 		return
 	}
 
-	log.Info().
-		Msgf("The subscription for user %s was unsubscribed", sub.UserID)
+	err := h.svc.TagSubscriptions(r.Context(), service.TagInput{
+		SubscriptionIDs: in.SubscriptionIDs,
+		Add:             in.Add,
+		Remove:          in.Remove,
+	})
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "subscription_ids must list 1 to "+strconv.Itoa(service.MaxBulkSubscriptions)+
+				" uuids and add or remove tags, none in both; "+tagsMessage)
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "subscription not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().
+		Int("subscriptions", len(in.SubscriptionIDs)).
+		Strs("add", in.Add).
+		Strs("remove", in.Remove).
+		Msg("subscriptions tagged")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.svc.ListTags(r.Context())
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// DeleteTag removes {tag} from every subscription of the organization.
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	if err := h.svc.DeleteTag(r.Context(), tag); err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
 
+	zerolog.Ctx(r.Context()).Info().Str("tag", tag).Msg("tag deleted")
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -261,6 +566,10 @@ func (h *Handler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
 
 	total, err := h.svc.SumForPeriod(r.Context(), from, to, uidPtr, snPtr)
 	if err != nil {
+		if err == service.ErrForbidden {
+			respondErr(w, http.StatusForbidden, "forbidden")
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -269,12 +578,38 @@ func (h *Handler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]int64{"total": total})
 }
 
+const billingPeriodMessage = "billing_period must be 1, 3, 6 or 12 months"
+
+const overlapMessage = "the user already has a subscription to this service for an overlapping period"
+
+var tagsMessage = "at most " + strconv.Itoa(service.MaxTags) + " tags of 1 to " + strconv.Itoa(service.MaxTagLength) + " characters"
+
 type createReq struct {
 	ServiceName string  `json:"service_name"`
 	Price       int     `json:"price"`
 	UserID      string  `json:"user_id"`
 	StartDate   string  `json:"start_date"`
 	EndDate     *string `json:"end_date,omitempty"`
+	CostCenter  *string `json:"cost_center,omitempty"`
+	// BillingPeriod is in months, monthly when omitted.
+	BillingPeriod int `json:"billing_period,omitempty"`
+}
+
+// parsePage reads limit (default 50, at most 1000) and offset, ignoring
+// invalid values.
+func parsePage(q url.Values) (limit, offset int) {
+	limit = 50
+	if l := q.Get("limit"); l != "" {
+		if vi, err := strconv.Atoi(l); err == nil && vi > 0 && vi <= 1000 {
+			limit = vi
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if vi, err := strconv.Atoi(o); err == nil && vi >= 0 {
+			offset = vi
+		}
+	}
+	return limit, offset
 }
 
 func parseMonthYear(s string) (time.Time, error) {
@@ -10,14 +10,19 @@ import (
 	"time"
 
 	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
 	"subscription-service/internal/model"
+	"subscription-service/internal/ratelimit"
 	"subscription-service/internal/repository"
 	"subscription-service/internal/service"
+	"subscription-service/internal/tenant"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockService struct {
@@ -45,9 +50,12 @@ func (m *mockService) UpdateSubscription(ctx context.Context, id string, in serv
 	}
 	return nil, args.Error(1)
 }
-func (m *mockService) DeleteSubscription(ctx context.Context, id string) error {
+func (m *mockService) DeleteSubscription(ctx context.Context, id string) (*model.Subscription, error) {
 	args := m.Called(ctx, id)
-	return args.Error(0)
+	if sub, ok := args.Get(0).(*model.Subscription); ok {
+		return sub, args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 func (m *mockService) ListSubscriptions(ctx context.Context, filter repository.ListFilter) ([]*model.Subscription, error) {
 	args := m.Called(ctx, filter)
@@ -61,6 +69,58 @@ func (m *mockService) SumForPeriod(ctx context.Context, from, to time.Time, user
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *mockService) SetMembers(ctx context.Context, id string, members []model.Member) ([]model.Member, error) {
+	args := m.Called(ctx, id, members)
+	if out, ok := args.Get(0).([]model.Member); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockService) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	args := m.Called(ctx, id)
+	if out, ok := args.Get(0).([]model.Member); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockService) SetTags(ctx context.Context, id string, tags []string) ([]string, error) {
+	args := m.Called(ctx, id, tags)
+	if out, ok := args.Get(0).([]string); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockService) TagSubscriptions(ctx context.Context, in service.TagInput) error {
+	return m.Called(ctx, in).Error(0)
+}
+func (m *mockService) ListTags(ctx context.Context) ([]model.Tag, error) {
+	args := m.Called(ctx)
+	if out, ok := args.Get(0).([]model.Tag); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockService) DeleteTag(ctx context.Context, name string) error {
+	return m.Called(ctx, name).Error(0)
+}
+
+func (m *mockService) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) ([]model.ScheduledPrice, error) {
+	args := m.Called(ctx, id, prices)
+	if out, ok := args.Get(0).([]model.ScheduledPrice); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockService) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	args := m.Called(ctx, id)
+	if out, ok := args.Get(0).([]model.ScheduledPrice); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func TestCreateSubscription_Success(t *testing.T) {
 	svc := new(mockService)
 	h := api.NewHandler(svc)
@@ -99,6 +159,163 @@ func TestCreateSubscription_Success(t *testing.T) {
 	svc.AssertExpectations(t)
 }
 
+func TestCreateSubscription_Overlap(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+	svc.On("CreateSubscription", mock.Anything, mock.AnythingOfType("service.CreateInput")).Return(nil, service.ErrOverlap)
+
+	b, _ := json.Marshal(map[string]any{
+		"service_name": "Netflix",
+		"price":        499,
+		"user_id":      uuid.New().String(),
+		"start_date":   "10-2025",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(b))
+	w := httptest.NewRecorder()
+	h.CreateSubscription(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestSetMembers(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	id := uuid.New().String()
+	half := 50.0
+	members := []model.Member{{UserID: uuid.New().String(), SharePercent: &half}, {UserID: uuid.New().String(), SharePercent: &half}}
+	svc.On("SetMembers", mock.Anything, id, members).Return(members, nil).Once()
+	svc.On("SetMembers", mock.Anything, id, members[:1]).Return(nil, service.ErrShares).Once()
+
+	b, _ := json.Marshal(map[string]any{"members": members})
+	req := muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/members", bytes.NewReader(b)), "id", id)
+	w := httptest.NewRecorder()
+	h.SetMembers(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	b, _ = json.Marshal(map[string]any{"members": members[:1]})
+	req = muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/members", bytes.NewReader(b)), "id", id)
+	w = httptest.NewRecorder()
+	h.SetMembers(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A plain user moving the whole cost onto somebody else.
+	fixed := 1000
+	offload := []model.Member{{UserID: uuid.New().String(), Amount: &fixed}}
+	svc.On("SetMembers", mock.Anything, id, offload).Return(nil, service.ErrForbidden).Once()
+	b, _ = json.Marshal(map[string]any{"members": offload})
+	req = muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/members", bytes.NewReader(b)), "id", id)
+	w = httptest.NewRecorder()
+	h.SetMembers(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestSetPriceSchedule(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	id := uuid.New().String()
+	prices := []model.ScheduledPrice{{Price: 599, EffectiveFrom: time.Date(2030, 7, 1, 0, 0, 0, 0, time.UTC)}}
+	svc.On("SetPriceSchedule", mock.Anything, id, prices).Return(prices, nil).Once()
+	svc.On("SetPriceSchedule", mock.Anything, id, []model.ScheduledPrice{}).Return(nil, repository.ErrNotFound).Once()
+
+	b, _ := json.Marshal(map[string]any{"prices": []map[string]any{{"price": 599, "effective_from": "07-2030"}}})
+	req := muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/price-schedule", bytes.NewReader(b)), "id", id)
+	w := httptest.NewRecorder()
+	h.SetPriceSchedule(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	b, _ = json.Marshal(map[string]any{"prices": []map[string]any{{"price": 599, "effective_from": "2030-07"}}})
+	req = muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/price-schedule", bytes.NewReader(b)), "id", id)
+	w = httptest.NewRecorder()
+	h.SetPriceSchedule(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	b, _ = json.Marshal(map[string]any{"prices": []any{}})
+	req = muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/price-schedule", bytes.NewReader(b)), "id", id)
+	w = httptest.NewRecorder()
+	h.SetPriceSchedule(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestCreateSubscription_InvalidBillingPeriod(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	b, _ := json.Marshal(map[string]any{
+		"service_name":   "Netflix",
+		"price":          499,
+		"user_id":        uuid.New().String(),
+		"start_date":     "10-2025",
+		"billing_period": 2,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(b))
+	w := httptest.NewRecorder()
+	h.CreateSubscription(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	svc.AssertNotCalled(t, "CreateSubscription", mock.Anything, mock.Anything)
+}
+
+func TestSetTags(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	id := uuid.New().String()
+	svc.On("SetTags", mock.Anything, id, []string{"Marketing", "q3"}).Return([]string{"marketing", "q3"}, nil).Once()
+	svc.On("SetTags", mock.Anything, id, []string{""}).Return(nil, service.ErrInvalid).Once()
+
+	b, _ := json.Marshal(map[string]any{"tags": []string{"Marketing", "q3"}})
+	req := muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/tags", bytes.NewReader(b)), "id", id)
+	w := httptest.NewRecorder()
+	h.SetTags(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got []string
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	assert.Equal(t, []string{"marketing", "q3"}, got)
+
+	b, _ = json.Marshal(map[string]any{"tags": []string{""}})
+	req = muxWithParam(httptest.NewRequest(http.MethodPut, "/subscriptions/"+id+"/tags", bytes.NewReader(b)), "id", id)
+	w = httptest.NewRecorder()
+	h.SetTags(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestTagSubscriptions(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	ids := []string{uuid.New().String(), uuid.New().String()}
+	in := service.TagInput{SubscriptionIDs: ids, Add: []string{"marketing"}}
+	svc.On("TagSubscriptions", mock.Anything, in).Return(nil).Once()
+	svc.On("TagSubscriptions", mock.Anything, in).Return(repository.ErrNotFound).Once()
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		b, _ := json.Marshal(map[string]any{"subscription_ids": ids, "add": []string{"marketing"}})
+		w := httptest.NewRecorder()
+		h.TagSubscriptions(w, httptest.NewRequest(http.MethodPost, "/subscriptions/tags", bytes.NewReader(b)))
+		assert.Equal(t, want, w.Code)
+	}
+	svc.AssertExpectations(t)
+}
+
+func TestListSubscriptions_FiltersByTagAndCostCenter(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	tag, center := "marketing", "growth"
+	svc.On("ListSubscriptions", mock.Anything, repository.ListFilter{Tag: &tag, CostCenter: &center, Limit: 50}).
+		Return([]*model.Subscription{}, nil)
+
+	w := httptest.NewRecorder()
+	h.ListSubscriptions(w, httptest.NewRequest(http.MethodGet, "/subscriptions?tag=marketing&cost_center=growth", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	svc.AssertExpectations(t)
+}
+
 func TestCreateSubscription_InvalidJSON(t *testing.T) {
 	svc := new(mockService)
 	h := api.NewHandler(svc)
@@ -137,8 +354,7 @@ func TestDeleteSubscription_Success(t *testing.T) {
 		UserID:      uuid.New().String(),
 		ServiceName: "YouTube",
 	}
-	svc.On("GetByID", mock.Anything, id).Return(sub, nil)
-	svc.On("DeleteSubscription", mock.Anything, id).Return(nil)
+	svc.On("DeleteSubscription", mock.Anything, id).Return(sub, nil)
 
 	req := httptest.NewRequest(http.MethodDelete, "/subscriptions/"+id, nil)
 	req = muxWithParam(req, "id", id)
@@ -147,6 +363,7 @@ func TestDeleteSubscription_Success(t *testing.T) {
 
 	resp := w.Result()
 	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	svc.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
 }
 
 func TestGetTotalCost_Success(t *testing.T) {
@@ -277,3 +494,139 @@ func TestUpdateSubscription_Success(t *testing.T) {
 	assert.Equal(t, "Spotify", got.ServiceName)
 	svc.AssertExpectations(t)
 }
+
+var testSecret = []byte("test-secret")
+
+func bearer(t *testing.T, claims jwt.MapClaims) string {
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	assert.NoError(t, err)
+	return "Bearer " + tok
+}
+
+func TestAuthenticate(t *testing.T) {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: testSecret})
+	assert.NoError(t, err)
+
+	orgID := uuid.New().String()
+	var gotOrg, gotSub string
+	h := api.Authenticate(v, nil)(api.RequireTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotOrg, _ = tenant.OrgID(r.Context())
+		if p, ok := auth.FromContext(r.Context()); ok {
+			gotSub = p.Subject
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set("Authorization", bearer(t, jwt.MapClaims{"sub": "u1", "org_id": orgID, "exp": time.Now().Add(-time.Minute).Unix()}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set("Authorization", bearer(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(time.Hour).Unix()}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set("Authorization", bearer(t, jwt.MapClaims{"sub": "u1", "org_id": orgID, "exp": time.Now().Add(time.Hour).Unix()}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, orgID, gotOrg)
+	assert.Equal(t, "u1", gotSub)
+}
+
+func TestGetSubscriptionByID_Forbidden(t *testing.T) {
+	svc := new(mockService)
+	h := api.NewHandler(svc)
+
+	id := uuid.New().String()
+	svc.On("GetByID", mock.Anything, id).Return(nil, service.ErrForbidden)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/"+id, nil)
+	req = muxWithParam(req, "id", id)
+	w := httptest.NewRecorder()
+	h.GetSubscriptionByID(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRateLimit(t *testing.T) {
+	l := ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 3})
+	h := api.RateLimit(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	userCtx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "u1"})
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil).WithContext(userCtx)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Remaining"))
+
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions?limit=1000", nil).WithContext(userCtx)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "large pages cost more tokens")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	req = httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "anonymous clients are keyed by IP")
+}
+
+func TestRateLimit_SubjectsPerOrg(t *testing.T) {
+	l := ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 1})
+	h := api.RateLimit(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, org := range []string{"org-a", "org-b"} {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "u1", OrgID: org})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions", nil).WithContext(ctx))
+		assert.Equal(t, http.StatusOK, w.Code, "the same subject in another organization has its own budget")
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	l := ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 1})
+	h := api.RateLimitByIP(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, subject := range []string{"u1", "u2"} {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: subject})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions", nil).WithContext(ctx))
+		if subject == "u1" {
+			assert.Equal(t, http.StatusOK, w.Code)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, w.Code, "the address is limited whatever the credentials")
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	h := api.RequestTimeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
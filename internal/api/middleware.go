@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/logging"
+	"subscription-service/internal/ratelimit"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+func Authenticate(v *auth.JWTVerifier, keys APIKeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			credentials = strings.TrimSpace(credentials)
+
+			var (
+				p   *auth.Principal
+				err error
+			)
+			switch {
+			case strings.EqualFold(scheme, "Bearer") && credentials != "":
+				p, err = v.Verify(credentials)
+			case strings.EqualFold(scheme, "ApiKey") && credentials != "" && keys != nil:
+				p, err = keys.Authenticate(r.Context(), credentials)
+			default:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="subscriptions"`)
+				respondProblem(w, http.StatusUnauthorized, "bearer token or api key required")
+				return
+			}
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidToken) && !errors.Is(err, auth.ErrInvalidAPIKey) {
+					zerolog.Ctx(r.Context()).Error().Err(err).Msg("authentication failed")
+					respondProblem(w, http.StatusInternalServerError, "")
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="subscriptions", error="invalid_token"`)
+				respondProblem(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			logging.AddFields(r.Context(), func(c zerolog.Context) zerolog.Context {
+				c = c.Str("user", p.Subject).Str("org_id", p.OrgID)
+				if p.IsAPIKey() {
+					c = c.Str("api_key_id", p.APIKeyID)
+				}
+				return c
+			})
+
+			ctx := auth.WithPrincipal(r.Context(), p)
+			if p.OrgID != "" {
+				ctx = tenant.WithOrgID(ctx, p.OrgID)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.FromContext(r.Context())
+			if !ok || !p.HasScope(scope) {
+				respondProblem(w, http.StatusForbidden, "missing scope "+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := auth.FromContext(r.Context())
+		if !ok || p.IsAPIKey() || p.Role != auth.RoleAdmin {
+			respondProblem(w, http.StatusForbidden, "admin role required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func RequireTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := tenant.OrgID(r.Context())
+		if err != nil {
+			respondProblem(w, http.StatusForbidden, "credentials are not bound to an organization")
+			return
+		}
+		if _, err := uuid.Parse(orgID); err != nil {
+			respondProblem(w, http.StatusForbidden, "organization id must be uuid")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+const ConsistencyHeader = "X-Consistency"
+
+// ReadYourWrites sends the reads of a request to the primary database when
+// the client asks for "X-Consistency: strong", e.g. right after a write.
+func ReadYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get(ConsistencyHeader), "strong") {
+			r = r.WithContext(repository.WithPrimary(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestTimeout puts a deadline on the request context so that slow
+// database work is abandoned instead of piling up behind a stuck query.
+func RequestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RateLimit limits every client: an API key or JWT subject of an
+// organization once authenticated, otherwise an IP address.
+func RateLimit(l *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return rateLimit(l, rateLimitKey)
+}
+
+// RateLimitByIP limits every IP address whatever credentials it presents.
+// It goes before Authenticate, so that unauthenticated floods are limited
+// before they cost a token verification or an API key lookup.
+func RateLimitByIP(l *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return rateLimit(l, ipKey)
+}
+
+func rateLimit(l *ratelimit.Limiter, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := l.Allow(key(r), requestCost(r))
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				respondProblem(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey scopes subjects to their organization: the same subject in
+// two organizations has two budgets.
+func rateLimitKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		if p.IsAPIKey() {
+			return "key:" + p.OrgID + ":" + p.APIKeyID
+		}
+		return "sub:" + p.OrgID + ":" + p.Subject
+	}
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// requestCost makes large pages more expensive: every started 100 rows
+// requested via `limit` costs one token.
+func requestCost(r *http.Request) float64 {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 100 {
+		return 1
+	}
+	return math.Ceil(float64(limit) / 100)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func respondProblem(w http.ResponseWriter, code int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ReportHandler struct {
+	svc service.ReportService
+}
+
+func NewReportHandler(svc service.ReportService) *ReportHandler {
+	return &ReportHandler{svc: svc}
+}
+
+// GetForecast serves ?months= (default 12) of projected spend, optionally
+// for one ?user_id=.
+func (h *ReportHandler) GetForecast(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	months, ok := parseBounded(w, q, "months", 12, service.MaxForecastMonths)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserID(w, q)
+	if !ok {
+		return
+	}
+
+	forecast, err := h.svc.Forecast(r.Context(), months, userID)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, forecast)
+}
+
+// GetDuplicates pages through overlapping subscriptions, optionally of one
+// ?user_id=.
+func (h *ReportHandler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, ok := parseUserID(w, q)
+	if !ok {
+		return
+	}
+	limit, offset := parsePage(q)
+
+	overlaps, err := h.svc.Duplicates(r.Context(), userID, limit, offset)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	if overlaps == nil {
+		overlaps = []model.Overlap{}
+	}
+	writeJSON(w, http.StatusOK, overlaps)
+}
+
+// GetPriceAnomalies pages through subscriptions priced ?threshold= percent
+// (default 20) above the catalog or other users, or raised within ?days=
+// (default 90), optionally of one ?user_id=.
+func (h *ReportHandler) GetPriceAnomalies(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	in := service.PriceAnomalyInput{Threshold: 20, Days: 90}
+	var ok bool
+	if in.Threshold, ok = parseBounded(w, q, "threshold", in.Threshold, service.MaxAnomalyThreshold); !ok {
+		return
+	}
+	if in.Days, ok = parseBounded(w, q, "days", in.Days, service.MaxPriceHikeDays); !ok {
+		return
+	}
+	if in.UserID, ok = parseUserID(w, q); !ok {
+		return
+	}
+	in.Limit, in.Offset = parsePage(q)
+
+	anomalies, err := h.svc.PriceAnomalies(r.Context(), in)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	if anomalies == nil {
+		anomalies = []model.PriceAnomaly{}
+	}
+	writeJSON(w, http.StatusOK, anomalies)
+}
+
+// GetTop ranks the ?limit= (default 10) biggest users and services by spend
+// between ?from= and ?to= (YYYY-MM-DD).
+func (h *ReportHandler) GetTop(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, ok := parseDateRange(w, q)
+	if !ok {
+		return
+	}
+	limit, ok := parseBounded(w, q, "limit", 10, service.MaxTopLimit)
+	if !ok {
+		return
+	}
+
+	top, err := h.svc.Top(r.Context(), from, to, limit)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, top)
+}
+
+// GetChurn serves churn per service for the months ?from= through ?to=
+// (MM-YYYY, by default the last 12 months including the current one),
+// optionally of one ?service_name= and ?user_id=.
+func (h *ReportHandler) GetChurn(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if v := q.Get("to"); v != "" {
+		var err error
+		if to, err = parseMonthYear(v); err != nil {
+			respondErr(w, http.StatusBadRequest, "to must be MM-YYYY")
+			return
+		}
+	}
+	from := to.AddDate(0, -11, 0)
+	if v := q.Get("from"); v != "" {
+		var err error
+		if from, err = parseMonthYear(v); err != nil {
+			respondErr(w, http.StatusBadRequest, "from must be MM-YYYY")
+			return
+		}
+	}
+	userID, ok := parseUserID(w, q)
+	if !ok {
+		return
+	}
+	var serviceName *string
+	if sn := q.Get("service_name"); sn != "" {
+		serviceName = &sn
+	}
+
+	churn, err := h.svc.Churn(r.Context(), from, to, serviceName, userID)
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "from must not be after to, at most "+strconv.Itoa(service.MaxChurnMonths)+" months")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, churn)
+}
+
+// GetUserSummary serves the dashboard of {user_id}.
+func (h *ReportHandler) GetUserSummary(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "user_id")
+	if _, err := uuid.Parse(userID); err != nil {
+		respondErr(w, http.StatusBadRequest, "user_id must be uuid")
+		return
+	}
+
+	summary, err := h.svc.UserSummary(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// GetAllocation splits the spend between ?from= and ?to= (YYYY-MM-DD) by
+// ?group_by=cost_center (default) or tag.
+func (h *ReportHandler) GetAllocation(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, ok := parseDateRange(w, q)
+	if !ok {
+		return
+	}
+	groupBy := model.GroupByCostCenter
+	if v := q.Get("group_by"); v != "" {
+		groupBy = v
+	}
+	if groupBy != model.GroupByCostCenter && groupBy != model.GroupByTag {
+		respondErr(w, http.StatusBadRequest, "group_by must be cost_center or tag")
+		return
+	}
+
+	report, err := h.svc.Allocation(r.Context(), from, to, groupBy)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseDateRange reads the required ?from= and ?to= (YYYY-MM-DD).
+func parseDateRange(w http.ResponseWriter, q url.Values) (from, to time.Time, ok bool) {
+	from, err := time.Parse("2006-01-02", q.Get("from"))
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, "from must be YYYY-MM-DD")
+		return from, to, false
+	}
+	to, err = time.Parse("2006-01-02", q.Get("to"))
+	if err != nil {
+		respondErr(w, http.StatusBadRequest, "to must be YYYY-MM-DD")
+		return from, to, false
+	}
+	if from.After(to) {
+		respondErr(w, http.StatusBadRequest, "invalid date range: 'from' must be before 'to'")
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// parseBounded reads an optional integer parameter between 1 and max and
+// answers 400 if it is out of range.
+func parseBounded(w http.ResponseWriter, q url.Values, name string, def, max int) (int, bool) {
+	v := q.Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > max {
+		respondErr(w, http.StatusBadRequest, name+" must be between 1 and "+strconv.Itoa(max))
+		return 0, false
+	}
+	return n, true
+}
+
+// parseUserID reads an optional ?user_id= and answers 400 if it is not a
+// uuid.
+func parseUserID(w http.ResponseWriter, q url.Values) (*string, bool) {
+	uid := q.Get("user_id")
+	if uid == "" {
+		return nil, true
+	}
+	if _, err := uuid.Parse(uid); err != nil {
+		respondErr(w, http.StatusBadRequest, "user_id must be uuid")
+		return nil, false
+	}
+	return &uid, true
+}
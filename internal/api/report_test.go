@@ -0,0 +1,268 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockReportService struct {
+	mock.Mock
+}
+
+func (m *mockReportService) Forecast(ctx context.Context, months int, userID *string) (*model.Forecast, error) {
+	args := m.Called(ctx, months, userID)
+	if f, ok := args.Get(0).(*model.Forecast); ok {
+		return f, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportService) Duplicates(ctx context.Context, userID *string, limit, offset int) ([]model.Overlap, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	if o, ok := args.Get(0).([]model.Overlap); ok {
+		return o, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportService) PriceAnomalies(ctx context.Context, in service.PriceAnomalyInput) ([]model.PriceAnomaly, error) {
+	args := m.Called(ctx, in)
+	if a, ok := args.Get(0).([]model.PriceAnomaly); ok {
+		return a, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportService) Top(ctx context.Context, from, to time.Time, limit int) (*model.TopReport, error) {
+	args := m.Called(ctx, from, to, limit)
+	if r, ok := args.Get(0).(*model.TopReport); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportService) Churn(ctx context.Context, from, to time.Time, serviceName, userID *string) ([]model.ServiceChurn, error) {
+	args := m.Called(ctx, from, to, serviceName, userID)
+	if c, ok := args.Get(0).([]model.ServiceChurn); ok {
+		return c, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportService) UserSummary(ctx context.Context, userID string) (*model.UserSummary, error) {
+	args := m.Called(ctx, userID)
+	if u, ok := args.Get(0).(*model.UserSummary); ok {
+		return u, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReportService) Allocation(ctx context.Context, from, to time.Time, groupBy string) (*model.AllocationReport, error) {
+	args := m.Called(ctx, from, to, groupBy)
+	if a, ok := args.Get(0).(*model.AllocationReport); ok {
+		return a, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func newReportRouter(t *testing.T, svc *mockReportService) http.Handler {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: testSecret})
+	assert.NoError(t, err)
+	h := api.NewReportHandler(svc)
+
+	r := chi.NewRouter()
+	r.Route("/reports", func(r chi.Router) {
+		r.Use(api.Authenticate(v, nil), api.RequireTenant, api.RequireScope(auth.ScopeReportsRead))
+		r.Get("/forecast", h.GetForecast)
+		r.Get("/duplicates", h.GetDuplicates)
+		r.Get("/price-anomalies", h.GetPriceAnomalies)
+		r.Get("/top", h.GetTop)
+		r.Get("/churn", h.GetChurn)
+		r.Get("/allocation", h.GetAllocation)
+	})
+	r.Route("/users", func(r chi.Router) {
+		r.Use(api.Authenticate(v, nil), api.RequireTenant, api.RequireScope(auth.ScopeSubscriptionsRead))
+		r.Get("/{user_id}/summary", h.GetUserSummary)
+	})
+	return r
+}
+
+func TestGetForecast(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	svc.On("Forecast", mock.Anything, 12, (*string)(nil)).Return(&model.Forecast{Total: 4200, Months: []model.ForecastMonth{}}, nil)
+	svc.On("Forecast", mock.Anything, 3, (*string)(nil)).Return(&model.Forecast{Months: []model.ForecastMonth{}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/forecast", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got model.Forecast
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	assert.Equal(t, int64(4200), got.Total)
+
+	for query, want := range map[string]int{
+		"?months=3":       http.StatusOK,
+		"?months=0":       http.StatusBadRequest,
+		"?months=x":       http.StatusBadRequest,
+		"?user_id=nobody": http.StatusBadRequest,
+		"?months=100000":  http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/forecast"+query, nil)
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, query)
+	}
+}
+
+func TestGetDuplicates(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	svc.On("Duplicates", mock.Anything, (*string)(nil), 10, 20).Return(nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/duplicates?limit=10&offset=20", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/reports/duplicates?user_id=x", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetPriceAnomalies(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	svc.On("PriceAnomalies", mock.Anything, service.PriceAnomalyInput{Threshold: 20, Days: 90, Limit: 50}).
+		Return([]model.PriceAnomaly{{SubscriptionID: "s1", Reasons: []string{model.AnomalyPriceIncrease}}}, nil)
+	svc.On("PriceAnomalies", mock.Anything, service.PriceAnomalyInput{Threshold: 50, Days: 30, Limit: 50}).Return(nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/reports/price-anomalies", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got []model.PriceAnomaly
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	assert.Equal(t, []string{"price_increase"}, got[0].Reasons)
+
+	for query, want := range map[string]int{
+		"?threshold=50&days=30": http.StatusOK,
+		"?threshold=0":          http.StatusBadRequest,
+		"?days=x":               http.StatusBadRequest,
+		"?days=100000":          http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/price-anomalies"+query, nil)
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, query)
+	}
+}
+
+func TestGetTop(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	from, to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	svc.On("Top", mock.Anything, from, to, 10).Return(&model.TopReport{Total: 1500}, nil)
+	svc.On("Top", mock.Anything, from, to, 3).Return(&model.TopReport{}, nil)
+
+	for query, want := range map[string]int{
+		"?from=2025-03-01&to=2025-03-31":         http.StatusOK,
+		"?from=2025-03-01&to=2025-03-31&limit=3": http.StatusOK,
+		"?from=2025-03-01":                       http.StatusBadRequest,
+		"?from=2025-03-31&to=2025-03-01":         http.StatusBadRequest,
+		"?from=2025-03-01&to=2025-03-31&limit=0": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/top"+query, nil)
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, query)
+	}
+}
+
+func TestGetAllocation(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	from, to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	svc.On("Allocation", mock.Anything, from, to, model.GroupByCostCenter).Return(&model.AllocationReport{Total: 1500}, nil)
+	svc.On("Allocation", mock.Anything, from, to, model.GroupByTag).Return(&model.AllocationReport{}, nil)
+
+	for query, want := range map[string]int{
+		"?from=2025-03-01&to=2025-03-31":                  http.StatusOK,
+		"?from=2025-03-01&to=2025-03-31&group_by=tag":     http.StatusOK,
+		"?from=2025-03-01&to=2025-03-31&group_by=service": http.StatusBadRequest,
+		"?to=2025-03-31": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/allocation"+query, nil)
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, query)
+	}
+	svc.AssertExpectations(t)
+}
+
+func TestGetChurn(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	jan, mar := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	netflix := "Netflix"
+	svc.On("Churn", mock.Anything, jan, mar, &netflix, (*string)(nil)).Return([]model.ServiceChurn{{ServiceName: "Netflix"}}, nil)
+	svc.On("Churn", mock.Anything, mar, jan, (*string)(nil), (*string)(nil)).Return(nil, service.ErrInvalid)
+
+	for query, want := range map[string]int{
+		"?from=01-2025&to=03-2025&service_name=Netflix": http.StatusOK,
+		"?from=03-2025&to=01-2025":                      http.StatusBadRequest,
+		"?from=2025-01":                                 http.StatusBadRequest,
+		"?user_id=x":                                    http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/churn"+query, nil)
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, query)
+	}
+}
+
+func TestGetUserSummary(t *testing.T) {
+	svc := new(mockReportService)
+	router := newReportRouter(t, svc)
+	userID := uuid.New().String()
+	svc.On("UserSummary", mock.Anything, userID).Return(&model.UserSummary{UserID: userID, MonthlySpend: 1298}, nil)
+	other := uuid.New().String()
+	svc.On("UserSummary", mock.Anything, other).Return(nil, service.ErrForbidden)
+
+	for path, want := range map[string]int{
+		"/users/" + userID + "/summary": http.StatusOK,
+		"/users/" + other + "/summary":  http.StatusForbidden,
+		"/users/nobody/summary":         http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", adminToken(t))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, path)
+	}
+}
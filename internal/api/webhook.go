@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type WebhookHandler struct {
+	svc service.WebhookService
+}
+
+func NewWebhookHandler(svc service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{svc: svc}
+}
+
+type createWebhookReq struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type createWebhookResp struct {
+	*model.Webhook
+	Secret string `json:"secret"`
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var in createWebhookReq
+	if err := decodeJSON(r.Body, &in); err != nil {
+		respondErr(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	hook, secret, err := h.svc.CreateWebhook(r.Context(), service.CreateWebhookInput{URL: in.URL, EventTypes: in.EventTypes})
+	if err != nil {
+		switch err {
+		case service.ErrInvalid:
+			respondErr(w, http.StatusBadRequest, "url must be http(s) with a resolvable host, event_types must be a non-empty list of known event types")
+		case service.ErrPrivateURL:
+			respondErr(w, http.StatusBadRequest, "url must point to a public address")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	zerolog.Ctx(r.Context()).Info().
+		Str("webhook_id", hook.ID).
+		Str("url", hook.URL).
+		Strs("event_types", hook.EventTypes).
+		Msg("webhook created")
+	w.Header().Set("Location", "/webhooks/"+hook.ID)
+	writeJSON(w, http.StatusCreated, createWebhookResp{Webhook: hook, Secret: secret})
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		respondErr(w, http.StatusBadRequest, "id must be uuid")
+		return
+	}
+	limit, offset := parsePage(r.URL.Query())
+
+	deliveries, err := h.svc.ListDeliveries(r.Context(), id, limit, offset)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			respondErr(w, http.StatusNotFound, "not found")
+		case service.ErrForbidden:
+			respondErr(w, http.StatusForbidden, "forbidden")
+		default:
+			respondErr(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*model.WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
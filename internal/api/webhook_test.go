@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/api"
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockWebhookService struct {
+	mock.Mock
+}
+
+func (m *mockWebhookService) CreateWebhook(ctx context.Context, in service.CreateWebhookInput) (*model.Webhook, string, error) {
+	args := m.Called(ctx, in)
+	if w, ok := args.Get(0).(*model.Webhook); ok {
+		return w, args.String(1), args.Error(2)
+	}
+	return nil, "", args.Error(2)
+}
+func (m *mockWebhookService) ListDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit, offset)
+	if d, ok := args.Get(0).([]*model.WebhookDelivery); ok {
+		return d, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func newWebhookRouter(t *testing.T, svc *mockWebhookService) http.Handler {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: testSecret})
+	assert.NoError(t, err)
+	h := api.NewWebhookHandler(svc)
+
+	r := chi.NewRouter()
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(api.Authenticate(v, nil), api.RequireTenant, api.RequireScope(auth.ScopeWebhooksManage))
+		r.Post("/", h.CreateWebhook)
+		r.Get("/{id}/deliveries", h.ListDeliveries)
+	})
+	return r
+}
+
+func adminToken(t *testing.T) string {
+	return bearer(t, jwt.MapClaims{"sub": "u1", "org_id": uuid.New().String(), "role": "admin", "exp": time.Now().Add(time.Hour).Unix()})
+}
+
+func TestCreateWebhook(t *testing.T) {
+	svc := new(mockWebhookService)
+	router := newWebhookRouter(t, svc)
+
+	in := service.CreateWebhookInput{URL: "https://example.com/hook", EventTypes: []string{model.WebhookSubscriptionCreated}}
+	created := &model.Webhook{ID: uuid.New().String(), URL: in.URL, EventTypes: in.EventTypes, Secret: "whsec_x", Active: true}
+	svc.On("CreateWebhook", mock.Anything, in).Return(created, "whsec_x", nil)
+
+	body, _ := json.Marshal(map[string]any{"url": in.URL, "event_types": in.EventTypes})
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/webhooks/"+created.ID, w.Header().Get("Location"))
+	var got map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	assert.Equal(t, "whsec_x", got["secret"], "secret is returned once, on creation")
+	assert.Equal(t, true, got["active"])
+}
+
+func TestCreateWebhook_PrivateURL(t *testing.T) {
+	svc := new(mockWebhookService)
+	router := newWebhookRouter(t, svc)
+
+	in := service.CreateWebhookInput{URL: "http://169.254.169.254/latest/meta-data", EventTypes: []string{model.WebhookSubscriptionCreated}}
+	svc.On("CreateWebhook", mock.Anything, in).Return(nil, "", service.ErrPrivateURL)
+
+	body, _ := json.Marshal(map[string]any{"url": in.URL, "event_types": in.EventTypes})
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "public address")
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	svc := new(mockWebhookService)
+	router := newWebhookRouter(t, svc)
+	id := uuid.New().String()
+	missing := uuid.New().String()
+
+	svc.On("ListDeliveries", mock.Anything, id, 10, 20).Return([]*model.WebhookDelivery{{ID: "d1", Status: model.DeliveryFailed}}, nil)
+	svc.On("ListDeliveries", mock.Anything, missing, 50, 0).Return(nil, repository.ErrNotFound)
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks/"+id+"/deliveries?limit=10&offset=20", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"failed"`)
+
+	req = httptest.NewRequest(http.MethodGet, "/webhooks/"+missing+"/deliveries", nil)
+	req.Header.Set("Authorization", adminToken(t))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+const (
+	ScopeSubscriptionsRead  = "subscriptions:read"
+	ScopeSubscriptionsWrite = "subscriptions:write"
+	ScopeReportsRead        = "reports:read"
+	ScopeWebhooksManage     = "webhooks:manage"
+	ScopeBudgetsManage      = "budgets:manage"
+	ScopeCatalogManage      = "catalog:manage"
+)
+
+var KnownScopes = []string{ScopeSubscriptionsRead, ScopeSubscriptionsWrite, ScopeReportsRead, ScopeWebhooksManage, ScopeBudgetsManage, ScopeCatalogManage}
+
+const apiKeyPrefix = "sk_"
+
+// GenerateAPIKey returns a new plaintext key, its public prefix (safe to show in
+// listings) and the hash that is stored instead of the key itself.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	secret := hex.EncodeToString(buf)
+	key = apiKeyPrefix + secret
+	return key, key[:len(apiKeyPrefix)+8], HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsKnownScope(scope string) bool {
+	for _, s := range KnownScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscription-service/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var secret = []byte("test-secret")
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":    "user-1",
+		"org_id": "6f1c1c3e-8a44-4d55-9b1e-3f2f7b0a9c11",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify_HS256(t *testing.T) {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: secret})
+	require.NoError(t, err)
+
+	p, err := v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+	assert.Equal(t, "6f1c1c3e-8a44-4d55-9b1e-3f2f7b0a9c11", p.OrgID)
+	assert.Equal(t, "user-1", p.Claims["sub"])
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HS256Secret: secret, Issuer: "issuer"})
+	require.NoError(t, err)
+
+	expired := validClaims()
+	expired["iss"] = "issuer"
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "other"
+
+	noSubject := validClaims()
+	noSubject["iss"] = "issuer"
+	delete(noSubject, "sub")
+
+	okClaims := validClaims()
+	okClaims["iss"] = "issuer"
+
+	cases := map[string]string{
+		"expired":      sign(t, jwt.SigningMethodHS256, secret, "", expired),
+		"wrong issuer": sign(t, jwt.SigningMethodHS256, secret, "", wrongIssuer),
+		"no subject":   sign(t, jwt.SigningMethodHS256, secret, "", noSubject),
+		"wrong secret": sign(t, jwt.SigningMethodHS256, []byte("other"), "", okClaims),
+		"alg none":     sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", okClaims),
+		"garbage":      "not.a.token",
+	}
+	for name, tok := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := v.Verify(tok)
+			assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		})
+	}
+}
+
+func TestVerify_RS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	keys, err := auth.LoadJWKS(path)
+	require.NoError(t, err)
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{RS256Keys: keys})
+	require.NoError(t, err)
+
+	p, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, "k1", validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, key, "unknown", validClaims()))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", validClaims()))
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import "context"
+
+const (
+	RoleUser    = "user"
+	RoleFinance = "finance"
+	RoleAdmin   = "admin"
+)
+
+func IsKnownRole(role string) bool {
+	return role == RoleUser || role == RoleFinance || role == RoleAdmin
+}
+
+type Principal struct {
+	Subject  string
+	OrgID    string
+	Role     string
+	APIKeyID string
+	// Scopes restricts what the principal may do. Nil means unrestricted,
+	// which is the case for tokens that carry no scope claim.
+	Scopes []string
+	Claims map[string]interface{}
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != ""
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok && p != nil
+}
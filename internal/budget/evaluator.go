@@ -0,0 +1,146 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/notify"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	// Thresholds are percentages of the budget, e.g. 80 and 100.
+	Thresholds []int
+	Interval   time.Duration
+	BatchSize  int
+}
+
+// Evaluator compares every budget with the projected spend of the current
+// month and raises one alert per budget, month and crossed threshold. Alerts
+// go to the outbox (and from there to webhooks) and to the budget's owner:
+// the user of a personal budget, or the creator of an organization budget
+// unless it was created with an API key.
+type Evaluator struct {
+	repo     repository.BudgetRepo
+	notifier notify.Notifier
+	cfg      Config
+	now      func() time.Time
+}
+
+func NewEvaluator(repo repository.BudgetRepo, n notify.Notifier, cfg Config) *Evaluator {
+	return NewEvaluatorWithClock(repo, n, cfg, time.Now)
+}
+
+func NewEvaluatorWithClock(repo repository.BudgetRepo, n notify.Notifier, cfg Config, now func() time.Time) *Evaluator {
+	thresholds := slices.Clone(cfg.Thresholds)
+	slices.Sort(thresholds)
+	cfg.Thresholds = slices.Compact(thresholds)
+	return &Evaluator{repo: repo, notifier: n, cfg: cfg, now: now}
+}
+
+func (e *Evaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := e.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("budget evaluation failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce evaluates all budgets for the current month. Failed notifications
+// are released and retried by the next run.
+func (e *Evaluator) RunOnce(ctx context.Context) error {
+	now := e.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	after := ""
+	for {
+		budgets, err := e.repo.ListAfter(ctx, after, e.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, b := range budgets {
+			if err := e.evaluate(ctx, b, month); err != nil {
+				return err
+			}
+		}
+		if len(budgets) < e.cfg.BatchSize {
+			return nil
+		}
+		after = budgets[len(budgets)-1].ID
+	}
+}
+
+func (e *Evaluator) evaluate(ctx context.Context, b *model.Budget, month time.Time) error {
+	log := zerolog.Ctx(ctx)
+	ctx = tenant.WithOrgID(ctx, b.OrgID)
+
+	used, err := e.repo.MonthlySpend(ctx, month, b.UserID)
+	if err != nil {
+		return err
+	}
+	for _, t := range e.cfg.Thresholds {
+		if used*100 < b.Amount*int64(t) {
+			break
+		}
+		alert := &model.BudgetAlert{Budget: b, Month: month, Threshold: t, Used: used}
+		claimed, err := e.repo.ClaimAlert(ctx, alert)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		recipient := recipient(b)
+		if recipient == "" {
+			continue
+		}
+		if err := e.notifier.Notify(ctx, message(alert, recipient)); err != nil {
+			log.Warn().Err(err).Str("budget_id", b.ID).Int("threshold", t).Msg("budget alert not sent, will retry")
+			if err := e.repo.ReleaseAlert(ctx, alert); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Info().Str("budget_id", b.ID).Str("user_id", recipient).Int("threshold", t).Int64("used", used).Msg("budget alert sent")
+	}
+	return nil
+}
+
+func recipient(b *model.Budget) string {
+	if b.UserID != nil {
+		return *b.UserID
+	}
+	if strings.HasPrefix(b.CreatedBy, "apikey:") {
+		return ""
+	}
+	return b.CreatedBy
+}
+
+func message(a *model.BudgetAlert, userID string) notify.Message {
+	scope := "Ваш бюджет"
+	if a.Budget.UserID == nil {
+		scope = "Бюджет организации"
+	}
+	month := a.Month.Format("01.2006")
+	return notify.Message{
+		OrgID:   a.Budget.OrgID,
+		UserID:  userID,
+		Subject: fmt.Sprintf("%s на %s израсходован на %d%%", scope, month, a.Threshold),
+		Body: fmt.Sprintf("%s на %s: %d из %d руб. (%d%%) по прогнозу расходов на подписки.",
+			scope, month, a.Used, a.Budget.Amount, a.Used*100/a.Budget.Amount),
+	}
+}
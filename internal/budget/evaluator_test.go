@@ -0,0 +1,171 @@
+package budget_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscription-service/internal/budget"
+	"subscription-service/internal/model"
+	"subscription-service/internal/notify"
+	"subscription-service/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type alertKey struct {
+	budgetID  string
+	month     time.Time
+	threshold int
+}
+
+// memRepo mirrors pgBudgetRepo: alerts can be released, emitted events
+// cannot.
+type memRepo struct {
+	budgets []*model.Budget
+	spend   map[string]int64 // by org/user
+	alerts  map[alertKey]bool
+	emitted map[alertKey]bool
+	events  int
+}
+
+func (m *memRepo) Create(context.Context, *model.Budget) error { return nil }
+func (m *memRepo) GetByID(context.Context, string) (*model.Budget, error) {
+	return nil, errors.New("not used")
+}
+
+func (m *memRepo) MonthlySpend(ctx context.Context, _ time.Time, userID *string) (int64, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	key := orgID
+	if userID != nil {
+		key += "/" + *userID
+	}
+	return m.spend[key], nil
+}
+
+func (m *memRepo) ListAfter(_ context.Context, afterID string, limit int) ([]*model.Budget, error) {
+	var out []*model.Budget
+	for _, b := range m.budgets {
+		if b.ID > afterID && len(out) < limit {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) ClaimAlert(_ context.Context, a *model.BudgetAlert) (bool, error) {
+	k := alertKey{a.Budget.ID, a.Month, a.Threshold}
+	if m.alerts[k] {
+		return false, nil
+	}
+	m.alerts[k] = true
+	if m.emitted == nil {
+		m.emitted = map[alertKey]bool{}
+	}
+	if !m.emitted[k] {
+		m.emitted[k] = true
+		m.events++
+	}
+	return true, nil
+}
+
+func (m *memRepo) ReleaseAlert(_ context.Context, a *model.BudgetAlert) error {
+	delete(m.alerts, alertKey{a.Budget.ID, a.Month, a.Threshold})
+	return nil
+}
+
+type recorder struct {
+	sent []notify.Message
+	err  error
+}
+
+func (r *recorder) Notify(_ context.Context, m notify.Message) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+var (
+	now   = time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	march = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func setup() (*memRepo, *recorder, *budget.Evaluator) {
+	alice := "alice"
+	repo := &memRepo{
+		budgets: []*model.Budget{
+			{ID: "b1", OrgID: "org", UserID: &alice, Amount: 1000, CreatedBy: alice},
+			{ID: "b2", OrgID: "org", Amount: 10000, CreatedBy: "admin"},
+			{ID: "b3", OrgID: "org2", Amount: 100, CreatedBy: "apikey:k1"},
+		},
+		spend:  map[string]int64{"org/alice": 850, "org": 5000, "org2": 150},
+		alerts: map[alertKey]bool{},
+	}
+	rec := &recorder{}
+	e := budget.NewEvaluatorWithClock(repo, rec, budget.Config{Thresholds: []int{100, 80}, BatchSize: 2},
+		func() time.Time { return now })
+	return repo, rec, e
+}
+
+func TestRunOnce(t *testing.T) {
+	repo, rec, e := setup()
+
+	require.NoError(t, e.RunOnce(context.Background()))
+	require.Len(t, rec.sent, 1)
+	assert.Equal(t, "alice", rec.sent[0].UserID)
+	assert.Contains(t, rec.sent[0].Subject, "80%")
+	assert.True(t, repo.alerts[alertKey{"b1", march, 80}])
+	assert.False(t, repo.alerts[alertKey{"b2", march, 80}])
+	// Budgets created with an API key get the event but no notification.
+	assert.True(t, repo.alerts[alertKey{"b3", march, 80}])
+	assert.True(t, repo.alerts[alertKey{"b3", march, 100}])
+
+	// Crossing the next threshold alerts once more; nothing is repeated.
+	repo.spend["org/alice"] = 1200
+	rec.sent = nil
+	require.NoError(t, e.RunOnce(context.Background()))
+	require.Len(t, rec.sent, 1)
+	assert.Contains(t, rec.sent[0].Subject, "100%")
+
+	rec.sent = nil
+	require.NoError(t, e.RunOnce(context.Background()))
+	assert.Empty(t, rec.sent)
+}
+
+func TestRunOnce_ReleasesFailed(t *testing.T) {
+	repo, rec, e := setup()
+	rec.err = errors.New("mail server down")
+
+	require.NoError(t, e.RunOnce(context.Background()))
+	assert.False(t, repo.alerts[alertKey{"b1", march, 80}])
+
+	rec.err = nil
+	require.NoError(t, e.RunOnce(context.Background()))
+	require.Len(t, rec.sent, 1)
+	assert.Equal(t, "alice", rec.sent[0].UserID)
+}
+
+func TestRunOnce_RetryEmitsEventOnce(t *testing.T) {
+	repo, rec, e := setup()
+	rec.err = errors.New("mail server down")
+
+	for range 2 {
+		require.NoError(t, e.RunOnce(context.Background()))
+		assert.False(t, repo.alerts[alertKey{"b1", march, 80}])
+	}
+
+	rec.err = nil
+	require.NoError(t, e.RunOnce(context.Background()))
+	require.Len(t, rec.sent, 1)
+	assert.True(t, repo.alerts[alertKey{"b1", march, 80}])
+	// b1 crossed 80% and b3 both thresholds: one event each, although
+	// b1's notification failed twice.
+	assert.Equal(t, 3, repo.events)
+}
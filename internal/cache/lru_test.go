@@ -0,0 +1,47 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscription-service/internal/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	_ = c.Set(ctx, "a", []byte("1"), 0)
+	_ = c.Set(ctx, "b", []byte("2"), 0)
+	_, _, _ = c.Get(ctx, "a")
+	_ = c.Set(ctx, "c", []byte("3"), 0)
+
+	_, ok, _ := c.Get(ctx, "b")
+	assert.False(t, ok, "b was least recently used")
+	v, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(v))
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := cache.NewLRUWithClock(10, func() time.Time { return now })
+
+	_ = c.Set(ctx, "k", []byte("v"), time.Minute)
+	_ = c.Set(ctx, "forever", []byte("v"), 0)
+
+	now = now.Add(time.Minute)
+	_, ok, _ := c.Get(ctx, "k")
+	assert.False(t, ok)
+	_, ok, _ = c.Get(ctx, "forever")
+	assert.True(t, ok)
+	assert.Equal(t, 1, c.Len(), "expired entries are dropped on access")
+
+	_ = c.Delete(ctx, "forever")
+	assert.Equal(t, 0, c.Len())
+}
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const keyPrefix = "subscriptions:"
+
+type cachedRepo struct {
+	next  repository.SubscriptionRepo
+	store Store
+	ttl   time.Duration
+}
+
+// NewRepo caches GetByID and TotalCostForPeriod per organization. Keys embed
+// a generation that every successful write of the organization replaces, so
+// older entries are never read again and age out through ttl or eviction.
+// With a lagging read replica an entry may still be stale for up to ttl.
+func NewRepo(next repository.SubscriptionRepo, store Store, ttl time.Duration) repository.SubscriptionRepo {
+	return &cachedRepo{next: next, store: store, ttl: ttl}
+}
+
+func (r *cachedRepo) Create(ctx context.Context, s *model.Subscription) error {
+	if err := r.next.Create(ctx, s); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	key, data, hit := r.lookup(ctx, "GetByID", "get:"+id)
+	if hit {
+		var s model.Subscription
+		if err := json.Unmarshal(data, &s); err == nil {
+			return &s, nil
+		}
+	}
+
+	s, err := r.next.GetByID(ctx, id)
+	if err == nil && key != "" {
+		if data, err := json.Marshal(s); err == nil {
+			r.set(ctx, key, data)
+		}
+	}
+	return s, err
+}
+
+func (r *cachedRepo) Update(ctx context.Context, s *model.Subscription) error {
+	if err := r.next.Update(ctx, s); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) Delete(ctx context.Context, id string) error {
+	if err := r.next.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) List(ctx context.Context, filter repository.ListFilter) ([]*model.Subscription, error) {
+	return r.next.List(ctx, filter)
+}
+
+func (r *cachedRepo) FindOverlapping(ctx context.Context, s *model.Subscription) ([]*model.Subscription, error) {
+	return r.next.FindOverlapping(ctx, s)
+}
+
+// SetMembers changes how totals are attributed, so it invalidates them.
+func (r *cachedRepo) SetMembers(ctx context.Context, id string, members []model.Member) error {
+	if err := r.next.SetMembers(ctx, id, members); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	return r.next.ListMembers(ctx, id)
+}
+
+func (r *cachedRepo) SetTags(ctx context.Context, id string, tags []string) error {
+	if err := r.next.SetTags(ctx, id, tags); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) TagSubscriptions(ctx context.Context, ids, add, remove []string) error {
+	if err := r.next.TagSubscriptions(ctx, ids, add, remove); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) ListTags(ctx context.Context, userID *string) ([]model.Tag, error) {
+	return r.next.ListTags(ctx, userID)
+}
+
+func (r *cachedRepo) DeleteTag(ctx context.Context, name string) error {
+	if err := r.next.DeleteTag(ctx, name); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+// SetPriceSchedule changes the totals of the months it covers; applying a
+// price is invalidated by NewPriceScheduleRepo.
+func (r *cachedRepo) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) error {
+	if err := r.next.SetPriceSchedule(ctx, id, prices); err != nil {
+		return err
+	}
+	r.invalidate(ctx)
+	return nil
+}
+
+func (r *cachedRepo) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	return r.next.ListPriceSchedule(ctx, id)
+}
+
+func (r *cachedRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error) {
+	suffix := strings.Join([]string{"total", from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano),
+		optional(userID), optional(serviceName)}, ":")
+	key, data, hit := r.lookup(ctx, "TotalCostForPeriod", suffix)
+	if hit {
+		if total, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+			return total, nil
+		}
+	}
+
+	total, err := r.next.TotalCostForPeriod(ctx, from, to, userID, serviceName)
+	if err == nil && key != "" {
+		r.set(ctx, key, []byte(strconv.FormatInt(total, 10)))
+	}
+	return total, err
+}
+
+// lookup returns the key to populate after a miss, or "" when the call must
+// bypass the cache: no tenant, an explicit primary read or a store failure.
+func (r *cachedRepo) lookup(ctx context.Context, method, suffix string) (key string, data []byte, hit bool) {
+	if repository.ReadsPrimary(ctx) {
+		return "", nil, false
+	}
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return "", nil, false
+	}
+	gen, err := r.generation(ctx, orgID)
+	if err != nil {
+		r.fail(ctx, method, err)
+		return "", nil, false
+	}
+
+	key = keyPrefix + orgID + ":" + gen + ":" + suffix
+	data, hit, err = r.store.Get(ctx, key)
+	if err != nil {
+		r.fail(ctx, method, err)
+		return "", nil, false
+	}
+	if hit {
+		metrics.ObserveCacheLookup(method, "hit")
+		return key, data, true
+	}
+	metrics.ObserveCacheLookup(method, "miss")
+	return key, nil, false
+}
+
+func (r *cachedRepo) generation(ctx context.Context, orgID string) (string, error) {
+	data, ok, err := r.store.Get(ctx, generationKey(orgID))
+	if err != nil || ok {
+		return string(data), err
+	}
+	gen := uuid.NewString()
+	return gen, r.store.Set(ctx, generationKey(orgID), []byte(gen), 0)
+}
+
+func (r *cachedRepo) invalidate(ctx context.Context) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return
+	}
+	invalidateOrg(ctx, r.store, orgID)
+}
+
+func invalidateOrg(ctx context.Context, store Store, orgID string) {
+	if err := store.Set(ctx, generationKey(orgID), []byte(uuid.NewString()), 0); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("org_id", orgID).Msg("cache invalidation failed, entries stay until they expire")
+	}
+}
+
+func (r *cachedRepo) set(ctx context.Context, key string, data []byte) {
+	if err := r.store.Set(ctx, key, data, r.ttl); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("cache write failed")
+	}
+}
+
+func (r *cachedRepo) fail(ctx context.Context, method string, err error) {
+	metrics.ObserveCacheLookup(method, "error")
+	zerolog.Ctx(ctx).Warn().Err(err).Str("method", method).Msg("cache unavailable, reading from database")
+}
+
+func generationKey(orgID string) string {
+	return keyPrefix + "gen:" + orgID
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return "-"
+	}
+	return strconv.Quote(*s)
+}
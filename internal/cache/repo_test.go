@@ -0,0 +1,234 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscription-service/internal/cache"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRepo struct{ mock.Mock }
+
+func (m *mockRepo) Create(ctx context.Context, s *model.Subscription) error {
+	return m.Called(ctx, s).Error(0)
+}
+
+func (m *mockRepo) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	args := m.Called(ctx, id)
+	if s := args.Get(0); s != nil {
+		return s.(*model.Subscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) Update(ctx context.Context, s *model.Subscription) error {
+	return m.Called(ctx, s).Error(0)
+}
+
+func (m *mockRepo) Delete(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *mockRepo) List(ctx context.Context, f repository.ListFilter) ([]*model.Subscription, error) {
+	args := m.Called(ctx, f)
+	return args.Get(0).([]*model.Subscription), args.Error(1)
+}
+
+func (m *mockRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error) {
+	args := m.Called(ctx, from, to, userID, serviceName)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *mockRepo) FindOverlapping(ctx context.Context, s *model.Subscription) ([]*model.Subscription, error) {
+	args := m.Called(ctx, s)
+	if subs, ok := args.Get(0).([]*model.Subscription); ok {
+		return subs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) SetMembers(ctx context.Context, id string, members []model.Member) error {
+	return m.Called(ctx, id, members).Error(0)
+}
+
+func (m *mockRepo) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	args := m.Called(ctx, id)
+	if members, ok := args.Get(0).([]model.Member); ok {
+		return members, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) SetTags(ctx context.Context, id string, tags []string) error {
+	return m.Called(ctx, id, tags).Error(0)
+}
+
+func (m *mockRepo) TagSubscriptions(ctx context.Context, ids, add, remove []string) error {
+	return m.Called(ctx, ids, add, remove).Error(0)
+}
+
+func (m *mockRepo) ListTags(ctx context.Context, userID *string) ([]model.Tag, error) {
+	args := m.Called(ctx, userID)
+	if tags, ok := args.Get(0).([]model.Tag); ok {
+		return tags, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) DeleteTag(ctx context.Context, name string) error {
+	return m.Called(ctx, name).Error(0)
+}
+
+func (m *mockRepo) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) error {
+	return m.Called(ctx, id, prices).Error(0)
+}
+
+func (m *mockRepo) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	args := m.Called(ctx, id)
+	if prices, ok := args.Get(0).([]model.ScheduledPrice); ok {
+		return prices, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) Delete(context.Context, ...string) error {
+	return errors.New("connection refused")
+}
+
+var (
+	orgA = tenant.WithOrgID(context.Background(), "00000000-0000-0000-0000-00000000000a")
+	orgB = tenant.WithOrgID(context.Background(), "00000000-0000-0000-0000-00000000000b")
+)
+
+func TestTotal_CachedPerOrgUntilWrite(t *testing.T) {
+	next := new(mockRepo)
+	repo := cache.NewRepo(next, cache.NewLRU(100), time.Minute)
+	from, to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	next.On("TotalCostForPeriod", orgA, from, to, (*string)(nil), (*string)(nil)).Return(int64(100), nil).Once()
+	next.On("TotalCostForPeriod", orgB, from, to, (*string)(nil), (*string)(nil)).Return(int64(7), nil).Once()
+
+	for i := 0; i < 3; i++ {
+		got, err := repo.TotalCostForPeriod(orgA, from, to, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), got)
+	}
+	got, err := repo.TotalCostForPeriod(orgB, from, to, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), got, "organizations never share entries")
+
+	sub := &model.Subscription{ID: "s1"}
+	next.On("Create", orgA, sub).Return(nil).Once()
+	require.NoError(t, repo.Create(orgA, sub))
+
+	next.On("TotalCostForPeriod", orgA, from, to, (*string)(nil), (*string)(nil)).Return(int64(250), nil).Once()
+	got, err = repo.TotalCostForPeriod(orgA, from, to, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), got, "writes invalidate the organization's totals")
+
+	got, err = repo.TotalCostForPeriod(orgB, from, to, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), got, "other organizations stay cached")
+	next.AssertExpectations(t)
+}
+
+func TestGetByID_InvalidatedByUpdateAndDelete(t *testing.T) {
+	next := new(mockRepo)
+	repo := cache.NewRepo(next, cache.NewLRU(100), time.Minute)
+
+	old := &model.Subscription{ID: "s1", ServiceName: "Netflix", Price: 499}
+	next.On("GetByID", orgA, "s1").Return(old, nil).Once()
+	s, err := repo.GetByID(orgA, "s1")
+	require.NoError(t, err)
+	s, err = repo.GetByID(orgA, "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 499, s.Price)
+
+	updated := &model.Subscription{ID: "s1", ServiceName: "Netflix", Price: 599}
+	next.On("Update", orgA, updated).Return(nil).Once()
+	require.NoError(t, repo.Update(orgA, updated))
+
+	next.On("GetByID", orgA, "s1").Return(updated, nil).Once()
+	s, err = repo.GetByID(orgA, "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 599, s.Price)
+
+	next.On("Delete", orgA, "s1").Return(nil).Once()
+	require.NoError(t, repo.Delete(orgA, "s1"))
+
+	next.On("GetByID", orgA, "s1").Return(nil, repository.ErrNotFound).Twice()
+	_, err = repo.GetByID(orgA, "s1")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetByID(orgA, "s1")
+	assert.ErrorIs(t, err, repository.ErrNotFound, "misses are not cached")
+	next.AssertExpectations(t)
+}
+
+func TestBypass(t *testing.T) {
+	next := new(mockRepo)
+	sub := &model.Subscription{ID: "s1"}
+
+	repo := cache.NewRepo(next, failingStore{}, time.Minute)
+	next.On("GetByID", orgA, "s1").Return(sub, nil).Once()
+	_, err := repo.GetByID(orgA, "s1")
+	assert.NoError(t, err, "store failures fall through to the database")
+
+	repo = cache.NewRepo(next, cache.NewLRU(100), time.Minute)
+	primary := repository.WithPrimary(orgA)
+	next.On("GetByID", primary, "s1").Return(sub, nil).Twice()
+	_, _ = repo.GetByID(primary, "s1")
+	_, _ = repo.GetByID(primary, "s1")
+	next.AssertExpectations(t)
+}
+
+type appliedPrices struct{ applied int }
+
+func (a *appliedPrices) Due(context.Context, time.Time, *repository.DuePrice, int) ([]repository.DuePrice, error) {
+	return nil, nil
+}
+
+func (a *appliedPrices) Apply(context.Context, repository.DuePrice, time.Time) error {
+	a.applied++
+	return nil
+}
+
+func TestPriceScheduleApply_InvalidatesOrg(t *testing.T) {
+	next := new(mockRepo)
+	store := cache.NewLRU(100)
+	repo := cache.NewRepo(next, store, time.Minute)
+	schedule := cache.NewPriceScheduleRepo(&appliedPrices{}, store)
+
+	old := &model.Subscription{ID: "s1", Price: 499}
+	next.On("GetByID", orgA, "s1").Return(old, nil).Once()
+	_, err := repo.GetByID(orgA, "s1")
+	require.NoError(t, err)
+
+	// The applier has no tenant in its context.
+	orgID, err := tenant.OrgID(orgA)
+	require.NoError(t, err)
+	require.NoError(t, schedule.Apply(context.Background(), repository.DuePrice{SubscriptionID: "s1", OrgID: orgID}, time.Now()))
+
+	next.On("GetByID", orgA, "s1").Return(&model.Subscription{ID: "s1", Price: 599}, nil).Once()
+	s, err := repo.GetByID(orgA, "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 599, s.Price, "applied prices invalidate the organization")
+	next.AssertExpectations(t)
+}
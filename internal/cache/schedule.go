@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/repository"
+)
+
+type cachedScheduleRepo struct {
+	repository.PriceScheduleRepo
+	store Store
+}
+
+// NewPriceScheduleRepo invalidates the entries NewRepo caches in store for
+// the organization of every applied price. Apply runs outside any request,
+// so the organization is taken from the price rather than the context.
+func NewPriceScheduleRepo(next repository.PriceScheduleRepo, store Store) repository.PriceScheduleRepo {
+	return &cachedScheduleRepo{PriceScheduleRepo: next, store: store}
+}
+
+func (r *cachedScheduleRepo) Apply(ctx context.Context, d repository.DuePrice, at time.Time) error {
+	if err := r.PriceScheduleRepo.Apply(ctx, d, at); err != nil {
+		return err
+	}
+	invalidateOrg(ctx, r.store, d.OrgID)
+	return nil
+}
@@ -1,38 +1,398 @@
 package config
 
 import (
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
 )
 
+// Every leaf field can be set, in increasing order of precedence, by its
+// `default` tag, the YAML file (key path from `yaml` tags), the environment
+// variable named by `env` and the command line flag named after the YAML
+// path (e.g. --db.port). Fields tagged `secret` are redacted when printed.
 type Config struct {
-	AppPort    string
-	LogLevel   string
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBSSLMode  string
-}
-
-func Load() *Config {
-	return &Config{
-		DBUser:     mustGetEnv("DB_USER"),
-		DBPassword: mustGetEnv("DB_PASSWORD"),
-		DBHost:     mustGetEnv("DB_HOST"),
-		DBPort:     mustGetEnv("DB_PORT"),
-		DBName:     mustGetEnv("DB_NAME"),
-		DBSSLMode:  mustGetEnv("DB_SSLMODE"),
-		AppPort:    mustGetEnv("APP_PORT"),
-		LogLevel:   mustGetEnv("LOG_LEVEL"),
-	}
-}
-
-func mustGetEnv(key string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		log.Fatalf("environment variable %s not set", key)
-	}
-	return v
+	App           AppConfig           `yaml:"app"`
+	DB            DBConfig            `yaml:"db"`
+	Auth          AuthConfig          `yaml:"auth"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Cache         CacheConfig         `yaml:"cache"`
+	Outbox        OutboxConfig        `yaml:"outbox"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+	Subscriptions SubscriptionsConfig `yaml:"subscriptions"`
+	Reminders     RemindersConfig     `yaml:"reminders"`
+	Budgets       BudgetsConfig       `yaml:"budgets"`
+	PriceSchedule PriceScheduleConfig `yaml:"price_schedule"`
+	Notify        NotifyConfig        `yaml:"notify"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+	Health        HealthConfig        `yaml:"health"`
+}
+
+type AppConfig struct {
+	Port           int           `yaml:"port" env:"APP_PORT" default:"8080" usage:"HTTP listen port"`
+	LogLevel       string        `yaml:"log_level" env:"LOG_LEVEL" default:"info" usage:"zerolog level"`
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"APP_REQUEST_TIMEOUT" default:"10s" usage:"deadline of every API request context"`
+	MetricsAddr    string        `yaml:"metrics_addr" env:"METRICS_ADDR" default:":9090" usage:"internal listen address of /metrics, never expose it publicly; empty disables"`
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST" default:"localhost" usage:"PostgreSQL host"`
+	Port     int    `yaml:"port" env:"DB_PORT" default:"5432" usage:"PostgreSQL port"`
+	User     string `yaml:"user" env:"DB_USER" usage:"PostgreSQL user"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true" usage:"PostgreSQL password"`
+	Name     string `yaml:"name" env:"DB_NAME" usage:"PostgreSQL database"`
+	SSLMode  string `yaml:"sslmode" env:"DB_SSLMODE" default:"disable" usage:"PostgreSQL sslmode"`
+
+	ReplicaConnString string `yaml:"replica_dsn" env:"DB_REPLICA_DSN" secret:"true" usage:"read replica connection string (key=value or postgres:// URL); empty falls back to replica_host"`
+	ReplicaHost       string `yaml:"replica_host" env:"DB_REPLICA_HOST" usage:"read replica host sharing the primary's credentials; empty with replica_dsn disables replica routing"`
+	ReplicaPort       int    `yaml:"replica_port" env:"DB_REPLICA_PORT" usage:"read replica port, 0 uses db.port"`
+
+	MaxOpenConns     int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25" usage:"maximum open connections"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"5" usage:"maximum idle connections"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m" usage:"maximum connection lifetime"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m" usage:"maximum connection idle time"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT" default:"5s" usage:"server side statement_timeout, 0 disables"`
+	QueryTimeout     time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" default:"3s" usage:"client side deadline of each repository query, 0 disables"`
+}
+
+type AuthConfig struct {
+	JWTSecret   string `yaml:"jwt_hs256_secret" env:"JWT_HS256_SECRET" secret:"true" usage:"shared secret for HS256 tokens"`
+	JWTJWKSFile string `yaml:"jwt_jwks_file" env:"JWT_JWKS_FILE" usage:"local JWKS file with RS256 keys"`
+	JWTIssuer   string `yaml:"jwt_issuer" env:"JWT_ISSUER" usage:"required iss claim"`
+	JWTAudience string `yaml:"jwt_audience" env:"JWT_AUDIENCE" usage:"required aud claim"`
+}
+
+type RateLimitConfig struct {
+	ReadRPS    float64 `yaml:"read_rps" env:"RATE_LIMIT_READ_RPS" default:"20" usage:"read requests per second per client"`
+	ReadBurst  int     `yaml:"read_burst" env:"RATE_LIMIT_READ_BURST" default:"40" usage:"read burst per client"`
+	WriteRPS   float64 `yaml:"write_rps" env:"RATE_LIMIT_WRITE_RPS" default:"5" usage:"write requests per second per client"`
+	WriteBurst int     `yaml:"write_burst" env:"RATE_LIMIT_WRITE_BURST" default:"10" usage:"write burst per client"`
+	TotalRPS   float64 `yaml:"total_rps" env:"RATE_LIMIT_TOTAL_RPS" default:"0.5" usage:"/subscriptions/total requests per second per client"`
+	TotalBurst int     `yaml:"total_burst" env:"RATE_LIMIT_TOTAL_BURST" default:"5" usage:"/subscriptions/total burst per client"`
+	IPRPS      float64 `yaml:"ip_rps" env:"RATE_LIMIT_IP_RPS" default:"50" usage:"requests per second per IP address, checked before authentication"`
+	IPBurst    int     `yaml:"ip_burst" env:"RATE_LIMIT_IP_BURST" default:"100" usage:"burst per IP address"`
+}
+
+type CacheConfig struct {
+	Backend string        `yaml:"backend" env:"CACHE_BACKEND" default:"memory" usage:"memory or none"`
+	Size    int           `yaml:"size" env:"CACHE_SIZE" default:"10000" usage:"maximum entries of the memory cache"`
+	TTL     time.Duration `yaml:"ttl" env:"CACHE_TTL" default:"30s" usage:"lifetime of cached reads and totals"`
+}
+
+type OutboxConfig struct {
+	Sinks        string        `yaml:"sinks" env:"OUTBOX_SINKS" default:"webhooks" usage:"comma separated event sinks: webhooks, stdout, file, http, nats, kafka; none disables the relay"`
+	File         string        `yaml:"file" env:"OUTBOX_FILE" default:"events.jsonl" usage:"destination of the file sink"`
+	HTTPURL      string        `yaml:"http_url" env:"OUTBOX_HTTP_URL" usage:"endpoint of the http sink"`
+	NATSURL      string        `yaml:"nats_url" env:"OUTBOX_NATS_URL" secret:"true" usage:"nats://[user:pass@]host[:port] of the nats sink"`
+	NATSSubject  string        `yaml:"nats_subject" env:"OUTBOX_NATS_SUBJECT" default:"subscriptions" usage:"subject prefix of the nats sink"`
+	KafkaBrokers string        `yaml:"kafka_brokers" env:"OUTBOX_KAFKA_BROKERS" usage:"comma separated host:port of the kafka sink"`
+	KafkaTopic   string        `yaml:"kafka_topic" env:"OUTBOX_KAFKA_TOPIC" default:"subscriptions" usage:"topic of the kafka sink"`
+	SinkTimeout  time.Duration `yaml:"sink_timeout" env:"OUTBOX_SINK_TIMEOUT" default:"5s" usage:"timeout of one http, nats or kafka delivery"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" default:"100" usage:"events leased per relay batch"`
+	Lease        time.Duration `yaml:"lease" env:"OUTBOX_LEASE" default:"10m" usage:"how long a batch is reserved while it is published, at least batch_size * sink_timeout"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" default:"1s" usage:"relay polling interval"`
+	RetryMax     time.Duration `yaml:"retry_max" env:"OUTBOX_RETRY_MAX" default:"10m" usage:"maximum backoff between delivery attempts"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION" default:"168h" usage:"how long published events are kept, 0 keeps them"`
+}
+
+// KafkaBrokerList splits KafkaBrokers.
+func (c OutboxConfig) KafkaBrokerList() []string {
+	var out []string
+	for _, b := range strings.Split(c.KafkaBrokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// SinkList returns the configured sinks, or nil when the relay is disabled.
+func (c OutboxConfig) SinkList() []string {
+	var out []string
+	for _, s := range strings.Split(c.Sinks, ",") {
+		if s = strings.TrimSpace(s); s != "" && s != "none" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+type WebhooksConfig struct {
+	Timeout      time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" default:"10s" usage:"timeout of one delivery attempt"`
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"8" usage:"attempts before a delivery is marked failed"`
+	DisableAfter int           `yaml:"disable_after" env:"WEBHOOK_DISABLE_AFTER" default:"20" usage:"consecutive failed attempts that disable a webhook"`
+	RetryBase    time.Duration `yaml:"retry_base" env:"WEBHOOK_RETRY_BASE" default:"30s" usage:"delay before the first retry, doubled on every further one"`
+	RetryMax     time.Duration `yaml:"retry_max" env:"WEBHOOK_RETRY_MAX" default:"6h" usage:"maximum delay between attempts"`
+	BatchSize    int           `yaml:"batch_size" env:"WEBHOOK_BATCH_SIZE" default:"20" usage:"deliveries leased per dispatcher batch"`
+	Lease        time.Duration `yaml:"lease" env:"WEBHOOK_LEASE" default:"5m" usage:"how long a batch is reserved while it is sent, at least batch_size * timeout"`
+	AllowPrivate bool          `yaml:"allow_private" env:"WEBHOOK_ALLOW_PRIVATE" usage:"accept webhook URLs on loopback and private addresses, for local development only"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" default:"2s" usage:"dispatcher polling interval"`
+}
+
+type SubscriptionsConfig struct {
+	DuplicatePolicy string `yaml:"duplicate_policy" env:"DUPLICATE_POLICY" default:"warn" usage:"overlapping subscriptions of a user and service: allow, warn or reject"`
+}
+
+type RemindersConfig struct {
+	Enabled   bool          `yaml:"enabled" env:"REMINDERS_ENABLED" default:"true" usage:"run the reminder scheduler"`
+	LeadDays  string        `yaml:"lead_days" env:"REMINDER_LEAD_DAYS" default:"7,1" usage:"comma separated days before end_date to remind at"`
+	Interval  time.Duration `yaml:"interval" env:"REMINDER_INTERVAL" default:"1h" usage:"how often due reminders are looked up"`
+	BatchSize int           `yaml:"batch_size" env:"REMINDER_BATCH_SIZE" default:"100" usage:"subscriptions fetched per query"`
+}
+
+// LeadDayList parses LeadDays.
+func (c RemindersConfig) LeadDayList() ([]int, error) {
+	return parseInts(c.LeadDays, 0, math.MaxInt)
+}
+
+type PriceScheduleConfig struct {
+	Enabled   bool          `yaml:"enabled" env:"PRICE_SCHEDULE_ENABLED" default:"true" usage:"apply scheduled price changes"`
+	Interval  time.Duration `yaml:"interval" env:"PRICE_SCHEDULE_INTERVAL" default:"1h" usage:"how often due prices are looked up"`
+	BatchSize int           `yaml:"batch_size" env:"PRICE_SCHEDULE_BATCH_SIZE" default:"100" usage:"scheduled prices fetched per query"`
+}
+
+type BudgetsConfig struct {
+	AlertsEnabled bool          `yaml:"alerts_enabled" env:"BUDGET_ALERTS_ENABLED" default:"true" usage:"run the budget evaluator"`
+	Thresholds    string        `yaml:"thresholds" env:"BUDGET_THRESHOLDS" default:"80,100" usage:"comma separated percentages of a budget that trigger alerts"`
+	Interval      time.Duration `yaml:"interval" env:"BUDGET_EVAL_INTERVAL" default:"15m" usage:"how often budgets are evaluated"`
+	BatchSize     int           `yaml:"batch_size" env:"BUDGET_BATCH_SIZE" default:"100" usage:"budgets fetched per query"`
+}
+
+// ThresholdList parses Thresholds.
+func (c BudgetsConfig) ThresholdList() ([]int, error) {
+	return parseInts(c.Thresholds, 1, 1000)
+}
+
+// NotifyConfig selects how reminders and budget alerts reach users.
+type NotifyConfig struct {
+	Channel        string        `yaml:"channel" env:"NOTIFY_CHANNEL" default:"log" usage:"log or smtp"`
+	SMTPAddr       string        `yaml:"smtp_addr" env:"SMTP_ADDR" usage:"SMTP server host:port"`
+	SMTPUsername   string        `yaml:"smtp_username" env:"SMTP_USERNAME" usage:"SMTP user, empty disables auth"`
+	SMTPPassword   string        `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true" usage:"SMTP password"`
+	SMTPFrom       string        `yaml:"smtp_from" env:"SMTP_FROM" usage:"sender address"`
+	SMTPTimeout    time.Duration `yaml:"smtp_timeout" env:"SMTP_TIMEOUT" default:"30s" usage:"deadline of one email, from dialing to QUIT"`
+	EmailRecipient string        `yaml:"email_recipient" env:"NOTIFY_EMAIL_RECIPIENT" usage:"text/template of the recipient address, e.g. {{.UserID}}@users.example.com"`
+}
+
+func parseInts(list string, lo, hi int) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(list, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || v < lo || v > hi {
+			return nil, fmt.Errorf("invalid value %q", f)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" default:"none" usage:"none, stdout, file or otlp"`
+	File        string  `yaml:"file" env:"TRACING_FILE" default:"traces.jsonl" usage:"destination of the file exporter"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" usage:"share of traces sampled"`
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s" usage:"readiness check timeout"`
+	DrainDelay   time.Duration `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"5s" usage:"time readiness fails before the server stops"`
+}
+
+func (c DBConfig) DSN() string {
+	return c.dsn(c.Host, c.Port)
+}
+
+// ReplicaDSN returns the connection string of the read replica, or "" if
+// none is configured and reads go to the primary. ReplicaConnString is used
+// as given, with statement_timeout added unless it sets one; otherwise the
+// replica at ReplicaHost shares credentials and settings with the primary.
+func (c DBConfig) ReplicaDSN() string {
+	if c.ReplicaConnString != "" {
+		return c.withStatementTimeout(c.ReplicaConnString)
+	}
+	if c.ReplicaHost == "" {
+		return ""
+	}
+	port := c.ReplicaPort
+	if port == 0 {
+		port = c.Port
+	}
+	return c.dsn(c.ReplicaHost, port)
+}
+
+func (c DBConfig) dsn(host string, port int) string {
+	return c.withStatementTimeout(fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		host, port, c.User, c.Password, c.Name, c.SSLMode))
+}
+
+func (c DBConfig) withStatementTimeout(dsn string) string {
+	if c.StatementTimeout <= 0 || strings.Contains(dsn, "statement_timeout") {
+		return dsn
+	}
+	ms := strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "statement_timeout=" + ms
+	}
+	return dsn + " statement_timeout=" + ms
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.App.Port > 0 && c.App.Port < 65536, "app.port: must be between 1 and 65535")
+	_, err := zerolog.ParseLevel(c.App.LogLevel)
+	check(err == nil, "app.log_level: unknown level %q", c.App.LogLevel)
+	check(c.App.RequestTimeout > 0, "app.request_timeout: must be positive")
+
+	check(c.DB.Host != "", "db.host: required")
+	check(c.DB.Port > 0 && c.DB.Port < 65536, "db.port: must be between 1 and 65535")
+	check(c.DB.User != "", "db.user: required")
+	check(c.DB.Name != "", "db.name: required")
+	check(c.DB.SSLMode != "", "db.sslmode: required")
+	check(c.DB.ReplicaConnString == "" || c.DB.ReplicaHost == "", "db.replica_dsn: set either replica_dsn or replica_host")
+	check(c.DB.ReplicaPort >= 0 && c.DB.ReplicaPort <= 65535, "db.replica_port: must be between 0 and 65535")
+	check(c.DB.MaxOpenConns > 0, "db.max_open_conns: must be positive")
+	check(c.DB.MaxIdleConns >= 0 && c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "db.max_idle_conns: must be between 0 and max_open_conns")
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime: must not be negative")
+	check(c.DB.ConnMaxIdleTime >= 0, "db.conn_max_idle_time: must not be negative")
+	check(c.DB.StatementTimeout >= 0, "db.statement_timeout: must not be negative")
+	check(c.DB.QueryTimeout >= 0, "db.query_timeout: must not be negative")
+	check(c.DB.QueryTimeout == 0 || c.DB.QueryTimeout < c.App.RequestTimeout, "db.query_timeout: must be shorter than app.request_timeout")
+
+	check(c.Auth.JWTSecret != "" || c.Auth.JWTJWKSFile != "", "auth: jwt_hs256_secret or jwt_jwks_file required")
+
+	check(c.RateLimit.ReadRPS > 0, "rate_limit.read_rps: must be positive")
+	check(c.RateLimit.ReadBurst > 0, "rate_limit.read_burst: must be positive")
+	check(c.RateLimit.WriteRPS > 0, "rate_limit.write_rps: must be positive")
+	check(c.RateLimit.WriteBurst > 0, "rate_limit.write_burst: must be positive")
+	check(c.RateLimit.TotalRPS > 0, "rate_limit.total_rps: must be positive")
+	check(c.RateLimit.TotalBurst > 0, "rate_limit.total_burst: must be positive")
+	check(c.RateLimit.IPRPS > 0, "rate_limit.ip_rps: must be positive")
+	check(c.RateLimit.IPBurst > 0, "rate_limit.ip_burst: must be positive")
+
+	switch c.Cache.Backend {
+	case "none":
+	case "memory":
+		check(c.Cache.Size > 0, "cache.size: must be positive")
+		check(c.Cache.TTL > 0, "cache.ttl: must be positive")
+	default:
+		check(false, "cache.backend: unknown backend %q", c.Cache.Backend)
+	}
+
+	for _, sink := range c.Outbox.SinkList() {
+		switch sink {
+		case "webhooks", "stdout":
+		case "file":
+			check(c.Outbox.File != "", "outbox.file: required for the file sink")
+		case "http":
+			u, err := url.Parse(c.Outbox.HTTPURL)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https"), "outbox.http_url: http(s) url required for the http sink")
+		case "nats":
+			check(strings.HasPrefix(c.Outbox.NATSURL, "nats://"), "outbox.nats_url: nats:// url required for the nats sink")
+		case "kafka":
+			check(len(c.Outbox.KafkaBrokerList()) > 0, "outbox.kafka_brokers: required for the kafka sink")
+			check(c.Outbox.KafkaTopic != "", "outbox.kafka_topic: required for the kafka sink")
+		default:
+			check(false, "outbox.sinks: unknown sink %q", sink)
+		}
+	}
+	check(c.Outbox.SinkTimeout > 0, "outbox.sink_timeout: must be positive")
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size: must be positive")
+	check(c.Outbox.Lease >= time.Duration(c.Outbox.BatchSize)*c.Outbox.SinkTimeout,
+		"outbox.lease: must be at least batch_size * sink_timeout")
+	check(c.Outbox.PollInterval > 0, "outbox.poll_interval: must be positive")
+	check(c.Outbox.RetryMax > 0, "outbox.retry_max: must be positive")
+	check(c.Outbox.Retention >= 0, "outbox.retention: must not be negative")
+
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout: must be positive")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts: must be positive")
+	check(c.Webhooks.DisableAfter > 0, "webhooks.disable_after: must be positive")
+	check(c.Webhooks.RetryBase > 0 && c.Webhooks.RetryBase <= c.Webhooks.RetryMax, "webhooks.retry_base: must be positive and at most retry_max")
+	check(c.Webhooks.BatchSize > 0, "webhooks.batch_size: must be positive")
+	check(c.Webhooks.Lease >= time.Duration(c.Webhooks.BatchSize)*c.Webhooks.Timeout,
+		"webhooks.lease: must be at least batch_size * timeout")
+	check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval: must be positive")
+
+	switch c.Subscriptions.DuplicatePolicy {
+	case "allow", "warn", "reject":
+	default:
+		check(false, "subscriptions.duplicate_policy: unknown policy %q", c.Subscriptions.DuplicatePolicy)
+	}
+
+	if c.Reminders.Enabled {
+		_, err := c.Reminders.LeadDayList()
+		check(err == nil, "reminders.lead_days: %v", err)
+		check(c.Reminders.Interval > 0, "reminders.interval: must be positive")
+		check(c.Reminders.BatchSize > 0, "reminders.batch_size: must be positive")
+	}
+
+	if c.Budgets.AlertsEnabled {
+		_, err := c.Budgets.ThresholdList()
+		check(err == nil, "budgets.thresholds: %v", err)
+		check(c.Budgets.Interval > 0, "budgets.interval: must be positive")
+		check(c.Budgets.BatchSize > 0, "budgets.batch_size: must be positive")
+	}
+
+	if c.PriceSchedule.Enabled {
+		check(c.PriceSchedule.Interval > 0, "price_schedule.interval: must be positive")
+		check(c.PriceSchedule.BatchSize > 0, "price_schedule.batch_size: must be positive")
+	}
+
+	switch c.Notify.Channel {
+	case "log":
+	case "smtp":
+		check(c.Notify.SMTPAddr != "", "notify.smtp_addr: required for the smtp channel")
+		check(c.Notify.SMTPFrom != "", "notify.smtp_from: required for the smtp channel")
+		check(c.Notify.SMTPTimeout > 0, "notify.smtp_timeout: must be positive")
+		check(c.Notify.EmailRecipient != "", "notify.email_recipient: required for the smtp channel")
+	default:
+		check(false, "notify.channel: unknown channel %q", c.Notify.Channel)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	case "file":
+		check(c.Tracing.File != "", "tracing.file: required for the file exporter")
+	default:
+		check(false, "tracing.exporter: unknown exporter %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1")
+
+	check(c.Health.CheckTimeout > 0, "health.check_timeout: must be positive")
+	check(c.Health.DrainDelay >= 0, "health.drain_delay: must not be negative")
+
+	return errors.Join(errs...)
+}
+
+const redacted = "<redacted>"
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	cp := *c
+	for _, f := range fields(&cp) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString(redacted)
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&cp); err != nil {
+		return err
+	}
+	return enc.Close()
 }
@@ -0,0 +1,157 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscription-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, `
+app:
+  port: 9000
+  log_level: debug
+db:
+  host: file-host
+  user: file-user
+  name: subscriptions
+auth:
+  jwt_hs256_secret: file-secret
+health:
+  check_timeout: 3s
+`)
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("APP_PORT", "9100")
+
+	cfg, err := config.Load([]string{"--config", path, "--app.port", "9200"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9200, cfg.App.Port, "flag beats env and file")
+	assert.Equal(t, "env-host", cfg.DB.Host, "env beats file")
+	assert.Equal(t, "file-user", cfg.DB.User, "file beats default")
+	assert.Equal(t, "debug", cfg.App.LogLevel)
+	assert.Equal(t, 5432, cfg.DB.Port, "default")
+	assert.Equal(t, 3*time.Second, cfg.Health.CheckTimeout)
+	assert.Equal(t, 5*time.Second, cfg.Health.DrainDelay)
+}
+
+func TestLoad_ReportsAllErrors(t *testing.T) {
+	t.Setenv("DB_PORT", "not-a-number")
+
+	_, err := config.Load([]string{"--app.port", "0", "--tracing.exporter", "zipkin"})
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, "db.port: env DB_PORT")
+	assert.Contains(t, msg, "app.port: must be between")
+	assert.Contains(t, msg, "db.user: required")
+	assert.Contains(t, msg, "auth: jwt_hs256_secret or jwt_jwks_file required")
+	assert.Contains(t, msg, `tracing.exporter: unknown exporter "zipkin"`)
+}
+
+func TestLoad_UnknownFileKey(t *testing.T) {
+	path := writeFile(t, "db:\n  hots: typo\n")
+	_, err := config.Load([]string{"--config", path})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hots")
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	cfg, err := config.Load([]string{
+		"--db.user", "app", "--db.name", "subs", "--db.password", "hunter2", "--auth.jwt_hs256_secret", "s3cret",
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Print(&buf))
+	out := buf.String()
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "s3cret")
+	assert.Contains(t, out, "password: <redacted>")
+	assert.Contains(t, out, "check_timeout: 2s")
+	assert.Equal(t, "hunter2", cfg.DB.Password, "printing does not modify the config")
+}
+
+func TestDSN_StatementTimeout(t *testing.T) {
+	cfg, err := config.Load([]string{
+		"--db.user", "app", "--db.name", "subs", "--auth.jwt_hs256_secret", "s3cret", "--db.statement_timeout", "1500ms",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, cfg.DB.DSN(), "statement_timeout=1500")
+
+	cfg.DB.StatementTimeout = 0
+	assert.NotContains(t, cfg.DB.DSN(), "statement_timeout")
+}
+
+func TestLoad_PoolValidation(t *testing.T) {
+	_, err := config.Load([]string{
+		"--db.user", "app", "--db.name", "subs", "--auth.jwt_hs256_secret", "s3cret",
+		"--db.max_open_conns", "2", "--db.max_idle_conns", "5", "--db.query_timeout", "1m",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "db.max_idle_conns: must be between")
+	assert.Contains(t, err.Error(), "db.query_timeout: must be shorter")
+}
+
+func TestReplicaDSN(t *testing.T) {
+	cfg, err := config.Load([]string{
+		"--db.user", "app", "--db.name", "subs", "--auth.jwt_hs256_secret", "s3cret", "--db.port", "6432",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, cfg.DB.ReplicaDSN())
+
+	cfg.DB.ReplicaHost = "replica"
+	assert.Contains(t, cfg.DB.ReplicaDSN(), "host=replica port=6432 user=app")
+}
+
+func TestReplicaDSN_ConnString(t *testing.T) {
+	base := []string{"--db.user", "app", "--db.name", "subs", "--auth.jwt_hs256_secret", "s3cret"}
+
+	t.Setenv("DB_REPLICA_DSN", "postgres://ro:pw@replica:6432/subs?sslmode=require")
+	cfg, err := config.Load(base)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://ro:pw@replica:6432/subs?sslmode=require&statement_timeout=5000", cfg.DB.ReplicaDSN())
+
+	cfg.DB.ReplicaConnString = "host=replica user=ro statement_timeout=100"
+	assert.Equal(t, "host=replica user=ro statement_timeout=100", cfg.DB.ReplicaDSN())
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+	assert.NotContains(t, out.String(), "statement_timeout=100")
+
+	_, err = config.Load(append(base, "--db.replica_host", "replica"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "db.replica_dsn: set either replica_dsn or replica_host")
+}
+
+func TestLoad_Notifications(t *testing.T) {
+	base := []string{"--db.user", "app", "--db.name", "subs", "--auth.jwt_hs256_secret", "s3cret"}
+
+	cfg, err := config.Load(base)
+	require.NoError(t, err)
+	days, err := cfg.Reminders.LeadDayList()
+	require.NoError(t, err)
+	assert.Equal(t, []int{7, 1}, days)
+
+	thresholds, err := cfg.Budgets.ThresholdList()
+	require.NoError(t, err)
+	assert.Equal(t, []int{80, 100}, thresholds)
+
+	_, err = config.Load(append(base, "--reminders.lead_days", "7,x", "--budgets.thresholds", "0", "--notify.channel", "smtp"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reminders.lead_days")
+	assert.Contains(t, err.Error(), "budgets.thresholds")
+	assert.Contains(t, err.Error(), "notify.smtp_addr: required")
+}
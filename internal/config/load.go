@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from defaults, the YAML file given by
+// --config (or CONFIG_FILE), the environment and the flags in args. All
+// problems, including validation failures, are reported together; the
+// returned config is usable for printing even when err is not nil.
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	fs := fields(cfg)
+
+	var errs []error
+	for _, f := range fs {
+		if f.def == "" {
+			continue
+		}
+		if err := setValue(f.value, f.def); err != nil {
+			errs = append(errs, fmt.Errorf("%s: bad default %q: %w", f.path, f.def, err))
+		}
+	}
+
+	flagSet := flag.NewFlagSet("subscription-service", flag.ContinueOnError)
+	configFile := flagSet.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flagValues := make(map[string]string)
+	for _, f := range fs {
+		path := f.path
+		flagSet.Func(path, f.usage, func(s string) error {
+			flagValues[path] = s
+			return nil
+		})
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, f := range fs {
+		if f.env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(f.env); ok && v != "" {
+			if err := setValue(f.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: env %s=%q: %w", f.path, f.env, v, err))
+			}
+		}
+	}
+
+	for _, f := range fs {
+		if v, ok := flagValues[f.path]; ok {
+			if err := setValue(f.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: flag --%s=%q: %w", f.path, f.path, v, err))
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+type field struct {
+	path   string
+	env    string
+	def    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func fields(cfg *Config) []field {
+	return collect(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func collect(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		path := sf.Tag.Get("yaml")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			out = append(out, collect(fv, path)...)
+			continue
+		}
+		out = append(out, field{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			def:    sf.Tag.Get("default"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/health"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+type readyReport struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+func ready(c *health.Checker) (int, readyReport) {
+	w := httptest.NewRecorder()
+	c.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var rep readyReport
+	_ = json.NewDecoder(w.Body).Decode(&rep)
+	return w.Code, rep
+}
+
+func TestReady(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.Add("ok", func(ctx context.Context) error { return nil })
+
+	code, rep := ready(c)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", rep.Status)
+
+	c.Add("broken", func(ctx context.Context) error { return errors.New("boom") })
+	code, rep = ready(c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", rep.Status)
+	assert.Equal(t, "ok", rep.Checks["ok"].Status)
+	assert.Equal(t, "boom", rep.Checks["broken"].Error)
+}
+
+func TestReady_Timeout(t *testing.T) {
+	c := health.NewChecker(10 * time.Millisecond)
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, rep := ready(c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", rep.Checks["slow"].Status)
+}
+
+func TestReady_Draining(t *testing.T) {
+	c := health.NewChecker(time.Second)
+	c.SetDraining()
+
+	code, rep := ready(c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", rep.Checks["shutdown"].Status)
+
+	w := httptest.NewRecorder()
+	c.Live(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "liveness is unaffected by draining")
+}
+
+func TestMigrationVersion(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	check := health.MigrationVersion(db, 3)
+	q := regexp.QuoteMeta(`SELECT version, dirty FROM schema_migrations`)
+
+	mock.ExpectQuery(q).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(3, false))
+	assert.NoError(t, check(context.Background()))
+
+	mock.ExpectQuery(q).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(2, false))
+	assert.EqualError(t, check(context.Background()), "schema version 2, want 3")
+
+	mock.ExpectQuery(q).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(3, true))
+	assert.EqualError(t, check(context.Background()), "migration 3 is dirty")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestLoggerKey struct{}
+
+// Middleware assigns a request ID (reusing a sane incoming X-Request-ID),
+// puts a logger carrying it into the request context for zerolog.Ctx and
+// writes one access log line per request.
+func Middleware(base zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			reqID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(reqID) {
+				reqID = uuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, reqID)
+
+			ctx := base.With().Ctx(r.Context()).Str("request_id", reqID).Logger().WithContext(r.Context())
+			logger := zerolog.Ctx(ctx)
+			ctx = context.WithValue(ctx, requestLoggerKey{}, logger)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := ""
+			if rctx := chi.RouteContext(ctx); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+
+			ev := logger.Info()
+			if status >= http.StatusInternalServerError {
+				ev = logger.Error()
+			}
+			ev.Str("method", r.Method).
+				Str("route", route).
+				Str("path", r.URL.Path).
+				Int("status", status).
+				Int("bytes", ww.BytesWritten()).
+				Dur("latency", time.Since(start)).
+				Str("remote_addr", r.RemoteAddr).
+				Msg("request")
+		})
+	}
+}
+
+// AddFields attaches fields to the request logger, so that they also appear
+// on the access log line written after the handler returns.
+func AddFields(ctx context.Context, update func(zerolog.Context) zerolog.Context) {
+	if l, ok := ctx.Value(requestLoggerKey{}).(*zerolog.Logger); ok {
+		l.UpdateContext(update)
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestMiddleware_RequestScopedLogger(t *testing.T) {
+	var buf bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(logging.Middleware(zerolog.New(&buf)))
+	r.Get("/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.AddFields(r.Context(), func(c zerolog.Context) zerolog.Context {
+			return c.Str("user", "u1")
+		})
+		zerolog.Ctx(r.Context()).Info().Str("subscription_id", "42").Msg("handler")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/42", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "req-123", w.Header().Get(logging.RequestIDHeader))
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "handler", lines[0]["message"])
+	assert.Equal(t, "req-123", lines[0]["request_id"])
+	assert.Equal(t, "42", lines[0]["subscription_id"])
+
+	access := lines[1]
+	assert.Equal(t, "request", access["message"])
+	assert.Equal(t, "req-123", access["request_id"])
+	assert.Equal(t, "/subscriptions/{id}", access["route"])
+	assert.Equal(t, float64(http.StatusNotFound), access["status"])
+	assert.Equal(t, "u1", access["user"])
+	assert.Contains(t, access, "latency")
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	h := logging.Middleware(zerolog.New(&buf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, incoming := range []string{"", "has spaces", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(logging.RequestIDHeader, incoming)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		got := w.Header().Get(logging.RequestIDHeader)
+		assert.NotEmpty(t, got)
+		assert.NotEqual(t, incoming, got)
+	}
+}
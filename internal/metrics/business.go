@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"subscription-service/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/rs/zerolog"
+)
+
+// The business gauges are summed over all organizations and services, so
+// that no tenant's data leaves the tenant boundary through /metrics and
+// user-entered names never become labels.
+var (
+	activeSubscriptionsDesc = prometheus.NewDesc(
+		"subscriptions_active",
+		"Subscriptions active right now, over all organizations.",
+		nil, nil)
+	monthlySpendDesc = prometheus.NewDesc(
+		"subscriptions_monthly_spend_rubles",
+		"Monthly spend of active subscriptions, over all organizations.",
+		nil, nil)
+)
+
+// businessCollector queries the database on every scrape, so the values are
+// never stale and no background refresh is needed.
+type businessCollector struct {
+	repo    repository.StatsRepo
+	timeout time.Duration
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSubscriptionsDesc
+	ch <- monthlySpendDesc
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	s, err := c.repo.Active(ctx, time.Now().UTC())
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("collecting business metrics failed")
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(activeSubscriptionsDesc, prometheus.GaugeValue, float64(s.Active))
+	ch <- prometheus.MustNewConstMetric(monthlySpendDesc, prometheus.GaugeValue, float64(s.MonthlySpend))
+}
+
+func RegisterDB(db *sql.DB, stats repository.StatsRepo) {
+	prometheus.MustRegister(
+		collectors.NewDBStatsCollector(db, "subscriptions"),
+		&businessCollector{repo: stats, timeout: 5 * time.Second},
+	)
+}
+
+func RegisterReplicaDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "subscriptions_replica"))
+}
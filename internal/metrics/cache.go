@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_lookups_total",
+	Help: "Subscription cache lookups by repository method and result (hit, miss, error).",
+}, []string{"method", "result"})
+
+func ObserveCacheLookup(method, result string) {
+	cacheLookups.WithLabelValues(method, result).Inc()
+}
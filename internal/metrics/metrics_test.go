@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_LabelsByRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/subscriptions/{id}", "404"))
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/subscriptions/"+id, nil))
+	}
+	after := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/subscriptions/{id}", "404"))
+	assert.Equal(t, float64(2), after-before)
+}
+
+type stubRepo struct {
+	repository.SubscriptionRepo
+	err error
+}
+
+func (s stubRepo) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	return nil, s.err
+}
+
+func TestInstrumentRepo_ObservesOutcome(t *testing.T) {
+	count := func(result string) int {
+		m := &dto.Metric{}
+		_ = repoDuration.WithLabelValues("GetByID", result).(prometheus.Metric).Write(m)
+		return int(m.GetHistogram().GetSampleCount())
+	}
+
+	beforeErr, beforeNF := count("error"), count("not_found")
+	_, _ = InstrumentRepo(stubRepo{err: errors.New("boom")}).GetByID(context.Background(), "x")
+	_, _ = InstrumentRepo(stubRepo{err: repository.ErrNotFound}).GetByID(context.Background(), "x")
+	assert.Equal(t, 1, count("error")-beforeErr)
+	assert.Equal(t, 1, count("not_found")-beforeNF)
+}
+
+type fakeStats struct{ stats repository.ActiveStats }
+
+func (f fakeStats) Active(ctx context.Context, at time.Time) (repository.ActiveStats, error) {
+	return f.stats, nil
+}
+
+func TestBusinessCollector(t *testing.T) {
+	c := &businessCollector{repo: fakeStats{repository.ActiveStats{Active: 4, MonthlySpend: 1796}}, timeout: time.Second}
+	assert.Equal(t, 2, testutil.CollectAndCount(c))
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP subscriptions_active Subscriptions active right now, over all organizations.
+# TYPE subscriptions_active gauge
+subscriptions_active 4
+`), "subscriptions_active"))
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var outboxPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "outbox_publish_attempts_total",
+	Help: "Outbox event deliveries by event type and result (ok, error).",
+}, []string{"type", "result"})
+
+func ObserveOutboxPublish(eventType string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	outboxPublished.WithLabelValues(eventType, result).Inc()
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var repoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "repository_query_duration_seconds",
+	Help:    "Duration of SubscriptionRepo calls by method and outcome.",
+	Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"method", "result"})
+
+type instrumentedRepo struct {
+	next repository.SubscriptionRepo
+}
+
+func InstrumentRepo(next repository.SubscriptionRepo) repository.SubscriptionRepo {
+	return &instrumentedRepo{next: next}
+}
+
+func observe(method string, start time.Time, err error) {
+	result := "ok"
+	switch {
+	case err == repository.ErrNotFound:
+		result = "not_found"
+	case err != nil:
+		result = "error"
+	}
+	repoDuration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+func (r *instrumentedRepo) Create(ctx context.Context, s *model.Subscription) error {
+	start := time.Now()
+	err := r.next.Create(ctx, s)
+	observe("Create", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	start := time.Now()
+	s, err := r.next.GetByID(ctx, id)
+	observe("GetByID", start, err)
+	return s, err
+}
+
+func (r *instrumentedRepo) Update(ctx context.Context, s *model.Subscription) error {
+	start := time.Now()
+	err := r.next.Update(ctx, s)
+	observe("Update", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := r.next.Delete(ctx, id)
+	observe("Delete", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) List(ctx context.Context, filter repository.ListFilter) ([]*model.Subscription, error) {
+	start := time.Now()
+	out, err := r.next.List(ctx, filter)
+	observe("List", start, err)
+	return out, err
+}
+
+func (r *instrumentedRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error) {
+	start := time.Now()
+	total, err := r.next.TotalCostForPeriod(ctx, from, to, userID, serviceName)
+	observe("TotalCostForPeriod", start, err)
+	return total, err
+}
+
+func (r *instrumentedRepo) FindOverlapping(ctx context.Context, s *model.Subscription) ([]*model.Subscription, error) {
+	start := time.Now()
+	out, err := r.next.FindOverlapping(ctx, s)
+	observe("FindOverlapping", start, err)
+	return out, err
+}
+
+func (r *instrumentedRepo) SetMembers(ctx context.Context, id string, members []model.Member) error {
+	start := time.Now()
+	err := r.next.SetMembers(ctx, id, members)
+	observe("SetMembers", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	start := time.Now()
+	out, err := r.next.ListMembers(ctx, id)
+	observe("ListMembers", start, err)
+	return out, err
+}
+
+func (r *instrumentedRepo) SetTags(ctx context.Context, id string, tags []string) error {
+	start := time.Now()
+	err := r.next.SetTags(ctx, id, tags)
+	observe("SetTags", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) TagSubscriptions(ctx context.Context, ids, add, remove []string) error {
+	start := time.Now()
+	err := r.next.TagSubscriptions(ctx, ids, add, remove)
+	observe("TagSubscriptions", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) ListTags(ctx context.Context, userID *string) ([]model.Tag, error) {
+	start := time.Now()
+	out, err := r.next.ListTags(ctx, userID)
+	observe("ListTags", start, err)
+	return out, err
+}
+
+func (r *instrumentedRepo) DeleteTag(ctx context.Context, name string) error {
+	start := time.Now()
+	err := r.next.DeleteTag(ctx, name)
+	observe("DeleteTag", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) error {
+	start := time.Now()
+	err := r.next.SetPriceSchedule(ctx, id, prices)
+	observe("SetPriceSchedule", start, err)
+	return err
+}
+
+func (r *instrumentedRepo) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	start := time.Now()
+	out, err := r.next.ListPriceSchedule(ctx, id)
+	observe("ListPriceSchedule", start, err)
+	return out, err
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var webhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_delivery_attempts_total",
+	Help: "Webhook delivery attempts by event type and result (ok, error).",
+}, []string{"type", "result"})
+
+func ObserveWebhookAttempt(eventType string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	webhookAttempts.WithLabelValues(eventType, result).Inc()
+}
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_subscriptions_org_user_id;
+DROP INDEX IF EXISTS idx_subscriptions_org_service_name;
+DROP INDEX IF EXISTS idx_subscriptions_org_start_end;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions (user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name ON subscriptions (service_name);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_end ON subscriptions (start_date, end_date);
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS org_id;
@@ -0,0 +1,14 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS org_id uuid;
+
+UPDATE subscriptions SET org_id = '00000000-0000-0000-0000-000000000000' WHERE org_id IS NULL;
+
+ALTER TABLE subscriptions ALTER COLUMN org_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+DROP INDEX IF EXISTS idx_subscriptions_service_name;
+DROP INDEX IF EXISTS idx_subscriptions_start_end;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_org_user_id ON subscriptions (org_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_org_service_name ON subscriptions (org_id, service_name);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_org_start_end ON subscriptions (org_id, start_date, end_date);
+
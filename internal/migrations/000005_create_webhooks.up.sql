@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  org_id uuid NOT NULL,
+  url text NOT NULL,
+  secret text NOT NULL,
+  event_types text[] NOT NULL,
+  active boolean NOT NULL DEFAULT true,
+  consecutive_failures int NOT NULL DEFAULT 0,
+  disabled_at timestamptz,
+  created_by text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_org_id ON webhooks (org_id) WHERE active;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  webhook_id uuid NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  org_id uuid NOT NULL,
+  event_id uuid NOT NULL,
+  event_type text NOT NULL,
+  payload jsonb NOT NULL,
+  status text NOT NULL DEFAULT 'pending',
+  attempts int NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz NOT NULL DEFAULT now(),
+  last_status_code int,
+  last_error text,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  delivered_at timestamptz,
+  UNIQUE (webhook_id, event_id, event_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at DESC);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reminders_sent (
+  subscription_id uuid NOT NULL,
+  end_date date NOT NULL,
+  lead_days int NOT NULL,
+  org_id uuid NOT NULL,
+  sent_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (subscription_id, end_date, lead_days)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date ON subscriptions (end_date);
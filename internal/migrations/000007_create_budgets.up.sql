@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS budgets (
+  id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+  org_id uuid NOT NULL,
+  user_id uuid,
+  amount bigint NOT NULL CHECK (amount > 0),
+  created_by text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- One budget per user and one for the whole organization.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_user ON budgets (org_id, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_org ON budgets (org_id) WHERE user_id IS NULL;
+
+CREATE TABLE IF NOT EXISTS budget_alerts (
+  budget_id uuid NOT NULL REFERENCES budgets (id) ON DELETE CASCADE,
+  month date NOT NULL,
+  threshold int NOT NULL,
+  used bigint NOT NULL,
+  sent_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (budget_id, month, threshold)
+);
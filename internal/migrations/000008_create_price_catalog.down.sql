@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_price_changes;
+DROP TABLE IF EXISTS service_catalog;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS service_catalog (
+  org_id uuid NOT NULL,
+  service_name text NOT NULL,
+  price integer NOT NULL CHECK (price >= 0),
+  updated_by text NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Catalog entries match subscriptions by service name ignoring case.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_catalog_name ON service_catalog (org_id, lower(service_name));
+
+CREATE TABLE IF NOT EXISTS subscription_price_changes (
+  id bigserial PRIMARY KEY,
+  org_id uuid NOT NULL,
+  subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+  old_price integer NOT NULL,
+  new_price integer NOT NULL,
+  changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_price_changes_sub ON subscription_price_changes (subscription_id, changed_at);
+
+-- Seed the history from updates still kept in the outbox.
+INSERT INTO subscription_price_changes (org_id, subscription_id, old_price, new_price, changed_at)
+SELECT o.org_id, o.aggregate_id, (o.payload->'previous'->>'price')::int, (o.payload->'subscription'->>'price')::int, o.created_at
+FROM outbox o
+JOIN subscriptions s ON s.id = o.aggregate_id
+WHERE o.event_type = 'subscription.updated'
+  AND (o.payload->'previous'->>'price') <> (o.payload->'subscription'->>'price')
+ORDER BY o.id;
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS subscription_costs;
+DROP TABLE IF EXISTS subscription_members;
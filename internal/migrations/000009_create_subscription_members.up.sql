@@ -0,0 +1,29 @@
+-- Members share the cost of a subscription: a fixed monthly amount, or a
+-- percentage of what is left of the price after the fixed amounts.
+CREATE TABLE IF NOT EXISTS subscription_members (
+  subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+  user_id uuid NOT NULL,
+  share_percent numeric(5,2) CHECK (share_percent > 0 AND share_percent <= 100),
+  amount integer CHECK (amount >= 0),
+  PRIMARY KEY (subscription_id, user_id),
+  CHECK ((share_percent IS NULL) <> (amount IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_members_user_id ON subscription_members (user_id);
+
+-- subscription_costs attributes every subscription to the users who pay for
+-- it: its members, or the subscription's user when it has none. The costs
+-- of a subscription add up to its price.
+CREATE OR REPLACE VIEW subscription_costs AS
+SELECT s.id AS subscription_id, s.org_id, s.service_name, s.start_date, s.end_date,
+       COALESCE(m.user_id, s.user_id) AS user_id,
+       CASE
+         WHEN m.subscription_id IS NULL THEN s.price::numeric
+         WHEN m.amount IS NOT NULL THEN m.amount::numeric
+         ELSE GREATEST(s.price - f.fixed, 0) * m.share_percent / 100
+       END AS cost
+FROM subscriptions s
+LEFT JOIN subscription_members m ON m.subscription_id = s.id
+LEFT JOIN LATERAL (
+  SELECT COALESCE(SUM(x.amount), 0) AS fixed FROM subscription_members x WHERE x.subscription_id = s.id
+) f ON true;
@@ -0,0 +1,19 @@
+DROP VIEW IF EXISTS subscription_costs;
+CREATE VIEW subscription_costs AS
+SELECT s.id AS subscription_id, s.org_id, s.service_name, s.start_date, s.end_date,
+       COALESCE(m.user_id, s.user_id) AS user_id,
+       CASE
+         WHEN m.subscription_id IS NULL THEN s.price::numeric
+         WHEN m.amount IS NOT NULL THEN m.amount::numeric
+         ELSE GREATEST(s.price - f.fixed, 0) * m.share_percent / 100
+       END AS cost
+FROM subscriptions s
+LEFT JOIN subscription_members m ON m.subscription_id = s.id
+LEFT JOIN LATERAL (
+  SELECT COALESCE(SUM(x.amount), 0) AS fixed FROM subscription_members x WHERE x.subscription_id = s.id
+) f ON true;
+
+DROP TABLE IF EXISTS subscription_tags;
+DROP TABLE IF EXISTS tags;
+DROP INDEX IF EXISTS idx_subscriptions_cost_center;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS cost_center;
@@ -0,0 +1,36 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cost_center text;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_cost_center ON subscriptions (org_id, cost_center);
+
+-- Tags are stored lower-cased, so names are unique ignoring case.
+CREATE TABLE IF NOT EXISTS tags (
+  id bigserial PRIMARY KEY,
+  org_id uuid NOT NULL,
+  name text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  UNIQUE (org_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS subscription_tags (
+  subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+  tag_id bigint NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+  PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag_id ON subscription_tags (tag_id);
+
+-- Allocation reports group the costs by cost center.
+CREATE OR REPLACE VIEW subscription_costs AS
+SELECT s.id AS subscription_id, s.org_id, s.service_name, s.start_date, s.end_date,
+       COALESCE(m.user_id, s.user_id) AS user_id,
+       CASE
+         WHEN m.subscription_id IS NULL THEN s.price::numeric
+         WHEN m.amount IS NOT NULL THEN m.amount::numeric
+         ELSE GREATEST(s.price - f.fixed, 0) * m.share_percent / 100
+       END AS cost,
+       s.cost_center
+FROM subscriptions s
+LEFT JOIN subscription_members m ON m.subscription_id = s.id
+LEFT JOIN LATERAL (
+  SELECT COALESCE(SUM(x.amount), 0) AS fixed FROM subscription_members x WHERE x.subscription_id = s.id
+) f ON true;
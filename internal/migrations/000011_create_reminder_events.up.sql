@@ -0,0 +1,15 @@
+-- reminder_events records which subscription.ending_soon events were
+-- emitted. Unlike reminders_sent it survives a released claim, so a reminder
+-- whose notification is retried emits its event only once.
+CREATE TABLE IF NOT EXISTS reminder_events (
+  subscription_id uuid NOT NULL,
+  end_date date NOT NULL,
+  lead_days int NOT NULL,
+  org_id uuid NOT NULL,
+  emitted_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (subscription_id, end_date, lead_days)
+);
+
+INSERT INTO reminder_events (subscription_id, end_date, lead_days, org_id, emitted_at)
+SELECT subscription_id, end_date, lead_days, org_id, sent_at FROM reminders_sent
+ON CONFLICT DO NOTHING;
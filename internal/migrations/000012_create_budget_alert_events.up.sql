@@ -0,0 +1,14 @@
+-- budget_alert_events records which budget.threshold_crossed events were
+-- emitted. Unlike budget_alerts it survives a released claim, so an alert
+-- whose notification is retried emits its event only once.
+CREATE TABLE IF NOT EXISTS budget_alert_events (
+  budget_id uuid NOT NULL REFERENCES budgets (id) ON DELETE CASCADE,
+  month date NOT NULL,
+  threshold int NOT NULL,
+  emitted_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (budget_id, month, threshold)
+);
+
+INSERT INTO budget_alert_events (budget_id, month, threshold, emitted_at)
+SELECT budget_id, month, threshold, sent_at FROM budget_alerts
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,36 @@
+-- The price is charged once every billing_period months.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_period int NOT NULL DEFAULT 1
+  CHECK (billing_period IN (1, 3, 6, 12));
+
+-- Future prices of subscriptions. A price is applied to its subscription
+-- when its month begins and then removed from the schedule.
+CREATE TABLE IF NOT EXISTS price_schedule (
+  subscription_id uuid NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+  effective_from date NOT NULL,
+  price integer NOT NULL CHECK (price >= 0),
+  created_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (subscription_id, effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_schedule_effective_from ON price_schedule (effective_from);
+
+-- Costs are monthly: the price of a longer billing period is spread evenly
+-- over its months. The parts of the cost are exposed so that forecasts can
+-- compute it for a scheduled price; cost equals the repository's costAt
+-- applied to price.
+CREATE OR REPLACE VIEW subscription_costs AS
+SELECT s.id AS subscription_id, s.org_id, s.service_name, s.start_date, s.end_date,
+       COALESCE(m.user_id, s.user_id) AS user_id,
+       COALESCE(m.amount::numeric, GREATEST(s.price - f.fixed, 0) * COALESCE(m.share_percent, 100) / 100)
+         / s.billing_period AS cost,
+       s.cost_center,
+       s.price,
+       m.amount,
+       COALESCE(m.share_percent, 100) AS share_percent,
+       f.fixed,
+       s.billing_period
+FROM subscriptions s
+LEFT JOIN subscription_members m ON m.subscription_id = s.id
+LEFT JOIN LATERAL (
+  SELECT COALESCE(SUM(x.amount), 0) AS fixed FROM subscription_members x WHERE x.subscription_id = s.id
+) f ON true;
@@ -0,0 +1,3 @@
+-- Events are published outside the transaction that claims them; the lease
+-- keeps other relays off them meanwhile and expires if the relay dies.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS leased_until timestamptz;
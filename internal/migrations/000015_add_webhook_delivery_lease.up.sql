@@ -0,0 +1,4 @@
+-- Deliveries are sent outside the transaction that claims them; the lease
+-- keeps other dispatchers off them meanwhile and expires if the dispatcher
+-- dies.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS leased_until timestamptz;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_service_catalog_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_catalog_name ON service_catalog (org_id, lower(service_name));
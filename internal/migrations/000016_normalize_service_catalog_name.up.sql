@@ -0,0 +1,12 @@
+-- Catalog entries match subscriptions by service name ignoring case and
+-- surrounding whitespace; keep the latest entry of names that now collide.
+DELETE FROM service_catalog c
+USING service_catalog d
+WHERE d.org_id = c.org_id
+  AND lower(trim(d.service_name)) = lower(trim(c.service_name))
+  AND (d.updated_at, d.ctid) > (c.updated_at, c.ctid);
+
+UPDATE service_catalog SET service_name = trim(service_name) WHERE service_name <> trim(service_name);
+
+DROP INDEX IF EXISTS idx_service_catalog_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_catalog_name ON service_catalog (org_id, lower(trim(service_name)));
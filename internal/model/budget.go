@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// Budget caps the monthly spend of one user, or of the whole organization
+// when UserID is nil.
+type Budget struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id"`
+	UserID    *string   `json:"user_id,omitempty"`
+	Amount    int64     `json:"amount"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BudgetStatus compares a budget with the projected spend of a month.
+// Remaining is negative once the budget is overspent.
+type BudgetStatus struct {
+	BudgetID  string    `json:"budget_id"`
+	Month     time.Time `json:"month"`
+	Amount    int64     `json:"amount"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Percent   int64     `json:"percent"`
+}
+
+func (b *Budget) Status(month time.Time, used int64) *BudgetStatus {
+	return &BudgetStatus{
+		BudgetID:  b.ID,
+		Month:     month,
+		Amount:    b.Amount,
+		Used:      used,
+		Remaining: b.Amount - used,
+		Percent:   used * 100 / b.Amount,
+	}
+}
+
+// BudgetAlert is the Data of budget.threshold_crossed events: Used reached
+// Threshold percent of the budget in Month.
+type BudgetAlert struct {
+	Budget    *Budget   `json:"budget"`
+	Month     time.Time `json:"month"`
+	Threshold int       `json:"threshold"`
+	Used      int64     `json:"used"`
+}
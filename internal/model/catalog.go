@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// CatalogEntry is the negotiated or list price of a service in an
+// organization. It matches subscriptions by service name ignoring case.
+type CatalogEntry struct {
+	ServiceName string    `json:"service_name"`
+	Price       int       `json:"price"`
+	UpdatedBy   string    `json:"updated_by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
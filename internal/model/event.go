@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	EventSubscriptionCreated = "subscription.created"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionDeleted = "subscription.deleted"
+	// EventSubscriptionEndingSoon is emitted by the reminder scheduler.
+	EventSubscriptionEndingSoon = "subscription.ending_soon"
+	// EventBudgetThresholdCrossed is emitted by the budget evaluator.
+	EventBudgetThresholdCrossed = "budget.threshold_crossed"
+)
+
+// Event is a domain event as stored in the outbox and delivered to sinks.
+// Delivery is at-least-once, so consumers should deduplicate by ID.
+type Event struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	OrgID          string          `json:"org_id"`
+	SubscriptionID string          `json:"subscription_id,omitempty"`
+	BudgetID       string          `json:"budget_id,omitempty"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Data           json.RawMessage `json:"data"`
+}
+
+// SetAggregateID sets the ID of the entity the event is about.
+func (e *Event) SetAggregateID(id string) {
+	if strings.HasPrefix(e.Type, "budget.") {
+		e.BudgetID = id
+	} else {
+		e.SubscriptionID = id
+	}
+}
+
+// SubscriptionChange is the Data of subscription events. Previous is set for
+// updates; for deletions Subscription is the removed row.
+type SubscriptionChange struct {
+	Subscription *Subscription `json:"subscription"`
+	Previous     *Subscription `json:"previous,omitempty"`
+}
@@ -0,0 +1,179 @@
+package model
+
+import "time"
+
+// Forecast is the projected spend of the coming months, starting with the
+// current one.
+type Forecast struct {
+	Months []ForecastMonth `json:"months"`
+	Total  int64           `json:"total"`
+}
+
+type ForecastMonth struct {
+	Month     time.Time     `json:"month"`
+	Total     int64         `json:"total"`
+	ByService []ServiceCost `json:"by_service"`
+	ByUser    []UserCost    `json:"by_user"`
+}
+
+type ServiceCost struct {
+	ServiceName string `json:"service_name"`
+	Total       int64  `json:"total"`
+}
+
+type UserCost struct {
+	UserID string `json:"user_id"`
+	Total  int64  `json:"total"`
+}
+
+// Overlap is a pair of subscriptions of the same user and service that are
+// active at the same time, From through To (nil if both are open-ended).
+type Overlap struct {
+	UserID          string     `json:"user_id"`
+	ServiceName     string     `json:"service_name"`
+	SubscriptionIDs []string   `json:"subscription_ids"`
+	From            time.Time  `json:"from"`
+	To              *time.Time `json:"to,omitempty"`
+}
+
+// Reasons a subscription is reported as a price anomaly.
+const (
+	AnomalyAboveCatalog  = "above_catalog"
+	AnomalyAbovePeers    = "above_peers"
+	AnomalyPriceIncrease = "price_increase"
+)
+
+// PriceAnomaly is an active subscription that costs more than the catalog
+// or than other users pay for the same service, or whose price was raised
+// recently. Prices are compared per month: the percentages are how much
+// MonthlyPrice, Price spread over the billing period, exceeds the reference.
+type PriceAnomaly struct {
+	SubscriptionID     string       `json:"subscription_id"`
+	UserID             string       `json:"user_id"`
+	ServiceName        string       `json:"service_name"`
+	Price              int          `json:"price"`
+	MonthlyPrice       int          `json:"monthly_price"`
+	CatalogPrice       *int         `json:"catalog_price,omitempty"`
+	OverCatalogPercent *int64       `json:"over_catalog_percent,omitempty"`
+	PeerMedian         *int         `json:"peer_median,omitempty"`
+	PeerCount          int          `json:"peer_count,omitempty"`
+	OverPeersPercent   *int64       `json:"over_peers_percent,omitempty"`
+	LastIncrease       *PriceChange `json:"last_increase,omitempty"`
+	Reasons            []string     `json:"reasons"`
+}
+
+type PriceChange struct {
+	OldPrice  int       `json:"old_price"`
+	NewPrice  int       `json:"new_price"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// TopReport ranks users and services by spend in From..To and compares it
+// with the period of the same length just before, PreviousFrom..PreviousTo.
+type TopReport struct {
+	From          time.Time    `json:"from"`
+	To            time.Time    `json:"to"`
+	PreviousFrom  time.Time    `json:"previous_from"`
+	PreviousTo    time.Time    `json:"previous_to"`
+	Total         int64        `json:"total"`
+	PreviousTotal int64        `json:"previous_total"`
+	Users         []TopUser    `json:"users"`
+	Services      []TopService `json:"services"`
+}
+
+type TopUser struct {
+	UserID string `json:"user_id"`
+	SpendStats
+}
+
+type TopService struct {
+	ServiceName string `json:"service_name"`
+	SpendStats
+}
+
+// SpendStats describes the spend of one user or service. Count is the
+// number of subscriptions active in the period; ChangePercent is nil when
+// there was no spend in the previous period.
+type SpendStats struct {
+	Total         int64    `json:"total"`
+	Count         int      `json:"count"`
+	AveragePrice  int64    `json:"average_price"`
+	SharePercent  float64  `json:"share_percent"`
+	PreviousTotal int64    `json:"previous_total"`
+	Change        int64    `json:"change"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+}
+
+// Groupings of the allocation report.
+const (
+	GroupByCostCenter = "cost_center"
+	GroupByTag        = "tag"
+)
+
+// AllocationReport splits the spend in From..To by cost center or tag and
+// compares it with the period of the same length just before. Spend
+// without a cost center or tag is in the group with an empty Name. With
+// GroupByTag a subscription counts towards each of its tags, so the groups
+// may add up to more than Total.
+type AllocationReport struct {
+	From          time.Time         `json:"from"`
+	To            time.Time         `json:"to"`
+	PreviousFrom  time.Time         `json:"previous_from"`
+	PreviousTo    time.Time         `json:"previous_to"`
+	GroupBy       string            `json:"group_by"`
+	Total         int64             `json:"total"`
+	PreviousTotal int64             `json:"previous_total"`
+	Groups        []AllocationGroup `json:"groups"`
+}
+
+type AllocationGroup struct {
+	Name string `json:"name"`
+	SpendStats
+}
+
+// ServiceChurn describes how subscriptions to one service start and end
+// over a range of months. ChurnRate is Cancelled in percent of the
+// subscriptions active at the start of the range plus New; it and
+// AvgLifetimeDays are nil when undefined.
+type ServiceChurn struct {
+	ServiceName     string       `json:"service_name"`
+	New             int          `json:"new"`
+	Cancelled       int          `json:"cancelled"`
+	Net             int          `json:"net"`
+	ChurnRate       *float64     `json:"churn_rate,omitempty"`
+	AvgLifetimeDays *float64     `json:"avg_lifetime_days,omitempty"`
+	Months          []ChurnMonth `json:"months"`
+}
+
+// ChurnMonth counts the subscriptions active on the first day of Month,
+// started in it and ended in it (end_date is the last paid day). ChurnRate
+// is Cancelled in percent of ActiveAtStart plus New.
+type ChurnMonth struct {
+	Month           time.Time `json:"month"`
+	ActiveAtStart   int       `json:"active_at_start"`
+	New             int       `json:"new"`
+	Cancelled       int       `json:"cancelled"`
+	Net             int       `json:"net"`
+	ChurnRate       *float64  `json:"churn_rate,omitempty"`
+	AvgLifetimeDays *float64  `json:"avg_lifetime_days,omitempty"`
+}
+
+// UserSummary is the dashboard of one user as of AsOf. MonthlySpend and
+// Last12Months are computed like the forecast, the current month last, but
+// past months are priced from the price history;
+// UpcomingRenewals are active subscriptions whose end_date is within the
+// next 30 days.
+type UserSummary struct {
+	UserID              string          `json:"user_id"`
+	AsOf                time.Time       `json:"as_of"`
+	MonthlySpend        int64           `json:"monthly_spend"`
+	ActiveSubscriptions []*Subscription `json:"active_subscriptions"`
+	UpcomingRenewals    []*Subscription `json:"upcoming_renewals"`
+	Last12Months        []MonthSpend    `json:"last_12_months"`
+	TopServices         []ServiceCost   `json:"top_services"`
+}
+
+type MonthSpend struct {
+	Month time.Time `json:"month"`
+	Total int64     `json:"total"`
+}
@@ -4,6 +4,7 @@ import "time"
 
 type Subscription struct {
 	ID          string     `json:"id"`
+	OrgID       string     `json:"org_id"`
 	ServiceName string     `json:"service_name"`
 	Price       int        `json:"price"`
 	UserID      string     `json:"user_id"`
@@ -11,4 +12,40 @@ type Subscription struct {
 	EndDate     *time.Time `json:"end_date,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+	// BillingPeriod is the number of months the price is charged for, one
+	// of BillingPeriods. Costs spread the price evenly over them.
+	BillingPeriod int `json:"billing_period"`
+	// CostCenter is the team or department the subscription is charged to.
+	CostCenter *string `json:"cost_center,omitempty"`
+	// Tags are loaded by GetByID and List only.
+	Tags []string `json:"tags,omitempty"`
+	// Warnings are returned with a created or updated subscription and are
+	// not stored.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// BillingPeriods are the supported billing periods in months.
+var BillingPeriods = []int{1, 3, 6, 12}
+
+// ScheduledPrice is a future price of a subscription, applied when the
+// month starting at EffectiveFrom begins.
+type ScheduledPrice struct {
+	Price         int       `json:"price"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// Member shares the cost of a subscription: a fixed Amount per billing
+// period, or SharePercent of what is left of the price after the fixed
+// amounts. A subscription without members is paid entirely by its user.
+type Member struct {
+	UserID       string   `json:"user_id"`
+	SharePercent *float64 `json:"share_percent,omitempty"`
+	Amount       *int     `json:"amount,omitempty"`
+}
+
+// Tag labels subscriptions of an organization. Subscriptions counts the
+// tagged subscriptions visible to the caller.
+type Tag struct {
+	Name          string `json:"name"`
+	Subscriptions int    `json:"subscriptions"`
 }
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types. Created, updated and deleted mirror the outbox events;
+// renewed and price_changed are derived from updates.
+const (
+	WebhookSubscriptionCreated      = "subscription.created"
+	WebhookSubscriptionUpdated      = "subscription.updated"
+	WebhookSubscriptionDeleted      = "subscription.deleted"
+	WebhookSubscriptionRenewed      = "subscription.renewed"
+	WebhookSubscriptionPriceChanged = "subscription.price_changed"
+	WebhookSubscriptionEndingSoon   = "subscription.ending_soon"
+	WebhookBudgetThresholdCrossed   = "budget.threshold_crossed"
+)
+
+var WebhookEventTypes = []string{
+	WebhookSubscriptionCreated,
+	WebhookSubscriptionUpdated,
+	WebhookSubscriptionDeleted,
+	WebhookSubscriptionRenewed,
+	WebhookSubscriptionPriceChanged,
+	WebhookSubscriptionEndingSoon,
+	WebhookBudgetThresholdCrossed,
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID                  string     `json:"id"`
+	OrgID               string     `json:"org_id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"-"`
+	EventTypes          []string   `json:"event_types"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedBy           string     `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// Message is addressed to a user of an organization; notifiers resolve how
+// to reach them.
+type Message struct {
+	OrgID   string
+	UserID  string
+	Subject string
+	Body    string
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// LogNotifier writes messages to the context logger instead of sending them.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, m Message) error {
+	zerolog.Ctx(ctx).Info().
+		Str("org_id", m.OrgID).
+		Str("user_id", m.UserID).
+		Str("subject", m.Subject).
+		Str("body", m.Body).
+		Msg("notification")
+	return nil
+}
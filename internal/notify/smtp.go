@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type SMTPConfig struct {
+	Addr     string // host:port
+	Username string // PLAIN auth is used when set
+	Password string
+	From     string
+	// Recipient is a text/template executed with the Message that yields the
+	// recipient address, e.g. "{{.UserID}}@users.example.com".
+	Recipient string
+	// Timeout bounds a whole delivery, from dialing to QUIT, unless the
+	// context ends earlier.
+	Timeout time.Duration
+}
+
+type SMTPNotifier struct {
+	cfg       SMTPConfig
+	recipient *template.Template
+	now       func() time.Time
+}
+
+func NewSMTPNotifier(cfg SMTPConfig) (*SMTPNotifier, error) {
+	tmpl, err := template.New("recipient").Option("missingkey=error").Parse(cfg.Recipient)
+	if err != nil {
+		return nil, fmt.Errorf("recipient template: %w", err)
+	}
+	return &SMTPNotifier{cfg: cfg, recipient: tmpl, now: time.Now}, nil
+}
+
+// Notify sends m like smtp.SendMail does, STARTTLS included, but gives up
+// when ctx ends or Timeout passes, so a hung server cannot block the caller.
+func (n *SMTPNotifier) Notify(ctx context.Context, m Message) error {
+	var to bytes.Buffer
+	if err := n.recipient.Execute(&to, m); err != nil {
+		return err
+	}
+	rcpt := strings.TrimSpace(to.String())
+	if rcpt == "" || strings.ContainsAny(rcpt, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", rcpt)
+	}
+
+	if n.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.cfg.Timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	// Cancellation without a deadline interrupts blocked reads and writes.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	err = n.send(conn, rcpt, n.message(rcpt, m))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return errors.Join(ctxErr, err)
+	}
+	return err
+}
+
+func (n *SMTPNotifier) send(conn net.Conn, rcpt string, msg []byte) error {
+	host, _, _ := net.SplitHostPort(n.cfg.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.cfg.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.cfg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (n *SMTPNotifier) message(rcpt string, m Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", rcpt)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", n.now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
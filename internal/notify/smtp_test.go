@@ -0,0 +1,156 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-service/internal/notify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mail struct {
+	from, to, data string
+}
+
+// fakeSMTP accepts one client without auth and records the mail it sends.
+func fakeSMTP(t *testing.T) (addr string, mails chan mail) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	mails = make(chan mail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 test ESMTP")
+		var m mail
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch upper := strings.ToUpper(cmd); {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 test")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				m.from = strings.Trim(cmd[len("MAIL FROM:"):], "<>")
+				reply("250 ok")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				m.to = strings.Trim(cmd[len("RCPT TO:"):], "<>")
+				reply("250 ok")
+			case upper == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				m.data = b.String()
+				mails <- m
+				reply("250 queued")
+			case upper == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, mails := fakeSMTP(t)
+	n, err := notify.NewSMTPNotifier(notify.SMTPConfig{
+		Addr:      addr,
+		From:      "billing@example.com",
+		Recipient: "{{.UserID}}@users.example.com",
+	})
+	require.NoError(t, err)
+
+	err = n.Notify(context.Background(), notify.Message{
+		OrgID:   "org",
+		UserID:  "u1",
+		Subject: "Подписка заканчивается",
+		Body:    "line one\nline two",
+	})
+	require.NoError(t, err)
+
+	m := <-mails
+	assert.Equal(t, "billing@example.com", m.from)
+	assert.Equal(t, "u1@users.example.com", m.to)
+	assert.Contains(t, m.data, "To: u1@users.example.com\r\n")
+	assert.Contains(t, m.data, "Subject: =?utf-8?q?")
+	assert.Contains(t, m.data, "line one\r\nline two\r\n")
+}
+
+func TestSMTPNotifier_InvalidRecipient(t *testing.T) {
+	_, err := notify.NewSMTPNotifier(notify.SMTPConfig{Recipient: "{{.Missing"})
+	assert.Error(t, err)
+
+	n, err := notify.NewSMTPNotifier(notify.SMTPConfig{Addr: "127.0.0.1:1", Recipient: "{{.UserID}}"})
+	require.NoError(t, err)
+	err = n.Notify(context.Background(), notify.Message{UserID: "a@b.c\r\nBcc: x@y.z"})
+	assert.ErrorContains(t, err, "invalid recipient")
+}
+
+// hungSMTP accepts connections and never answers.
+func hungSMTP(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	conns := make(chan net.Conn, 8)
+	t.Cleanup(func() {
+		ln.Close()
+		for len(conns) > 0 {
+			(<-conns).Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSMTPNotifier_HungServer(t *testing.T) {
+	addr := hungSMTP(t)
+	msg := notify.Message{UserID: "u1", Subject: "s", Body: "b"}
+
+	n, err := notify.NewSMTPNotifier(notify.SMTPConfig{Addr: addr, From: "billing@example.com", Recipient: "{{.UserID}}@example.com", Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	start := time.Now()
+	err = n.Notify(context.Background(), msg)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	n, err = notify.NewSMTPNotifier(notify.SMTPConfig{Addr: addr, From: "billing@example.com", Recipient: "{{.UserID}}@example.com"})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err = n.Notify(ctx, msg)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,30 @@
+package outbox_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSink(t *testing.T) {
+	status := http.StatusAccepted
+	var gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("X-Event-Type")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := outbox.NewHTTPSink(srv.URL, time.Second)
+	assert.NoError(t, sink.Publish(context.Background(), testEvent()))
+	assert.Equal(t, "subscription.created", gotType)
+
+	status = http.StatusServiceUnavailable
+	assert.ErrorContains(t, sink.Publish(context.Background(), testEvent()), "status 503")
+}
@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"subscription-service/internal/model"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSink produces events to one topic, keyed by the subscription or
+// budget they are about so that its events share a partition. A publish
+// counts as delivered once all in-sync replicas acknowledged it.
+type KafkaSink struct {
+	w *kafka.Writer
+}
+
+func NewKafkaSink(brokers []string, topic string, timeout time.Duration) (*KafkaSink, error) {
+	if len(brokers) == 0 || topic == "" {
+		return nil, errors.New("kafka sink: brokers and topic are required")
+	}
+	return &KafkaSink{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// The relay publishes one event at a time and retries on its own.
+		BatchSize:    1,
+		MaxAttempts:  1,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	}}, nil
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, e *model.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	key := e.SubscriptionID
+	if key == "" {
+		key = e.BudgetID
+	}
+	err = s.w.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(key),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "event_id", Value: []byte(e.ID)},
+			{Key: "event_type", Value: []byte(e.Type)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("kafka %s: %w", s.w.Topic, err)
+	}
+	return nil
+}
+
+func (s *KafkaSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,33 @@
+package outbox_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"subscription-service/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaSink_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sink, err := outbox.NewKafkaSink([]string{addr}, "subscriptions", time.Second)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Error(t, sink.Publish(ctx, testEvent()))
+
+	_, err = outbox.NewKafkaSink(nil, "subscriptions", time.Second)
+	assert.Error(t, err)
+	_, err = outbox.NewKafkaSink([]string{addr}, "", time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"subscription-service/internal/model"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSSink publishes events to core NATS on "<prefix>.<event type>". A
+// publish counts as delivered once the server answered the flush that follows
+// it; core NATS does not persist messages, use a JetStream stream on the
+// subject for that.
+type NATSSink struct {
+	nc      *nats.Conn
+	prefix  string
+	timeout time.Duration
+}
+
+// NewNATSSink connects to rawURL in the background and keeps reconnecting, so
+// an unreachable server fails publishes rather than startup.
+func NewNATSSink(rawURL, subjectPrefix string, timeout time.Duration) (*NATSSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("nats url %q: want nats://host[:port]", rawURL)
+	}
+	nc, err := nats.Connect(rawURL,
+		nats.Name("subscription-service"),
+		nats.Timeout(timeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		// Fail publishes while disconnected instead of buffering them; the
+		// relay retries.
+		nats.ReconnectBufSize(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("nats %s: %w", u.Redacted(), err)
+	}
+	return &NATSSink{nc: nc, prefix: subjectPrefix, timeout: timeout}, nil
+}
+
+func (s *NATSSink) Publish(ctx context.Context, e *model.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := s.nc.Publish(s.prefix+"."+e.Type, payload); err != nil {
+		return fmt.Errorf("nats publish: %w", err)
+	}
+	if err := s.nc.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("nats flush: %w", err)
+	}
+	return nil
+}
+
+func (s *NATSSink) Close() error {
+	s.nc.Close()
+	return nil
+}
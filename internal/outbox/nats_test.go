@@ -0,0 +1,75 @@
+package outbox_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-service/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNATS accepts one client, records the subjects it publishes to and
+// answers every PING.
+func fakeNATS(t *testing.T) (addr string, subjects chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	subjects = make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 3 && fields[0] == "PUB":
+				subjects <- fields[1]
+				_, _ = r.ReadString('\n') // payload
+			case len(fields) == 1 && fields[0] == "PING":
+				_, _ = conn.Write([]byte("PONG\r\n"))
+			}
+		}
+	}()
+	return ln.Addr().String(), subjects
+}
+
+func TestNATSSink(t *testing.T) {
+	addr, subjects := fakeNATS(t)
+	sink, err := outbox.NewNATSSink("nats://"+addr, "subscriptions", time.Second)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	require.NoError(t, sink.Publish(context.Background(), testEvent()))
+	require.NoError(t, sink.Publish(context.Background(), testEvent()))
+	assert.Equal(t, "subscriptions.subscription.created", <-subjects)
+	assert.Equal(t, "subscriptions.subscription.created", <-subjects)
+}
+
+func TestNATSSink_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sink, err := outbox.NewNATSSink("nats://"+addr, "subscriptions", time.Second)
+	require.NoError(t, err)
+	assert.Error(t, sink.Publish(context.Background(), testEvent()))
+
+	_, err = outbox.NewNATSSink("http://"+addr, "subscriptions", time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	// Lease is how long a batch is reserved for this relay while it is
+	// published; it must outlast publishing a whole batch.
+	Lease time.Duration
+	// Retention is how long published events are kept; 0 keeps them forever.
+	Retention time.Duration
+}
+
+// Relay moves committed outbox events to a Sink. Events are marked published
+// only after the sink accepted them, outside of the transaction that leased
+// them, so delivery is at-least-once; failed
+// events are retried with exponential backoff without blocking later ones.
+type Relay struct {
+	repo repository.OutboxRepo
+	sink Sink
+	cfg  Config
+}
+
+func NewRelay(repo repository.OutboxRepo, sink Sink, cfg Config) *Relay {
+	return &Relay{repo: repo, sink: sink, cfg: cfg}
+}
+
+// Run drains the outbox every PollInterval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		if err := r.Drain(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("outbox relay failed")
+		}
+		if r.cfg.Retention > 0 && time.Since(lastPurge) > time.Hour {
+			lastPurge = time.Now()
+			n, err := r.repo.DeletePublished(ctx, time.Now().Add(-r.cfg.Retention))
+			if err != nil {
+				log.Error().Err(err).Msg("outbox purge failed")
+			} else if n > 0 {
+				log.Info().Int64("deleted", n).Msg("outbox purged")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain publishes due events until fewer than a full batch is left.
+func (r *Relay) Drain(ctx context.Context) error {
+	for {
+		n, err := r.repo.ProcessBatch(ctx, r.cfg.BatchSize, r.cfg.Lease, r.publish, r.retryIn)
+		if err != nil || n < r.cfg.BatchSize {
+			return err
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, e *model.Event) error {
+	err := r.sink.Publish(ctx, e)
+	metrics.ObserveOutboxPublish(e.Type, err)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Str("event_id", e.ID).Str("event_type", e.Type).Msg("outbox event not delivered, will retry")
+	}
+	return err
+}
+
+func (r *Relay) retryIn(attempts int) time.Duration {
+	d := r.cfg.RetryBase
+	for i := 1; i < attempts && d < r.cfg.RetryMax; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.RetryMax)
+}
@@ -0,0 +1,98 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/outbox"
+	"subscription-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memOutbox mimics the outbox table: events are due until published and
+// failed ones are rescheduled.
+type memOutbox struct {
+	events    []*model.Event
+	published map[string]bool
+	due       map[string]time.Time
+	retries   []time.Duration
+	attempts  map[string]int
+}
+
+func newMemOutbox(n int) *memOutbox {
+	m := &memOutbox{published: map[string]bool{}, due: map[string]time.Time{}, attempts: map[string]int{}}
+	for i := 0; i < n; i++ {
+		e := testEvent()
+		e.ID = string(rune('a' + i))
+		m.events = append(m.events, e)
+	}
+	return m
+}
+
+func (m *memOutbox) ProcessBatch(ctx context.Context, limit int, _ time.Duration, publish repository.PublishFunc, retryIn func(int) time.Duration) (int, error) {
+	n := 0
+	for _, e := range m.events {
+		if n == limit {
+			break
+		}
+		if m.published[e.ID] || m.due[e.ID].After(time.Now()) {
+			continue
+		}
+		m.attempts[e.ID]++
+		if err := publish(ctx, e); err != nil {
+			d := retryIn(m.attempts[e.ID])
+			m.retries = append(m.retries, d)
+			m.due[e.ID] = time.Now().Add(d)
+		} else {
+			m.published[e.ID] = true
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (m *memOutbox) DeletePublished(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+type flakySink struct {
+	failures int
+	got      []string
+}
+
+func (s *flakySink) Publish(_ context.Context, e *model.Event) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("unavailable")
+	}
+	s.got = append(s.got, e.ID)
+	return nil
+}
+
+func TestRelay_DrainsInBatches(t *testing.T) {
+	repo := newMemOutbox(5)
+	sink := &flakySink{}
+	relay := outbox.NewRelay(repo, sink, outbox.Config{BatchSize: 2, RetryBase: time.Second, RetryMax: time.Minute})
+
+	require.NoError(t, relay.Drain(context.Background()))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, sink.got)
+}
+
+func TestRelay_RetriesWithBackoff(t *testing.T) {
+	repo := newMemOutbox(1)
+	sink := &flakySink{failures: 1}
+	relay := outbox.NewRelay(repo, sink, outbox.Config{BatchSize: 10, RetryBase: time.Second, RetryMax: time.Minute})
+
+	require.NoError(t, relay.Drain(context.Background()))
+	assert.Empty(t, sink.got, "failed events wait for their next attempt")
+	assert.Equal(t, []time.Duration{time.Second}, repo.retries)
+
+	repo.due["a"] = time.Time{}
+	require.NoError(t, relay.Drain(context.Background()))
+	assert.Equal(t, []string{"a"}, sink.got)
+}
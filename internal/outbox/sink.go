@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"subscription-service/internal/model"
+)
+
+// Sink delivers events to consumers. Publish must return an error unless the
+// event was handed over durably enough that it need not be sent again.
+type Sink interface {
+	Publish(ctx context.Context, e *model.Event) error
+}
+
+type multiSink []Sink
+
+// Multi publishes every event to all sinks. A failure in any of them retries
+// the event everywhere, so sinks see duplicates and must tolerate them.
+func Multi(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+func (m multiSink) Publish(ctx context.Context, e *model.Event) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Publish(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriterSink writes events as JSON lines, e.g. to stdout or a file.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func OpenFileSink(path string) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterSink{w: f, c: f}, nil
+}
+
+func (s *WriterSink) Publish(_ context.Context, e *model.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+func (s *WriterSink) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
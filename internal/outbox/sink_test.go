@@ -0,0 +1,60 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	events []*model.Event
+	err    error
+}
+
+func (s *recordingSink) Publish(_ context.Context, e *model.Event) error {
+	s.events = append(s.events, e)
+	return s.err
+}
+
+func testEvent() *model.Event {
+	return &model.Event{
+		ID:             "9b2f6a1e-4c55-4a6a-9d0b-2f4b6f0e8c11",
+		Type:           model.EventSubscriptionCreated,
+		OrgID:          "00000000-0000-0000-0000-00000000000a",
+		SubscriptionID: "s1",
+		Data:           json.RawMessage(`{"subscription":{"id":"s1"}}`),
+	}
+}
+
+func TestWriterSink_JSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	sink := outbox.NewWriterSink(&buf)
+
+	require.NoError(t, sink.Publish(context.Background(), testEvent()))
+	require.NoError(t, sink.Publish(context.Background(), testEvent()))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var got model.Event
+	require.NoError(t, json.Unmarshal(lines[0], &got))
+	assert.Equal(t, "subscription.created", got.Type)
+	assert.JSONEq(t, `{"subscription":{"id":"s1"}}`, string(got.Data))
+}
+
+func TestMulti_PublishesEverywhere(t *testing.T) {
+	ok := &recordingSink{}
+	failing := &recordingSink{err: errors.New("down")}
+
+	err := outbox.Multi(ok, failing).Publish(context.Background(), testEvent())
+	assert.Error(t, err, "one failing sink retries the event")
+	assert.Len(t, ok.events, 1)
+	assert.Len(t, failing.events, 1)
+}
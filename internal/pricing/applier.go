@@ -0,0 +1,78 @@
+package pricing
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/repository"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Applier makes scheduled prices the current price of their subscription
+// once their month has begun.
+type Applier struct {
+	repo repository.PriceScheduleRepo
+	cfg  Config
+	now  func() time.Time
+}
+
+func NewApplier(repo repository.PriceScheduleRepo, cfg Config) *Applier {
+	return NewApplierWithClock(repo, cfg, time.Now)
+}
+
+func NewApplierWithClock(repo repository.PriceScheduleRepo, cfg Config, now func() time.Time) *Applier {
+	return &Applier{repo: repo, cfg: cfg, now: now}
+}
+
+func (a *Applier) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("price schedule run failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies all prices that are due today. A price that cannot be
+// applied stays scheduled and is retried by the next run; the run goes on
+// past it, so failing prices never hold back the ones behind them.
+func (a *Applier) RunOnce(ctx context.Context) error {
+	log := zerolog.Ctx(ctx)
+	now := a.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var after *repository.DuePrice
+	for {
+		due, err := a.repo.Due(ctx, today, after, a.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, d := range due {
+			if err := a.repo.Apply(ctx, d, now); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Warn().Err(err).Str("subscription_id", d.SubscriptionID).
+					Str("effective_from", d.EffectiveFrom.Format("01-2006")).Msg("scheduled price not applied, will retry")
+				continue
+			}
+			log.Info().Str("subscription_id", d.SubscriptionID).Int("price", d.Price).Msg("scheduled price applied")
+		}
+		if len(due) < a.cfg.BatchSize {
+			return nil
+		}
+		after = &due[len(due)-1]
+	}
+}
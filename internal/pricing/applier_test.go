@@ -0,0 +1,115 @@
+package pricing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/pricing"
+	"subscription-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memRepo mirrors pgPriceScheduleRepo: applied prices leave the schedule.
+type memRepo struct {
+	scheduled []repository.DuePrice
+	prices    map[string]int
+	fail      map[string]bool
+	applies   int
+}
+
+// scheduled is ordered like Due's result.
+func (m *memRepo) Due(_ context.Context, day time.Time, after *repository.DuePrice, limit int) ([]repository.DuePrice, error) {
+	var out []repository.DuePrice
+	for _, d := range m.scheduled {
+		if after != nil && (d.EffectiveFrom.Before(after.EffectiveFrom) ||
+			d.EffectiveFrom.Equal(after.EffectiveFrom) && d.SubscriptionID <= after.SubscriptionID) {
+			continue
+		}
+		if !d.EffectiveFrom.After(day) && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) Apply(_ context.Context, d repository.DuePrice, _ time.Time) error {
+	m.applies++
+	if m.fail[d.SubscriptionID] {
+		return errors.New("shares do not fit")
+	}
+	for i, s := range m.scheduled {
+		if s.SubscriptionID == d.SubscriptionID && s.EffectiveFrom.Equal(d.EffectiveFrom) {
+			m.scheduled = append(m.scheduled[:i], m.scheduled[i+1:]...)
+			break
+		}
+	}
+	m.prices[d.SubscriptionID] = d.Price
+	return nil
+}
+
+func due(id string, price int, from time.Time) repository.DuePrice {
+	return repository.DuePrice{SubscriptionID: id, OrgID: "org", ScheduledPrice: model.ScheduledPrice{Price: price, EffectiveFrom: from}}
+}
+
+func month(m time.Month) time.Time {
+	return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRunOnce_AppliesDuePrices(t *testing.T) {
+	repo := &memRepo{
+		scheduled: []repository.DuePrice{
+			due("a", 500, month(6)),
+			due("a", 600, month(7)),
+			due("b", 700, month(7)),
+			due("c", 800, month(8)),
+		},
+		prices: map[string]int{},
+	}
+	now := time.Date(2025, 7, 1, 3, 0, 0, 0, time.UTC)
+	a := pricing.NewApplierWithClock(repo, pricing.Config{BatchSize: 2}, func() time.Time { return now })
+
+	require.NoError(t, a.RunOnce(context.Background()))
+	assert.Equal(t, map[string]int{"a": 600, "b": 700}, repo.prices)
+	require.Len(t, repo.scheduled, 1)
+	assert.Equal(t, "c", repo.scheduled[0].SubscriptionID)
+}
+
+func TestRunOnce_FailedPriceStaysScheduled(t *testing.T) {
+	repo := &memRepo{
+		scheduled: []repository.DuePrice{due("a", 500, month(7)), due("b", 700, month(7))},
+		prices:    map[string]int{},
+		fail:      map[string]bool{"a": true},
+	}
+	now := time.Date(2025, 7, 1, 3, 0, 0, 0, time.UTC)
+	a := pricing.NewApplierWithClock(repo, pricing.Config{BatchSize: 2}, func() time.Time { return now })
+
+	require.NoError(t, a.RunOnce(context.Background()))
+	assert.Equal(t, map[string]int{"b": 700}, repo.prices)
+	assert.Equal(t, 2, repo.applies, "a failed price must not be retried in the same run")
+	require.Len(t, repo.scheduled, 1)
+
+	repo.fail = nil
+	require.NoError(t, a.RunOnce(context.Background()))
+	assert.Equal(t, map[string]int{"a": 500, "b": 700}, repo.prices)
+	assert.Empty(t, repo.scheduled)
+}
+
+func TestRunOnce_FailedBatchDoesNotStarveLaterPrices(t *testing.T) {
+	repo := &memRepo{
+		scheduled: []repository.DuePrice{due("a", 500, month(6)), due("b", 600, month(6)), due("c", 700, month(7))},
+		prices:    map[string]int{},
+		fail:      map[string]bool{"a": true, "b": true},
+	}
+	now := time.Date(2025, 7, 1, 3, 0, 0, 0, time.UTC)
+	a := pricing.NewApplierWithClock(repo, pricing.Config{BatchSize: 2}, func() time.Time { return now })
+
+	require.NoError(t, a.RunOnce(context.Background()))
+	assert.Equal(t, map[string]int{"c": 700}, repo.prices)
+	assert.Equal(t, 3, repo.applies)
+	assert.Len(t, repo.scheduled, 2)
+}
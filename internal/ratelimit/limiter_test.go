@@ -0,0 +1,50 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"subscription-service/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestAllow_BurstThenRefill(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := ratelimit.NewWithClock(ratelimit.Limit{Rate: 1, Burst: 3}, c.now)
+
+	for i := 0; i < 3; i++ {
+		res := l.Allow("a", 1)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+
+	res := l.Allow("a", 1)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+
+	assert.True(t, l.Allow("b", 1).Allowed, "keys have separate buckets")
+
+	c.t = c.t.Add(time.Second)
+	assert.True(t, l.Allow("a", 1).Allowed)
+	assert.False(t, l.Allow("a", 1).Allowed)
+}
+
+func TestAllow_Cost(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := ratelimit.NewWithClock(ratelimit.Limit{Rate: 2, Burst: 10}, c.now)
+
+	assert.True(t, l.Allow("a", 8).Allowed)
+	res := l.Allow("a", 5)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+	assert.Equal(t, 1500*time.Millisecond, res.RetryAfter)
+
+	c.t = c.t.Add(10 * time.Second)
+	assert.True(t, l.Allow("a", 50).Allowed, "cost is capped at burst")
+}
@@ -0,0 +1,120 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/notify"
+	"subscription-service/internal/repository"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	// LeadDays are the reminder offsets before end_date, e.g. 7 and 1.
+	LeadDays  []int
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Scheduler sends one reminder per subscription, end date and lead time.
+// Each lead time owns the window between itself and the next shorter one,
+// so a subscription created two days before its end only gets the shortest
+// applicable reminder rather than all of them at once.
+type Scheduler struct {
+	repo     repository.ReminderRepo
+	notifier notify.Notifier
+	cfg      Config
+	now      func() time.Time
+}
+
+func NewScheduler(repo repository.ReminderRepo, n notify.Notifier, cfg Config) *Scheduler {
+	return NewSchedulerWithClock(repo, n, cfg, time.Now)
+}
+
+func NewSchedulerWithClock(repo repository.ReminderRepo, n notify.Notifier, cfg Config, now func() time.Time) *Scheduler {
+	leads := slices.Clone(cfg.LeadDays)
+	slices.Sort(leads)
+	cfg.LeadDays = slices.Compact(leads)
+	return &Scheduler{repo: repo, notifier: n, cfg: cfg, now: now}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("reminder run failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends all reminders that are due now. Failed notifications are
+// released and retried by the next run.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	now := s.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	after := today.AddDate(0, 0, -1) // end_date is a date: include today
+	for _, lead := range s.cfg.LeadDays {
+		until := today.AddDate(0, 0, lead)
+		if err := s.sendWindow(ctx, after, until, lead); err != nil {
+			return err
+		}
+		after = until
+	}
+	return nil
+}
+
+func (s *Scheduler) sendWindow(ctx context.Context, after, until time.Time, lead int) error {
+	log := zerolog.Ctx(ctx)
+	for {
+		due, err := s.repo.Due(ctx, after, until, lead, s.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, sub := range due {
+			claimed, err := s.repo.Claim(ctx, sub, lead)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			if err := s.notifier.Notify(ctx, message(sub)); err != nil {
+				failed++
+				log.Warn().Err(err).Str("subscription_id", sub.ID).Int("lead_days", lead).Msg("reminder not sent, will retry")
+				if err := s.repo.Release(ctx, sub, lead); err != nil {
+					return err
+				}
+				continue
+			}
+			log.Info().Str("subscription_id", sub.ID).Str("user_id", sub.UserID).Int("lead_days", lead).Msg("reminder sent")
+		}
+		// Released reminders are due again; stop instead of retrying them
+		// in a loop until the next run.
+		if len(due) < s.cfg.BatchSize || failed > 0 {
+			return nil
+		}
+	}
+}
+
+func message(s *model.Subscription) notify.Message {
+	end := s.EndDate.Format("02.01.2006")
+	return notify.Message{
+		OrgID:   s.OrgID,
+		UserID:  s.UserID,
+		Subject: fmt.Sprintf("Подписка %s заканчивается %s", s.ServiceName, end),
+		Body: fmt.Sprintf("Подписка %s (%d руб.) заканчивается %s.\nПродлите её или отмените, если она больше не нужна.",
+			s.ServiceName, s.Price, end),
+	}
+}
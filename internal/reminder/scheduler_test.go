@@ -0,0 +1,151 @@
+package reminder_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/notify"
+	"subscription-service/internal/reminder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type claimKey struct {
+	id   string
+	lead int
+}
+
+// memRepo mirrors pgReminderRepo: claims can be released, emitted events
+// cannot.
+type memRepo struct {
+	subs    []*model.Subscription
+	claimed map[claimKey]bool
+	emitted map[claimKey]bool
+	events  int
+}
+
+func (m *memRepo) Due(_ context.Context, after, until time.Time, lead, limit int) ([]*model.Subscription, error) {
+	var out []*model.Subscription
+	for _, s := range m.subs {
+		if s.EndDate.After(after) && !s.EndDate.After(until) && !m.claimed[claimKey{s.ID, lead}] && len(out) < limit {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (m *memRepo) Claim(_ context.Context, s *model.Subscription, lead int) (bool, error) {
+	k := claimKey{s.ID, lead}
+	if m.claimed[k] {
+		return false, nil
+	}
+	m.claimed[k] = true
+	if m.emitted == nil {
+		m.emitted = map[claimKey]bool{}
+	}
+	if !m.emitted[k] {
+		m.emitted[k] = true
+		m.events++
+	}
+	return true, nil
+}
+
+func (m *memRepo) Release(_ context.Context, s *model.Subscription, lead int) error {
+	delete(m.claimed, claimKey{s.ID, lead})
+	return nil
+}
+
+type recorder struct {
+	sent []string
+	fail map[string]bool
+}
+
+func (r *recorder) Notify(_ context.Context, m notify.Message) error {
+	if r.fail[m.UserID] {
+		return errors.New("mail server down")
+	}
+	r.sent = append(r.sent, m.UserID)
+	return nil
+}
+
+// flaky fails its first failures notifications.
+type flaky struct {
+	failures int
+	sent     int
+}
+
+func (f *flaky) Notify(context.Context, notify.Message) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("mail server down")
+	}
+	f.sent++
+	return nil
+}
+
+var now = time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+
+func sub(id string, inDays int) *model.Subscription {
+	end := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC).AddDate(0, 0, inDays)
+	return &model.Subscription{ID: id, UserID: id, ServiceName: "Netflix", Price: 499, EndDate: &end}
+}
+
+func newScheduler(repo *memRepo, n notify.Notifier) *reminder.Scheduler {
+	return reminder.NewSchedulerWithClock(repo, n, reminder.Config{LeadDays: []int{7, 1, 7}, BatchSize: 2},
+		func() time.Time { return now })
+}
+
+func TestRunOnce_Windows(t *testing.T) {
+	repo := &memRepo{
+		subs:    []*model.Subscription{sub("today", 0), sub("tomorrow", 1), sub("in5", 5), sub("in7", 7), sub("in8", 8), sub("past", -1)},
+		claimed: map[claimKey]bool{},
+	}
+	rec := &recorder{}
+	s := newScheduler(repo, rec)
+
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.ElementsMatch(t, []string{"today", "tomorrow", "in5", "in7"}, rec.sent)
+	assert.True(t, repo.claimed[claimKey{"tomorrow", 1}])
+	assert.True(t, repo.claimed[claimKey{"in5", 7}])
+
+	// A second run sends nothing new.
+	rec.sent = nil
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.Empty(t, rec.sent)
+}
+
+func TestRunOnce_ReleasesFailed(t *testing.T) {
+	var subs []*model.Subscription
+	for i := range 5 {
+		subs = append(subs, sub(fmt.Sprintf("u%d", i), 3))
+	}
+	repo := &memRepo{subs: subs, claimed: map[claimKey]bool{}}
+	rec := &recorder{fail: map[string]bool{"u1": true}}
+	s := newScheduler(repo, rec)
+
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.Equal(t, []string{"u0"}, rec.sent, "the window stops at a failed batch")
+	assert.False(t, repo.claimed[claimKey{"u1", 7}])
+
+	rec.fail = nil
+	require.NoError(t, s.RunOnce(context.Background()))
+	assert.Equal(t, []string{"u0", "u1", "u2", "u3", "u4"}, rec.sent)
+}
+
+func TestRunOnce_RetryEmitsEventOnce(t *testing.T) {
+	repo := &memRepo{subs: []*model.Subscription{sub("u0", 3)}, claimed: map[claimKey]bool{}}
+	n := &flaky{failures: 2}
+	s := newScheduler(repo, n)
+
+	for range 3 {
+		require.NoError(t, s.RunOnce(context.Background()))
+	}
+	assert.Equal(t, 1, n.sent)
+	assert.True(t, repo.claimed[claimKey{"u0", 7}])
+	assert.Equal(t, 1, repo.events, "one outbox event despite two failed notifications")
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepo interface {
+	Create(ctx context.Context, k *model.APIKey) error
+	List(ctx context.Context) ([]*model.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	GetActiveByHash(ctx context.Context, hash string) (*model.APIKey, error)
+}
+
+const apiKeyColumns = `id, org_id, name, prefix, key_hash, scopes, created_by, created_at, revoked_at`
+
+type pgAPIKeyRepo struct {
+	db *sql.DB
+}
+
+func NewPGAPIKeyRepo(db *sql.DB) APIKeyRepo {
+	return &pgAPIKeyRepo{db: db}
+}
+
+func (p *pgAPIKeyRepo) Create(ctx context.Context, k *model.APIKey) error {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+	k.OrgID = orgID
+
+	q := `INSERT INTO api_keys (id, org_id, name, prefix, key_hash, scopes, created_by, created_at)
+          VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+	_, err = p.db.ExecContext(ctx, q,
+		k.ID, k.OrgID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.CreatedBy, k.CreatedAt)
+	return err
+}
+
+func (p *pgAPIKeyRepo) List(ctx context.Context) ([]*model.APIKey, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + apiKeyColumns + `
+          FROM api_keys WHERE org_id = $1
+          ORDER BY created_at DESC`
+	rows, err := p.db.QueryContext(ctx, q, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*model.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgAPIKeyRepo) Revoke(ctx context.Context, id string) error {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE api_keys SET revoked_at = now()
+          WHERE id = $1 AND org_id = $2 AND revoked_at IS NULL`
+	res, err := p.db.ExecContext(ctx, q, id, orgID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetActiveByHash is not tenant scoped: the key itself determines the tenant.
+func (p *pgAPIKeyRepo) GetActiveByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	q := `SELECT ` + apiKeyColumns + `
+          FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	k, err := scanAPIKey(p.db.QueryRowContext(ctx, q, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return k, nil
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	k := &model.APIKey{}
+	var revoked sql.NullTime
+	if err := row.Scan(&k.ID, &k.OrgID, &k.Name, &k.Prefix, &k.KeyHash, pq.Array(&k.Scopes), &k.CreatedBy, &k.CreatedAt, &revoked); err != nil {
+		return nil, err
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return k, nil
+}
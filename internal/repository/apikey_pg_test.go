@@ -0,0 +1,79 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyCreate_Success(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := repository.NewPGAPIKeyRepo(db)
+
+	k := &model.APIKey{
+		ID:        uuid.New().String(),
+		Name:      "batch",
+		Prefix:    "sk_abcdef12",
+		KeyHash:   "hash",
+		Scopes:    []string{"reports:read"},
+		CreatedBy: "admin",
+		CreatedAt: time.Now(),
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO api_keys`)).
+		WithArgs(k.ID, testOrgID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.CreatedBy, k.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	assert.NoError(t, repo.Create(orgCtx(), k))
+	assert.Equal(t, testOrgID, k.OrgID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyGetActiveByHash(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := repository.NewPGAPIKeyRepo(db)
+
+	rows := sqlmock.NewRows([]string{
+		"id", "org_id", "name", "prefix", "key_hash", "scopes", "created_by", "created_at", "revoked_at",
+	}).AddRow("k1", testOrgID, "batch", "sk_abcdef12", "hash", "{subscriptions:read,reports:read}", "admin", time.Now(), nil)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`)).
+		WithArgs("hash").
+		WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM api_keys WHERE key_hash = $1`)).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	k, err := repo.GetActiveByHash(context.Background(), "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, testOrgID, k.OrgID)
+	assert.Equal(t, []string{"subscriptions:read", "reports:read"}, k.Scopes)
+
+	_, err = repo.GetActiveByHash(context.Background(), "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRevoke_NotFound(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := repository.NewPGAPIKeyRepo(db)
+
+	id := uuid.New().String()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE api_keys SET revoked_at = now()`)).
+		WithArgs(id, testOrgID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, repo.Revoke(orgCtx(), id), repository.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
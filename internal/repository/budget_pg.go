@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+
+	"github.com/lib/pq"
+)
+
+// ErrConflict is returned when a unique constraint rejects a write.
+var ErrConflict = errors.New("conflict")
+
+type BudgetRepo interface {
+	// Create returns ErrConflict if the user (or the organization, for a
+	// budget without user) already has a budget.
+	Create(ctx context.Context, b *model.Budget) error
+	GetByID(ctx context.Context, id string) (*model.Budget, error)
+	// MonthlySpend is the projected spend of the month starting at month,
+	// computed like TotalCostForPeriod for that month (scheduled prices
+	// included) and optionally narrowed to one user.
+	MonthlySpend(ctx context.Context, month time.Time, userID *string) (int64, error)
+
+	// ListAfter pages through the budgets of all organizations by id; it
+	// and the alert methods below are used by the evaluator only.
+	ListAfter(ctx context.Context, afterID string, limit int) ([]*model.Budget, error)
+	// ClaimAlert records the alert and, the first time an alert is claimed,
+	// emits a budget.threshold_crossed event. It returns false if the alert
+	// was already claimed.
+	ClaimAlert(ctx context.Context, a *model.BudgetAlert) (bool, error)
+	// ReleaseAlert forgets a claim whose notification could not be sent.
+	// The event is not emitted again.
+	ReleaseAlert(ctx context.Context, a *model.BudgetAlert) error
+}
+
+const budgetColumns = `id, org_id, user_id, amount, created_by, created_at`
+
+type pgBudgetRepo struct {
+	db *sql.DB
+}
+
+func NewPGBudgetRepo(db *sql.DB) BudgetRepo {
+	return &pgBudgetRepo{db: db}
+}
+
+func (p *pgBudgetRepo) Create(ctx context.Context, b *model.Budget) error {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+	b.OrgID = orgID
+
+	_, err = p.db.ExecContext(ctx,
+		`INSERT INTO budgets (id, org_id, user_id, amount, created_by, created_at) VALUES ($1,$2,$3,$4,$5,$6)`,
+		b.ID, b.OrgID, b.UserID, b.Amount, b.CreatedBy, b.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+func (p *pgBudgetRepo) GetByID(ctx context.Context, id string) (*model.Budget, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + budgetColumns + ` FROM budgets WHERE id = $1 AND org_id = $2`
+	b, err := scanBudget(p.db.QueryRowContext(ctx, q, id, orgID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (p *pgBudgetRepo) MonthlySpend(ctx context.Context, month time.Time, userID *string) (int64, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	q := `SELECT COALESCE(ROUND(SUM(` + periodCost + `)),0)::bigint
+          FROM subscription_costs s` + scheduledPrice("$2::date") + `
+          WHERE s.org_id = $1
+            AND ` + activeDuring("$2::date", "$3::date") + `
+            AND ($4::uuid IS NULL OR s.user_id = $4::uuid)`
+	var uid interface{}
+	if userID != nil {
+		uid = *userID
+	}
+	var total int64
+	err = p.db.QueryRowContext(ctx, q, orgID, month, month.AddDate(0, 1, -1), uid).Scan(&total)
+	return total, err
+}
+
+func (p *pgBudgetRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*model.Budget, error) {
+	q := `SELECT ` + budgetColumns + `
+          FROM budgets
+          WHERE $1 = '' OR id > $1::uuid
+          ORDER BY id
+          LIMIT $2`
+	rows, err := p.db.QueryContext(ctx, q, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*model.Budget
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgBudgetRepo) ClaimAlert(ctx context.Context, a *model.BudgetAlert) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO budget_alerts (budget_id, month, threshold, used) VALUES ($1,$2,$3,$4)
+         ON CONFLICT DO NOTHING`, a.Budget.ID, a.Month, a.Threshold, a.Used)
+	if err != nil {
+		return false, err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return false, nil
+	}
+
+	// budget_alert_events is not touched by ReleaseAlert: a retried alert
+	// has already emitted its event.
+	res, err = tx.ExecContext(ctx,
+		`INSERT INTO budget_alert_events (budget_id, month, threshold) VALUES ($1,$2,$3)
+         ON CONFLICT DO NOTHING`, a.Budget.ID, a.Month, a.Threshold)
+	if err != nil {
+		return false, err
+	}
+	if ra, _ := res.RowsAffected(); ra > 0 {
+		if err := insertOutbox(ctx, tx, a.Budget.OrgID, model.EventBudgetThresholdCrossed, a.Budget.ID, a); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+func (p *pgBudgetRepo) ReleaseAlert(ctx context.Context, a *model.BudgetAlert) error {
+	_, err := p.db.ExecContext(ctx,
+		`DELETE FROM budget_alerts WHERE budget_id = $1 AND month = $2 AND threshold = $3`,
+		a.Budget.ID, a.Month, a.Threshold)
+	return err
+}
+
+func scanBudget(row rowScanner) (*model.Budget, error) {
+	b := &model.Budget{}
+	var userID sql.NullString
+	if err := row.Scan(&b.ID, &b.OrgID, &userID, &b.Amount, &b.CreatedBy, &b.CreatedAt); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		b.UserID = &userID.String
+	}
+	return b, nil
+}
@@ -0,0 +1,110 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetCreate_Conflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGBudgetRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO budgets`)).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err = repo.Create(tenant.WithOrgID(context.Background(), testOrgID), &model.Budget{ID: "b1", Amount: 1000})
+	assert.ErrorIs(t, err, repository.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBudgetMonthlySpend(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGBudgetRepo(db)
+
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	userID := "6b1d4a6e-0a57-4a3c-9a8e-5e0f3f1e2b7c"
+	mock.ExpectQuery(regexp.QuoteMeta(`ps.effective_from <= $2::date`)+`(?s).*`+
+		regexp.QuoteMeta(`s.start_date <= $3::date AND (s.end_date IS NULL OR s.end_date >= $2::date)`)).
+		WithArgs(testOrgID, month, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), userID).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(1299))
+
+	used, err := repo.MonthlySpend(tenant.WithOrgID(context.Background(), testOrgID), month, &userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1299), used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBudgetClaimAlert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGBudgetRepo(db)
+
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	alert := &model.BudgetAlert{Budget: &model.Budget{ID: "b1", OrgID: testOrgID, Amount: 1000}, Month: month, Threshold: 80, Used: 850}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO budget_alerts`)).
+		WithArgs("b1", month, 80, int64(850)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO budget_alert_events`)).
+		WithArgs("b1", month, 80).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox`)).
+		WithArgs(sqlmock.AnyArg(), testOrgID, model.EventBudgetThresholdCrossed, "b1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	claimed, err := repo.ClaimAlert(context.Background(), alert)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO budget_alerts`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	claimed, err = repo.ClaimAlert(context.Background(), alert)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBudgetClaimAlert_AfterRelease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGBudgetRepo(db)
+
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	alert := &model.BudgetAlert{Budget: &model.Budget{ID: "b1", OrgID: testOrgID, Amount: 1000}, Month: month, Threshold: 80, Used: 850}
+
+	// The event of a released alert was already emitted: the retry claims
+	// the alert again without a second outbox row.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO budget_alerts`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO budget_alert_events`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	claimed, err := repo.ClaimAlert(context.Background(), alert)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
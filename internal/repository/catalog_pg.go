@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+)
+
+type CatalogRepo interface {
+	// Put creates or replaces the entry of e.ServiceName, ignoring case and
+	// surrounding whitespace.
+	Put(ctx context.Context, e *model.CatalogEntry) error
+	List(ctx context.Context, limit, offset int) ([]*model.CatalogEntry, error)
+	Delete(ctx context.Context, serviceName string) error
+}
+
+// pgCatalogRepo embeds pgRepo for its query timeout; its methods shadow the
+// subscription ones of the same name.
+type pgCatalogRepo struct {
+	*pgRepo
+}
+
+// NewPGCatalogRepo shares the options of NewPGRepo.
+func NewPGCatalogRepo(db *sql.DB, opts ...Option) CatalogRepo {
+	return &pgCatalogRepo{pgRepo: newPGRepo(db, opts)}
+}
+
+func (p *pgCatalogRepo) Put(ctx context.Context, e *model.CatalogEntry) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO service_catalog (org_id, service_name, price, updated_by, updated_at) VALUES ($1,$2,$3,$4,$5)
+          ON CONFLICT (org_id, ` + serviceKey("service_name") + `) DO UPDATE
+          SET service_name = EXCLUDED.service_name, price = EXCLUDED.price,
+              updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`
+	ctx, span := startQuerySpan(ctx, "PutCatalogEntry", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, q, orgID, e.ServiceName, e.Price, e.UpdatedBy, e.UpdatedAt)
+	return err
+}
+
+func (p *pgCatalogRepo) List(ctx context.Context, limit, offset int) (out []*model.CatalogEntry, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT service_name, price, updated_by, updated_at
+          FROM service_catalog
+          WHERE org_id = $1
+          ORDER BY ` + serviceKey("service_name") + `
+          LIMIT $2 OFFSET $3`
+	ctx, span := startQuerySpan(ctx, "ListCatalog", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, q, orgID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &model.CatalogEntry{}
+		if err := rows.Scan(&e.ServiceName, &e.Price, &e.UpdatedBy, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgCatalogRepo) Delete(ctx context.Context, serviceName string) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `DELETE FROM service_catalog WHERE org_id = $1 AND ` + serviceKey("service_name") + ` = ` + serviceKey("$2")
+	ctx, span := startQuerySpan(ctx, "DeleteCatalogEntry", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, q, orgID, serviceName)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
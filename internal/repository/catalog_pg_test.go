@@ -0,0 +1,61 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogPut_Upserts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGCatalogRepo(db)
+
+	e := &model.CatalogEntry{ServiceName: "Netflix", Price: 499, UpdatedBy: "admin", UpdatedAt: time.Now()}
+	mock.ExpectExec(regexp.QuoteMeta(`ON CONFLICT (org_id, lower(trim(service_name))) DO UPDATE`)).
+		WithArgs(testOrgID, "Netflix", 499, "admin", e.UpdatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.Put(tenant.WithOrgID(context.Background(), testOrgID), e))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatalogDelete_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGCatalogRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM service_catalog WHERE org_id = $1 AND lower(trim(service_name)) = lower(trim($2))`)).
+		WithArgs(testOrgID, " netflix").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.Delete(tenant.WithOrgID(context.Background(), testOrgID), " netflix")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatalogList_QueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGCatalogRepo(db, repository.WithQueryTimeout(10*time.Millisecond))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM service_catalog`)).
+		WithArgs(testOrgID, 10, 0).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"service_name", "price", "updated_by", "updated_at"}))
+
+	_, err = repo.List(tenant.WithOrgID(context.Background(), testOrgID), 10, 0)
+	assert.Error(t, err, "query is cancelled once the timeout elapses")
+}
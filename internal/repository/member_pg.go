@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+)
+
+func (p *pgRepo) SetMembers(ctx context.Context, id string, members []model.Member) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO subscription_members (subscription_id, user_id, share_percent, amount) VALUES ($1,$2,$3,$4)`
+	ctx, span := startQuerySpan(ctx, "SetMembers", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		var price int
+		err := tx.QueryRowContext(ctx,
+			`SELECT price FROM subscriptions WHERE id = $1 AND org_id = $2 FOR UPDATE`, id, orgID).Scan(&price)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM subscription_members WHERE subscription_id = $1`, id); err != nil {
+			return err
+		}
+		for _, m := range members {
+			if _, err := tx.ExecContext(ctx, q, id, m.UserID, m.SharePercent, m.Amount); err != nil {
+				return err
+			}
+		}
+		// The scheduled prices have to fit the new members as well.
+		scheduled, err := scheduledPrices(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return checkShares(ctx, tx, id, append(scheduled, price)...)
+	})
+}
+
+func (p *pgRepo) ListMembers(ctx context.Context, id string) (out []model.Member, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT m.user_id, m.share_percent, m.amount
+          FROM subscription_members m
+          JOIN subscriptions s ON s.id = m.subscription_id
+          WHERE m.subscription_id = $1 AND s.org_id = $2
+          ORDER BY m.user_id`
+	ctx, span := startQuerySpan(ctx, "ListMembers", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, id, orgID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var m model.Member
+			var share sql.NullFloat64
+			var amount sql.NullInt64
+			if err := rows.Scan(&m.UserID, &share, &amount); err != nil {
+				return err
+			}
+			if share.Valid {
+				m.SharePercent = &share.Float64
+			}
+			if amount.Valid {
+				v := int(amount.Int64)
+				m.Amount = &v
+			}
+			out = append(out, m)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkShares returns ErrShares if the members of subscription id do not fit
+// one of prices. The caller holds the subscription's row lock, which every
+// change of its price, schedule or members takes, so they cannot change in
+// between.
+func checkShares(ctx context.Context, tx *sql.Tx, id string, prices ...int) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	var members, percents, fixed int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(share_percent), COALESCE(SUM(amount),0) FROM subscription_members WHERE subscription_id = $1`,
+		id).Scan(&members, &percents, &fixed); err != nil {
+		return err
+	}
+	if members == 0 {
+		return nil
+	}
+	for _, price := range prices {
+		if fixed > price || (percents == 0 && fixed != price) {
+			return ErrShares
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"regexp"
+	"testing"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMembers(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	id := uuid.New().String()
+	half, fixed := 50.0, 300
+	members := []model.Member{{UserID: "u1", SharePercent: &half}, {UserID: "u2", Amount: &fixed}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM subscriptions WHERE id = $1 AND org_id = $2 FOR UPDATE`)).
+		WithArgs(id, testOrgID).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(1000))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM subscription_members WHERE subscription_id = $1`)).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscription_members`)).
+		WithArgs(id, "u1", half, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscription_members`)).
+		WithArgs(id, "u2", nil, fixed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM price_schedule WHERE subscription_id = $1`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(1200))
+	expectShares(mock, id, 2, 1, 300)
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.SetMembers(orgCtx(), id, members))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectShares expects the share check of a subscription whose members
+// pay percents of the rest and fixed in total.
+func expectShares(mock sqlmock.Sqlmock, id string, members, percents, fixed int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM subscription_members WHERE subscription_id = $1`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "count", "sum"}).AddRow(members, percents, fixed))
+}
+
+func TestSetMembers_NotFound(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}))
+	mock.ExpectRollback()
+
+	err := repo.SetMembers(orgCtx(), uuid.New().String(), nil)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListMembers(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	id := uuid.New().String()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM subscription_members m`)).
+		WithArgs(id, testOrgID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "share_percent", "amount"}).
+			AddRow("u1", "33.33", nil).
+			AddRow("u2", nil, 300))
+
+	got, err := repo.ListMembers(orgCtx(), id)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, 33.33, *got[0].SharePercent)
+	assert.Nil(t, got[0].Amount)
+	assert.Equal(t, 300, *got[1].Amount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"subscription-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// PublishFunc delivers one event; a non-nil error schedules a retry.
+type PublishFunc func(ctx context.Context, e *model.Event) error
+
+// OutboxRepo reads the outbox across all organizations; it is used by the
+// relay worker only.
+type OutboxRepo interface {
+	// ProcessBatch leases up to limit due events and hands them to publish
+	// in insertion order outside of any transaction, then marks them
+	// published or reschedules failed ones after retryIn(attempts). Events
+	// of a relay that stops mid-batch are due again once the lease expires.
+	ProcessBatch(ctx context.Context, limit int, lease time.Duration, publish PublishFunc, retryIn func(attempts int) time.Duration) (int, error)
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type pgOutboxRepo struct {
+	db *sql.DB
+}
+
+func NewPGOutboxRepo(db *sql.DB) OutboxRepo {
+	return &pgOutboxRepo{db: db}
+}
+
+type pendingEvent struct {
+	rowID    int64
+	attempts int
+	event    model.Event
+	err      error
+}
+
+func (p *pgOutboxRepo) ProcessBatch(ctx context.Context, limit int, lease time.Duration, publish PublishFunc, retryIn func(attempts int) time.Duration) (int, error) {
+	batch, err := p.claim(ctx, limit, lease)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+
+	// Sinks may be slow; publishing holds no locks or connections.
+	processed := 0
+	for i := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+		batch[i].err = publish(ctx, &batch[i].event)
+		processed++
+	}
+
+	// Record what was delivered even when ctx was cancelled meanwhile, so a
+	// shutdown does not publish the batch again.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, pe := range batch[:processed] {
+		if pe.err != nil {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3, leased_until = NULL WHERE id = $1`,
+				pe.rowID, time.Now().Add(retryIn(pe.attempts+1)), pe.err.Error())
+		} else {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE outbox SET attempts = attempts + 1, published_at = now(), last_error = NULL, leased_until = NULL WHERE id = $1`,
+				pe.rowID)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return processed, tx.Commit()
+}
+
+// claim leases due events in one statement. SKIP LOCKED and the lease let
+// every instance run a relay without double delivery of the same batch.
+func (p *pgOutboxRepo) claim(ctx context.Context, limit int, lease time.Duration) ([]pendingEvent, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`UPDATE outbox o SET leased_until = now() + make_interval(secs => $2)
+         FROM (
+           SELECT id FROM outbox
+           WHERE published_at IS NULL AND next_attempt_at <= now()
+             AND (leased_until IS NULL OR leased_until <= now())
+           ORDER BY id
+           LIMIT $1
+           FOR UPDATE SKIP LOCKED
+         ) due
+         WHERE o.id = due.id
+         RETURNING o.id, o.event_id, o.org_id, o.event_type, o.aggregate_id, o.payload, o.created_at, o.attempts`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []pendingEvent
+	for rows.Next() {
+		var pe pendingEvent
+		var payload []byte
+		var aggregateID string
+		if err := rows.Scan(&pe.rowID, &pe.event.ID, &pe.event.OrgID, &pe.event.Type, &aggregateID,
+			&payload, &pe.event.OccurredAt, &pe.attempts); err != nil {
+			return nil, err
+		}
+		pe.event.SetAggregateID(aggregateID)
+		pe.event.Data = payload
+		batch = append(batch, pe)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the subquery's order.
+	slices.SortFunc(batch, func(a, b pendingEvent) int { return cmp.Compare(a.rowID, b.rowID) })
+	return batch, nil
+}
+
+func (p *pgOutboxRepo) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func insertEvent(ctx context.Context, tx *sql.Tx, eventType string, s, previous *model.Subscription) error {
+	return insertOutbox(ctx, tx, s.OrgID, eventType, s.ID, model.SubscriptionChange{Subscription: s, Previous: previous})
+}
+
+func insertOutbox(ctx context.Context, tx *sql.Tx, orgID, eventType, aggregateID string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox (event_id, org_id, event_type, aggregate_id, payload, created_at) VALUES ($1,$2,$3,$4,$5,$6)`,
+		uuid.NewString(), orgID, eventType, aggregateID, payload, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,85 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGOutboxRepo(db)
+
+	// The lease is committed on its own; events are published before the
+	// transaction that records the outcome begins.
+	mock.ExpectQuery(regexp.QuoteMeta(`SET leased_until = now() + make_interval(secs => $2)`)).
+		WithArgs(10, 60.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "org_id", "event_type", "aggregate_id", "payload", "created_at", "attempts"}).
+			AddRow(2, "e2", testOrgID, "subscription.deleted", "s2", []byte(`{}`), time.Now(), 2).
+			AddRow(1, "e1", testOrgID, "subscription.created", "s1", []byte(`{}`), time.Now(), 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`published_at = now(), last_error = NULL, leased_until = NULL`)).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`next_attempt_at = $2, last_error = $3, leased_until = NULL`)).
+		WithArgs(2, sqlmock.AnyArg(), "sink down").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var attempts []int
+	var ids []string
+	n, err := repo.ProcessBatch(context.Background(), 10, time.Minute,
+		func(_ context.Context, e *model.Event) error {
+			ids = append(ids, e.ID)
+			if e.ID == "e2" {
+				return errors.New("sink down")
+			}
+			return nil
+		},
+		func(a int) time.Duration {
+			attempts = append(attempts, a)
+			return time.Minute
+		})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"e1", "e2"}, ids, "insertion order")
+	assert.Equal(t, []int{3}, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessBatch_RecordsAfterCancel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGOutboxRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`RETURNING`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "org_id", "event_type", "aggregate_id", "payload", "created_at", "attempts"}).
+			AddRow(1, "e1", testOrgID, "subscription.created", "s1", []byte(`{}`), time.Now(), 0).
+			AddRow(2, "e2", testOrgID, "subscription.created", "s2", []byte(`{}`), time.Now(), 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`published_at = now()`)).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Shutdown after the first event: it is recorded, the second one keeps
+	// its lease until it expires.
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err := repo.ProcessBatch(ctx, 10, time.Minute,
+		func(context.Context, *model.Event) error {
+			cancel()
+			return nil
+		},
+		func(int) time.Duration { return time.Minute })
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+)
+
+func (p *pgRepo) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO price_schedule (subscription_id, effective_from, price) VALUES ($1,$2,$3)`
+	ctx, span := startQuerySpan(ctx, "SetPriceSchedule", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if err := lockSubscriptions(ctx, tx, orgID, []string{id}); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM price_schedule WHERE subscription_id = $1`, id); err != nil {
+			return err
+		}
+		scheduled := make([]int, 0, len(prices))
+		for _, sp := range prices {
+			if _, err := tx.ExecContext(ctx, q, id, sp.EffectiveFrom, sp.Price); err != nil {
+				return err
+			}
+			scheduled = append(scheduled, sp.Price)
+		}
+		return checkShares(ctx, tx, id, scheduled...)
+	})
+}
+
+func (p *pgRepo) ListPriceSchedule(ctx context.Context, id string) (out []model.ScheduledPrice, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ps.price, ps.effective_from
+          FROM price_schedule ps
+          JOIN subscriptions s ON s.id = ps.subscription_id
+          WHERE ps.subscription_id = $1 AND s.org_id = $2
+          ORDER BY ps.effective_from`
+	ctx, span := startQuerySpan(ctx, "ListPriceSchedule", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, id, orgID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var sp model.ScheduledPrice
+			if err := rows.Scan(&sp.Price, &sp.EffectiveFrom); err != nil {
+				return err
+			}
+			out = append(out, sp)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func scheduledPrices(ctx context.Context, tx *sql.Tx, id string) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT price FROM price_schedule WHERE subscription_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []int
+	for rows.Next() {
+		var price int
+		if err := rows.Scan(&price); err != nil {
+			return nil, err
+		}
+		out = append(out, price)
+	}
+	return out, rows.Err()
+}
+
+// DuePrice is a scheduled price whose month has begun.
+type DuePrice struct {
+	SubscriptionID string
+	OrgID          string
+	model.ScheduledPrice
+}
+
+// PriceScheduleRepo applies scheduled prices of all organizations.
+type PriceScheduleRepo interface {
+	// Due returns prices effective on or before day, oldest first, starting
+	// after the price after when it is not nil.
+	Due(ctx context.Context, day time.Time, after *DuePrice, limit int) ([]DuePrice, error)
+	// Apply makes d the price of its subscription, recording the change and
+	// emitting subscription.updated like an update does, and removes d from
+	// the schedule. A price applied by another instance is skipped.
+	Apply(ctx context.Context, d DuePrice, at time.Time) error
+}
+
+type pgPriceScheduleRepo struct {
+	db *sql.DB
+}
+
+func NewPGPriceScheduleRepo(db *sql.DB) PriceScheduleRepo {
+	return &pgPriceScheduleRepo{db: db}
+}
+
+func (p *pgPriceScheduleRepo) Due(ctx context.Context, day time.Time, after *DuePrice, limit int) ([]DuePrice, error) {
+	var afterFrom, afterID interface{}
+	if after != nil {
+		afterFrom, afterID = after.EffectiveFrom, after.SubscriptionID
+	}
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT ps.subscription_id, s.org_id, ps.price, ps.effective_from
+         FROM price_schedule ps
+         JOIN subscriptions s ON s.id = ps.subscription_id
+         WHERE ps.effective_from <= $1
+           AND ($2::date IS NULL OR (ps.effective_from, ps.subscription_id) > ($2::date, $3::uuid))
+         ORDER BY ps.effective_from, ps.subscription_id
+         LIMIT $4`, day, afterFrom, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DuePrice
+	for rows.Next() {
+		var d DuePrice
+		if err := rows.Scan(&d.SubscriptionID, &d.OrgID, &d.Price, &d.EffectiveFrom); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgPriceScheduleRepo) Apply(ctx context.Context, d DuePrice, at time.Time) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	prev, err := scanSubscription(tx.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1 AND org_id = $2 FOR UPDATE`, d.SubscriptionID, d.OrgID))
+	if err == sql.ErrNoRows {
+		return nil // deleted, and its schedule with it
+	}
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM price_schedule WHERE subscription_id = $1 AND effective_from = $2`, d.SubscriptionID, d.EffectiveFrom)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	if prev.Price != d.Price {
+		s := *prev
+		s.Price, s.UpdatedAt = d.Price, at
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE subscriptions SET price = $1, updated_at = $2 WHERE id = $3`, s.Price, s.UpdatedAt, s.ID); err != nil {
+			return err
+		}
+		if err := checkShares(ctx, tx, s.ID, s.Price); err != nil {
+			return err
+		}
+		if err := recordPriceChange(ctx, tx, prev, &s); err != nil {
+			return err
+		}
+		if err := insertEvent(ctx, tx, model.EventSubscriptionUpdated, &s, prev); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,154 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPriceSchedule(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	id := uuid.New().String()
+	jul, sep := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WithArgs(testOrgID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM price_schedule WHERE subscription_id = $1`)).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO price_schedule`)).
+		WithArgs(id, jul, 599).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO price_schedule`)).
+		WithArgs(id, sep, 699).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectShares(mock, id, 0, 0, 0)
+	mock.ExpectCommit()
+
+	err := repo.SetPriceSchedule(orgCtx(), id, []model.ScheduledPrice{{Price: 599, EffectiveFrom: jul}, {Price: 699, EffectiveFrom: sep}})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetPriceSchedule_BelowFixedShares(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	id := uuid.New().String()
+	jul := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM price_schedule`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO price_schedule`)).
+		WithArgs(id, jul, 200).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectShares(mock, id, 1, 0, 300)
+	mock.ExpectRollback()
+
+	err := repo.SetPriceSchedule(orgCtx(), id, []model.ScheduledPrice{{Price: 200, EffectiveFrom: jul}})
+	assert.ErrorIs(t, err, repository.ErrShares)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListPriceSchedule(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	id := uuid.New().String()
+	jul := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM price_schedule ps`)).
+		WithArgs(id, testOrgID).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "effective_from"}).AddRow(599, jul))
+
+	got, err := repo.ListPriceSchedule(orgCtx(), id)
+	require.NoError(t, err)
+	assert.Equal(t, []model.ScheduledPrice{{Price: 599, EffectiveFrom: jul}}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPriceScheduleDue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGPriceScheduleRepo(db)
+
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE ps.effective_from <= $1`)).
+		WithArgs(day, nil, nil, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_id", "org_id", "price", "effective_from"}).AddRow("s1", testOrgID, 599, day))
+
+	got, err := repo.Due(context.Background(), day, nil, 50)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, repository.DuePrice{SubscriptionID: "s1", OrgID: testOrgID, ScheduledPrice: model.ScheduledPrice{Price: 599, EffectiveFrom: day}}, got[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPriceScheduleApply(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGPriceScheduleRepo(db)
+
+	id := uuid.New().String()
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	at := day.Add(time.Hour)
+	d := repository.DuePrice{SubscriptionID: id, OrgID: testOrgID, ScheduledPrice: model.ScheduledPrice{Price: 599, EffectiveFrom: day}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM subscriptions WHERE id = $1 AND org_id = $2 FOR UPDATE`)).
+		WithArgs(id, testOrgID).
+		WillReturnRows(subscriptionRows().AddRow(id, testOrgID, "Netflix", 499, "u1", day, nil, day, day, nil, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM price_schedule WHERE subscription_id = $1 AND effective_from = $2`)).
+		WithArgs(id, day).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE subscriptions SET price = $1, updated_at = $2 WHERE id = $3`)).
+		WithArgs(599, at, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectShares(mock, id, 0, 0, 0)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscription_price_changes`)).
+		WithArgs(testOrgID, id, 499, 599, at).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, "subscription.updated", id)
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Apply(context.Background(), d, at))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPriceScheduleApply_AlreadyApplied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGPriceScheduleRepo(db)
+
+	id := uuid.New().String()
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	d := repository.DuePrice{SubscriptionID: id, OrgID: testOrgID, ScheduledPrice: model.ScheduledPrice{Price: 599, EffectiveFrom: day}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(subscriptionRows().AddRow(id, testOrgID, "Netflix", 599, "u1", day, nil, day, day, nil, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM price_schedule`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	require.NoError(t, repo.Apply(context.Background(), d, day))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
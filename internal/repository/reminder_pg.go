@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// ReminderRepo finds subscriptions that are about to end across all
+// organizations and records which reminders were sent.
+type ReminderRepo interface {
+	// Due returns subscriptions with after < end_date <= until that have no
+	// reminder for leadDays yet.
+	Due(ctx context.Context, after, until time.Time, leadDays, limit int) ([]*model.Subscription, error)
+	// Claim records the reminder and, the first time a reminder is claimed,
+	// emits a subscription.ending_soon event. It returns false if the
+	// reminder was already claimed, e.g. by another instance.
+	Claim(ctx context.Context, s *model.Subscription, leadDays int) (bool, error)
+	// Release forgets a claim whose notification could not be sent, so that
+	// the next run retries it. The event is not emitted again.
+	Release(ctx context.Context, s *model.Subscription, leadDays int) error
+}
+
+type pgReminderRepo struct {
+	db *sql.DB
+}
+
+func NewPGReminderRepo(db *sql.DB) ReminderRepo {
+	return &pgReminderRepo{db: db}
+}
+
+func (p *pgReminderRepo) Due(ctx context.Context, after, until time.Time, leadDays, limit int) ([]*model.Subscription, error) {
+	q := `SELECT ` + subscriptionColumns + `
+          FROM subscriptions s
+          WHERE s.end_date > $1 AND s.end_date <= $2
+            AND NOT EXISTS (
+              SELECT 1 FROM reminders_sent r
+              WHERE r.subscription_id = s.id AND r.end_date = s.end_date AND r.lead_days = $3)
+          ORDER BY s.end_date, s.id
+          LIMIT $4`
+	rows, err := p.db.QueryContext(ctx, q, after, until, leadDays, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*model.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgReminderRepo) Claim(ctx context.Context, s *model.Subscription, leadDays int) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO reminders_sent (subscription_id, end_date, lead_days, org_id) VALUES ($1,$2,$3,$4)
+         ON CONFLICT DO NOTHING`, s.ID, s.EndDate, leadDays, s.OrgID)
+	if err != nil {
+		return false, err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return false, nil
+	}
+
+	// reminder_events is not touched by Release: a retried reminder has
+	// already emitted its event.
+	res, err = tx.ExecContext(ctx,
+		`INSERT INTO reminder_events (subscription_id, end_date, lead_days, org_id) VALUES ($1,$2,$3,$4)
+         ON CONFLICT DO NOTHING`, s.ID, s.EndDate, leadDays, s.OrgID)
+	if err != nil {
+		return false, err
+	}
+	if ra, _ := res.RowsAffected(); ra > 0 {
+		if err := insertEvent(ctx, tx, model.EventSubscriptionEndingSoon, s, nil); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+func (p *pgReminderRepo) Release(ctx context.Context, s *model.Subscription, leadDays int) error {
+	_, err := p.db.ExecContext(ctx,
+		`DELETE FROM reminders_sent WHERE subscription_id = $1 AND end_date = $2 AND lead_days = $3`,
+		s.ID, s.EndDate, leadDays)
+	return err
+}
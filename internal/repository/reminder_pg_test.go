@@ -0,0 +1,100 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminderDue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReminderRepo(db)
+
+	after := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := after.AddDate(0, 0, 7)
+	end := after.AddDate(0, 0, 5)
+	mock.ExpectQuery(regexp.QuoteMeta(`NOT EXISTS`)).
+		WithArgs(after, until, 7, 50).
+		WillReturnRows(subscriptionRows().AddRow("s1", testOrgID, "Netflix", 499, "u1", after, end, after, after, nil, 1))
+
+	subs, err := repo.Due(context.Background(), after, until, 7, 50)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "s1", subs[0].ID)
+	assert.Equal(t, end, *subs[0].EndDate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReminderClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReminderRepo(db)
+
+	end := time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)
+	sub := &model.Subscription{ID: "s1", OrgID: testOrgID, UserID: "u1", EndDate: &end}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO reminders_sent`)).
+		WithArgs("s1", sqlmock.AnyArg(), 7, testOrgID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO reminder_events`)).
+		WithArgs("s1", sqlmock.AnyArg(), 7, testOrgID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, model.EventSubscriptionEndingSoon, "s1")
+	mock.ExpectCommit()
+
+	claimed, err := repo.Claim(context.Background(), sub, 7)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Already claimed: no event, nothing committed.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO reminders_sent`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	claimed, err = repo.Claim(context.Background(), sub, 7)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM reminders_sent`)).
+		WithArgs("s1", sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, repo.Release(context.Background(), sub, 7))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReminderClaim_AfterRelease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReminderRepo(db)
+
+	end := time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)
+	sub := &model.Subscription{ID: "s1", OrgID: testOrgID, UserID: "u1", EndDate: &end}
+
+	// The event of a released claim was already emitted: the retry claims
+	// the reminder again without a second outbox row.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO reminders_sent`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO reminder_events`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	claimed, err := repo.Claim(context.Background(), sub, 7)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type primaryKey struct{}
+
+// WithReplica routes the plain reads of the repo (GetByID, List,
+// TotalCostForPeriod, the member, tag and price schedule listings) and the
+// report queries of NewPGReportRepo to a read replica. The primary is used
+// instead when the replica cannot be reached.
+//
+// Replica reads may lag behind writes. Only contexts marked by WithPrimary
+// read their own writes; over HTTP that takes the "X-Consistency: strong"
+// header, so a report requested right after a write may not include it yet.
+func WithReplica(db *sql.DB) Option {
+	return func(p *pgRepo) {
+		p.replica = db
+	}
+}
+
+// WithPrimary makes reads on ctx go to the primary, so that a caller sees
+// its own writes regardless of replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func ReadsPrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+func (p *pgRepo) read(ctx context.Context, query func(db *sql.DB) error) error {
+	span := trace.SpanFromContext(ctx)
+	if p.replica == nil || ReadsPrimary(ctx) {
+		span.SetAttributes(attribute.Bool("db.replica", false))
+		return query(p.db)
+	}
+
+	span.SetAttributes(attribute.Bool("db.replica", true))
+	err := query(p.replica)
+	if err == nil || !isConnError(err) || ctx.Err() != nil {
+		return err
+	}
+	zerolog.Ctx(ctx).Warn().Err(err).Msg("read replica unavailable, falling back to primary")
+	span.SetAttributes(attribute.Bool("db.replica", false))
+	span.AddEvent("replica fallback")
+	return query(p.db)
+}
+
+func isConnError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 08: connection exception, 57P03: cannot_connect_now (replica starting up).
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P03"
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReplicaMocks(t *testing.T) (primary, replica sqlmock.Sqlmock, repo repository.SubscriptionRepo) {
+	pdb, primary, err := sqlmock.New()
+	require.NoError(t, err)
+	rdb, replica, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		pdb.Close()
+		rdb.Close()
+	})
+	return primary, replica, repository.NewPGRepo(pdb, repository.WithReplica(rdb))
+}
+
+func TestReplica_ServesReads(t *testing.T) {
+	primary, replica, repo := newReplicaMocks(t)
+
+	from, to := time.Now().AddDate(0, -1, 0), time.Now()
+	replica.ExpectQuery(regexp.QuoteMeta(`FROM subscription_costs s`)).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(int64(700)))
+
+	got, err := repo.TotalCostForPeriod(orgCtx(), from, to, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), got)
+	assert.NoError(t, replica.ExpectationsWereMet())
+	assert.NoError(t, primary.ExpectationsWereMet())
+}
+
+func TestReplica_FallsBackOnConnectionError(t *testing.T) {
+	primary, replica, repo := newReplicaMocks(t)
+
+	replica.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id`)).WillReturnError(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+	primary.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id`)).
+		WillReturnRows(taggedSubscriptionRows())
+
+	out, err := repo.List(orgCtx(), repository.ListFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	assert.NoError(t, primary.ExpectationsWereMet())
+}
+
+func TestReplica_QueryErrorIsNotRetried(t *testing.T) {
+	primary, replica, repo := newReplicaMocks(t)
+
+	boom := errors.New("syntax error")
+	replica.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id`)).WillReturnError(boom)
+
+	_, err := repo.GetByID(orgCtx(), "id")
+	assert.ErrorIs(t, err, boom)
+	assert.NoError(t, primary.ExpectationsWereMet())
+}
+
+func TestReplica_WithPrimaryReadsYourWrites(t *testing.T) {
+	primary, replica, repo := newReplicaMocks(t)
+
+	primary.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id`)).WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.GetByID(repository.WithPrimary(orgCtx()), "id")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
@@ -0,0 +1,566 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+)
+
+// ForecastRow is the cost of one service for one user in one month.
+type ForecastRow struct {
+	Month       time.Time
+	ServiceName string
+	UserID      string
+	Total       int64
+}
+
+// PriceAnomalyFilter selects subscriptions active on Date that cost more
+// than Threshold percent above the catalog price or above the median price of
+// at least MinPeers subscriptions of other users, or whose price was raised
+// after Since.
+type PriceAnomalyFilter struct {
+	UserID    *string
+	Date      time.Time
+	Since     time.Time
+	Threshold int
+	MinPeers  int
+	Limit     int
+	Offset    int
+}
+
+// ChurnRow counts the subscriptions to one service in one month; see
+// model.ChurnMonth. LifetimeDays is the summed lifetime of the cancelled ones.
+type ChurnRow struct {
+	Month         time.Time
+	ServiceName   string
+	ActiveAtStart int
+	New           int
+	Cancelled     int
+	LifetimeDays  int64
+}
+
+// SpendRow is the spend of one user or service (Key) in a period, the number
+// of its subscriptions active then, and its spend in the previous period.
+type SpendRow struct {
+	Key           string
+	Total         int64
+	Count         int
+	PreviousTotal int64
+}
+
+// TopSpend holds the totals of both periods and the largest spenders of
+// the current one.
+type TopSpend struct {
+	Total         int64
+	PreviousTotal int64
+	Users         []SpendRow
+	Services      []SpendRow
+}
+
+// AllocationSpend holds the totals of both periods and the spend of every
+// group with spend in one of them.
+type AllocationSpend struct {
+	Total         int64
+	PreviousTotal int64
+	Groups        []SpendRow
+}
+
+type ReportRepo interface {
+	// Forecast returns the cost of the months starting at from, computed
+	// like TotalCostForPeriod, ordered by month, service and user. Months
+	// without cost have no rows.
+	Forecast(ctx context.Context, from time.Time, months int, userID *string) ([]ForecastRow, error)
+	// History returns the cost of past months like Forecast, but prices
+	// each month as recorded in the price history at its end rather than
+	// at the current price.
+	History(ctx context.Context, from time.Time, months int, userID *string) ([]ForecastRow, error)
+	// Duplicates pages through overlapping pairs of subscriptions of the same
+	// user and service, as FindOverlapping would report them.
+	Duplicates(ctx context.Context, userID *string, limit, offset int) ([]model.Overlap, error)
+	// PriceAnomalies pages through the subscriptions matching f with their
+	// reference prices and Reasons set; the percentages are left to the
+	// caller.
+	PriceAnomalies(ctx context.Context, f PriceAnomalyFilter) ([]model.PriceAnomaly, error)
+	// Top returns the limit users and services with the highest spend in
+	// from..to, computed like TotalCostForPeriod, ordered by spend.
+	Top(ctx context.Context, from, to, prevFrom, prevTo time.Time, userID *string, limit int) (*TopSpend, error)
+	// Churn returns the months from..to (first days of months) for every
+	// service with a subscription active in that month, ordered by month
+	// and service.
+	Churn(ctx context.Context, from, to time.Time, serviceName, userID *string) ([]ChurnRow, error)
+	// ActiveSubscriptions returns the subscriptions userID pays for that are
+	// active on day, most expensive first: those shared with userID as a
+	// member and those of userID without members.
+	ActiveSubscriptions(ctx context.Context, userID string, day time.Time) ([]*model.Subscription, error)
+	// Allocation returns the spend in from..to per cost center or tag
+	// (model.GroupByCostCenter or GroupByTag), computed like Top and ordered
+	// by spend. Spend without a cost center or tag has Key "".
+	Allocation(ctx context.Context, groupBy string, from, to, prevFrom, prevTo time.Time, userID *string) (*AllocationSpend, error)
+}
+
+// NewPGReportRepo shares the options of NewPGRepo, so reports run on the
+// read replica when one is configured.
+func NewPGReportRepo(db *sql.DB, opts ...Option) ReportRepo {
+	return newPGRepo(db, opts)
+}
+
+func (p *pgRepo) Forecast(ctx context.Context, from time.Time, months int, userID *string) ([]ForecastRow, error) {
+	// A month costs what TotalCostForPeriod returns for it.
+	return p.monthlySpend(ctx, "Forecast", monthlySpendQuery(periodCost, scheduledPrice("m.month::date")), from, months, userID)
+}
+
+func (p *pgRepo) History(ctx context.Context, from time.Time, months int, userID *string) ([]ForecastRow, error) {
+	// The price at the end of a month is the old price of the first change
+	// after it; without one, the month costs like in Forecast.
+	joins := `
+          LEFT JOIN LATERAL (
+            SELECT ch.old_price FROM subscription_price_changes ch
+            WHERE ch.subscription_id = s.subscription_id AND ch.changed_at >= m.month + interval '1 month'
+            ORDER BY ch.changed_at
+            LIMIT 1
+          ) h ON true` + scheduledPrice("m.month::date")
+	return p.monthlySpend(ctx, "History", monthlySpendQuery(costAt("COALESCE(h.old_price, sp.price, s.price)"), joins), from, months, userID)
+}
+
+// monthlySpendQuery sums cost per month $2..$3, service and user; joins may
+// add the tables cost refers to. Like Top, services are grouped by
+// serviceKey and named the same in every month.
+func monthlySpendQuery(cost, joins string) string {
+	return `SELECT m.month::date,
+                 min(min(trim(s.service_name))) OVER (PARTITION BY ` + serviceKey("s.service_name") + `),
+                 s.user_id, ROUND(SUM(` + cost + `))::bigint
+          FROM generate_series($2::date, $3::date, interval '1 month') AS m(month)
+          JOIN subscription_costs s ON s.org_id = $1
+            AND ` + activeDuring("m.month::date", "(m.month + interval '1 month - 1 day')::date") + `
+            AND ($4::uuid IS NULL OR s.user_id = $4::uuid)` + joins + `
+          GROUP BY 1, ` + serviceKey("s.service_name") + `, 3
+          ORDER BY 1, 2, 3`
+}
+
+func (p *pgRepo) monthlySpend(ctx context.Context, name, q string, from time.Time, months int, userID *string) (out []ForecastRow, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := startQuerySpan(ctx, name, q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var uid interface{}
+	if userID != nil {
+		uid = *userID
+	}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, from, from.AddDate(0, months-1, 0), uid)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var r ForecastRow
+			if err := rows.Scan(&r.Month, &r.ServiceName, &r.UserID, &r.Total); err != nil {
+				return err
+			}
+			out = append(out, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *pgRepo) Duplicates(ctx context.Context, userID *string, limit, offset int) (out []model.Overlap, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT a.user_id, a.service_name, a.id, s.id,
+                 GREATEST(a.start_date, s.start_date),
+                 NULLIF(LEAST(COALESCE(a.end_date, 'infinity'::date), COALESCE(s.end_date, 'infinity'::date)), 'infinity'::date)
+          FROM subscriptions a
+          JOIN subscriptions s ON s.org_id = a.org_id
+            AND s.user_id = a.user_id
+            AND ` + serviceKey("s.service_name") + ` = ` + serviceKey("a.service_name") + `
+            AND s.id > a.id
+            AND ` + activeDuring("a.start_date", "COALESCE(a.end_date, 'infinity'::date)") + `
+          WHERE a.org_id = $1
+            AND ($2::uuid IS NULL OR a.user_id = $2::uuid)
+          ORDER BY a.user_id, ` + serviceKey("a.service_name") + `, 5, a.id, s.id
+          LIMIT $3 OFFSET $4`
+	ctx, span := startQuerySpan(ctx, "Duplicates", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var uid interface{}
+	if userID != nil {
+		uid = *userID
+	}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, uid, limit, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var o model.Overlap
+			var first, second string
+			var to sql.NullTime
+			if err := rows.Scan(&o.UserID, &o.ServiceName, &first, &second, &o.From, &to); err != nil {
+				return err
+			}
+			o.SubscriptionIDs = []string{first, second}
+			if to.Valid {
+				o.To = &to.Time
+			}
+			out = append(out, o)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *pgRepo) PriceAnomalies(ctx context.Context, f PriceAnomalyFilter) (out []model.PriceAnomaly, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Peers are the other users' subscriptions to the service, so a user with
+	// several seats is not compared with themselves. Prices are compared per
+	// month, whatever the billing period.
+	q := `WITH active AS (
+            SELECT s.id, s.user_id, s.service_name, s.price, s.price::numeric / s.billing_period AS monthly
+            FROM subscriptions s
+            WHERE s.org_id = $1 AND ` + activeDuring("$2::date", "$2::date") + `
+          ), candidates AS (
+            SELECT a.id, a.user_id, a.service_name, a.price, c.price AS catalog_price,
+                   peer.median, peer.n, h.old_price, h.new_price, h.changed_at,
+                   COALESCE(a.monthly * 100 > c.price::bigint * (100 + $5), false) AS above_catalog,
+                   COALESCE(peer.n >= $6 AND a.monthly * 100 > peer.median * (100 + $5), false) AS above_peers
+            FROM active a
+            LEFT JOIN service_catalog c ON c.org_id = $1 AND ` + serviceKey("c.service_name") + ` = ` + serviceKey("a.service_name") + `
+            LEFT JOIN LATERAL (
+              SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY o.monthly) AS median, count(*) AS n
+              FROM active o
+              WHERE ` + serviceKey("o.service_name") + ` = ` + serviceKey("a.service_name") + ` AND o.user_id <> a.user_id
+            ) peer ON true
+            LEFT JOIN LATERAL (
+              SELECT ch.old_price, ch.new_price, ch.changed_at
+              FROM subscription_price_changes ch
+              WHERE ch.subscription_id = a.id AND ch.new_price > ch.old_price AND ch.changed_at >= $3
+              ORDER BY ch.changed_at DESC
+              LIMIT 1
+            ) h ON true
+            WHERE ($4::uuid IS NULL OR a.user_id = $4::uuid)
+          )
+          SELECT id, user_id, service_name, price, round(monthly)::int, catalog_price, round(median)::int, n,
+                 old_price, new_price, changed_at, above_catalog, above_peers
+          FROM candidates
+          WHERE above_catalog OR above_peers OR new_price IS NOT NULL
+          ORDER BY ` + serviceKey("service_name") + `, user_id, id
+          LIMIT $7 OFFSET $8`
+	ctx, span := startQuerySpan(ctx, "PriceAnomalies", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var uid interface{}
+	if f.UserID != nil {
+		uid = *f.UserID
+	}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, f.Date, f.Since, uid, f.Threshold, f.MinPeers, f.Limit, f.Offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var a model.PriceAnomaly
+			var catalog, median, oldPrice, newPrice sql.NullInt64
+			var changedAt sql.NullTime
+			var aboveCatalog, abovePeers bool
+			if err := rows.Scan(&a.SubscriptionID, &a.UserID, &a.ServiceName, &a.Price, &a.MonthlyPrice, &catalog, &median, &a.PeerCount,
+				&oldPrice, &newPrice, &changedAt, &aboveCatalog, &abovePeers); err != nil {
+				return err
+			}
+			if catalog.Valid {
+				v := int(catalog.Int64)
+				a.CatalogPrice = &v
+			}
+			if median.Valid {
+				v := int(median.Int64)
+				a.PeerMedian = &v
+			}
+			a.Reasons = []string{}
+			if aboveCatalog {
+				a.Reasons = append(a.Reasons, model.AnomalyAboveCatalog)
+			}
+			if abovePeers {
+				a.Reasons = append(a.Reasons, model.AnomalyAbovePeers)
+			}
+			if newPrice.Valid {
+				a.LastIncrease = &model.PriceChange{OldPrice: int(oldPrice.Int64), NewPrice: int(newPrice.Int64), ChangedAt: changedAt.Time}
+				a.Reasons = append(a.Reasons, model.AnomalyPriceIncrease)
+			}
+			out = append(out, a)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *pgRepo) Top(ctx context.Context, from, to, prevFrom, prevTo time.Time, userID *string, limit int) (out *TopSpend, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	totalQ := spendQuery("''", "")
+	rankQ := func(key, group string) string {
+		return spendQuery(key, "") + `
+          GROUP BY ` + group + `
+          HAVING bool_or(p.cur)
+          ORDER BY 2 DESC, 1
+          LIMIT $7`
+	}
+	// Services differing only in case or surrounding spaces are one
+	// service, named by the first of their spellings.
+	usersQ := rankQ("s.user_id::text", "1")
+	servicesQ := rankQ("min(trim(s.service_name))", serviceKey("s.service_name"))
+	ctx, span := startQuerySpan(ctx, "Top", usersQ)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var uid interface{}
+	if userID != nil {
+		uid = *userID
+	}
+	args := []interface{}{orgID, from, to, prevFrom, prevTo, uid}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		out = &TopSpend{}
+		var key string
+		var count int
+		if err := db.QueryRowContext(ctx, totalQ, args...).Scan(&key, &out.Total, &count, &out.PreviousTotal); err != nil {
+			return err
+		}
+		if out.Users, err = querySpendRows(ctx, db, usersQ, append(args, limit)...); err != nil {
+			return err
+		}
+		out.Services, err = querySpendRows(ctx, db, servicesQ, append(args, limit)...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *pgRepo) Allocation(ctx context.Context, groupBy string, from, to, prevFrom, prevTo time.Time, userID *string) (out *AllocationSpend, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, joins := "COALESCE(s.cost_center, '')", ""
+	if groupBy == model.GroupByTag {
+		key = "COALESCE(t.name, '')"
+		joins = `
+          LEFT JOIN subscription_tags st ON st.subscription_id = s.subscription_id
+          LEFT JOIN tags t ON t.id = st.tag_id`
+	}
+	totalQ := spendQuery("''", "")
+	q := spendQuery(key, joins) + `
+          GROUP BY 1
+          ORDER BY 2 DESC, 1`
+	ctx, span := startQuerySpan(ctx, "Allocation", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var uid interface{}
+	if userID != nil {
+		uid = *userID
+	}
+
+	args := []interface{}{orgID, from, to, prevFrom, prevTo, uid}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		out = &AllocationSpend{}
+		var key string
+		var count int
+		if err := db.QueryRowContext(ctx, totalQ, args...).Scan(&key, &out.Total, &count, &out.PreviousTotal); err != nil {
+			return err
+		}
+		out.Groups, err = querySpendRows(ctx, db, q, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// serviceKey is the normalized service name reports group services by, so
+// that "Netflix" and "netflix " are one service.
+func serviceKey(col string) string {
+	return `lower(trim(` + col + `))`
+}
+
+// spendQuery aggregates the spend of $2..$3 and of the previous period
+// $4..$5 in one pass, grouped by key when the caller adds GROUP BY 1; joins
+// may add the tables key refers to.
+func spendQuery(key, joins string) string {
+	return `SELECT ` + key + `,
+                 COALESCE(ROUND(SUM(s.cost) FILTER (WHERE p.cur)), 0)::bigint,
+                 COUNT(DISTINCT s.subscription_id) FILTER (WHERE p.cur),
+                 COALESCE(ROUND(SUM(s.cost) FILTER (WHERE p.prev)), 0)::bigint
+          FROM subscription_costs s
+          CROSS JOIN LATERAL (SELECT ` + activeDuring("$2::date", "$3::date") + ` AS cur,
+                                     ` + activeDuring("$4::date", "$5::date") + ` AS prev) p` + joins + `
+          WHERE s.org_id = $1
+            AND ($6::uuid IS NULL OR s.user_id = $6::uuid)
+            AND (p.cur OR p.prev)`
+}
+
+func querySpendRows(ctx context.Context, db *sql.DB, q string, args ...interface{}) ([]SpendRow, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SpendRow
+	for rows.Next() {
+		var r SpendRow
+		if err := rows.Scan(&r.Key, &r.Total, &r.Count, &r.PreviousTotal); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgRepo) Churn(ctx context.Context, from, to time.Time, serviceName, userID *string) (out []ChurnRow, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like Top, services are grouped by serviceKey; the window names a
+	// service the same way in every month.
+	q := `SELECT m.month::date,
+                 min(min(trim(s.service_name))) OVER (PARTITION BY ` + serviceKey("s.service_name") + `),
+                 COUNT(*) FILTER (WHERE s.start_date < m.month),
+                 COUNT(*) FILTER (WHERE s.start_date >= m.month),
+                 COUNT(*) FILTER (WHERE s.end_date <= m.last),
+                 COALESCE(SUM(s.end_date - s.start_date + 1) FILTER (WHERE s.end_date <= m.last), 0)
+          FROM generate_series($2::date, $3::date, interval '1 month') AS g(month)
+          CROSS JOIN LATERAL (SELECT g.month::date AS month, (g.month + interval '1 month - 1 day')::date AS last) m
+          JOIN subscriptions s ON s.org_id = $1
+            AND ` + activeDuring("m.month", "m.last") + `
+            AND ($4::text IS NULL OR ` + serviceKey("s.service_name") + ` = ` + serviceKey("$4::text") + `)
+            AND ($5::uuid IS NULL OR s.user_id = $5::uuid)
+          GROUP BY 1, ` + serviceKey("s.service_name") + `
+          ORDER BY 1, 2`
+	ctx, span := startQuerySpan(ctx, "Churn", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var sname, uid interface{}
+	if serviceName != nil {
+		sname = *serviceName
+	}
+	if userID != nil {
+		uid = *userID
+	}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, from, to, sname, uid)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var r ChurnRow
+			if err := rows.Scan(&r.Month, &r.ServiceName, &r.ActiveAtStart, &r.New, &r.Cancelled, &r.LifetimeDays); err != nil {
+				return err
+			}
+			out = append(out, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *pgRepo) ActiveSubscriptions(ctx context.Context, userID string, day time.Time) (out []*model.Subscription, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + subscriptionColumns + `
+          FROM subscriptions s
+          WHERE s.org_id = $1
+            AND EXISTS (SELECT 1 FROM subscription_costs c
+                        WHERE c.subscription_id = s.id AND c.user_id = $2)
+            AND ` + activeDuring("$3::date", "$3::date") + `
+          ORDER BY s.price DESC, s.service_name, s.id`
+	ctx, span := startQuerySpan(ctx, "ActiveSubscriptions", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, userID, day)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			s, err := scanSubscription(rows)
+			if err != nil {
+				return err
+			}
+			out = append(out, s)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,227 @@
+package repository_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForecast(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`s.end_date IS NULL OR s.end_date >= m.month::date`)+
+		`(?s).*`+regexp.QuoteMeta(`ps.effective_from <= m.month::date`)+
+		`(?s).*`+regexp.QuoteMeta(`GROUP BY 1, lower(trim(s.service_name)), 3`)).
+		WithArgs(testOrgID, from, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"month", "service_name", "user_id", "sum"}).
+			AddRow(from, "Netflix", "u1", 499).
+			AddRow(from.AddDate(0, 1, 0), "Netflix", "u1", 499))
+
+	rows, err := repo.Forecast(orgCtx(), from, 12, nil)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, repository.ForecastRow{Month: from, ServiceName: "Netflix", UserID: "u1", Total: 499}, rows[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	from := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	userID := "u1"
+	mock.ExpectQuery(regexp.QuoteMeta(`COALESCE(h.old_price, sp.price, s.price)`)+
+		`(?s).*`+regexp.QuoteMeta(`ch.changed_at >= m.month + interval '1 month'`)+
+		`(?s).*`+regexp.QuoteMeta(`GROUP BY 1, lower(trim(s.service_name)), 3`)).
+		WithArgs(testOrgID, from, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), userID).
+		WillReturnRows(sqlmock.NewRows([]string{"month", "service_name", "user_id", "sum"}).
+			AddRow(from, "Netflix", "u1", 399))
+
+	rows, err := repo.History(orgCtx(), from, 12, &userID)
+	require.NoError(t, err)
+	assert.Equal(t, []repository.ForecastRow{{Month: from, ServiceName: "Netflix", UserID: "u1", Total: 399}}, rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDuplicates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`lower(trim(s.service_name)) = lower(trim(a.service_name))`)).
+		WithArgs(testOrgID, nil, 50, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "service_name", "id", "id", "from", "to"}).
+			AddRow("u1", "Netflix", "s1", "s2", from, nil))
+
+	got, err := repo.Duplicates(orgCtx(), nil, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, []string{"s1", "s2"}, got[0].SubscriptionIDs)
+	assert.Equal(t, from, got[0].From)
+	assert.Nil(t, got[0].To)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPriceAnomalies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	f := repository.PriceAnomalyFilter{
+		Date:      time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+		Since:     time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC),
+		Threshold: 20,
+		MinPeers:  2,
+		Limit:     50,
+	}
+	changed := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE above_catalog OR above_peers OR new_price IS NOT NULL`)).
+		WithArgs(testOrgID, f.Date, f.Since, nil, 20, 2, 50, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "service_name", "price", "monthly_price", "catalog_price", "median", "n",
+			"old_price", "new_price", "changed_at", "above_catalog", "above_peers"}).
+			AddRow("s1", "u1", "Netflix", 899, 899, 499, 499, 3, 499, 899, changed, true, true).
+			AddRow("s2", "u2", "Slack", 3600, 300, 250, nil, 0, nil, nil, nil, true, false))
+
+	got, err := repo.PriceAnomalies(orgCtx(), f)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, []string{"above_catalog", "above_peers", "price_increase"}, got[0].Reasons)
+	assert.Equal(t, 499, *got[0].CatalogPrice)
+	assert.Equal(t, 3, got[0].PeerCount)
+	assert.Equal(t, changed, got[0].LastIncrease.ChangedAt)
+	assert.Equal(t, []string{"above_catalog"}, got[1].Reasons)
+	assert.Nil(t, got[1].PeerMedian)
+	assert.Nil(t, got[1].LastIncrease)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	from, to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	prevFrom, prevTo := time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	columns := []string{"key", "total", "count", "previous_total"}
+	mock.ExpectQuery(regexp.QuoteMeta(`COALESCE(ROUND(SUM(s.cost) FILTER (WHERE p.prev)), 0)::bigint`)).
+		WithArgs(testOrgID, from, to, prevFrom, prevTo, nil).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("", 1500, 3, 1000))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT s.user_id::text,`)).
+		WithArgs(testOrgID, from, to, prevFrom, prevTo, nil, 5).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("u1", 1000, 2, 1000).AddRow("u2", 500, 1, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT min(trim(s.service_name)),`)+`(?s).*`+regexp.QuoteMeta(`GROUP BY lower(trim(s.service_name))`)).
+		WithArgs(testOrgID, from, to, prevFrom, prevTo, nil, 5).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("Netflix", 1500, 3, 1000))
+
+	got, err := repo.Top(orgCtx(), from, to, prevFrom, prevTo, nil, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), got.Total)
+	assert.Equal(t, int64(1000), got.PreviousTotal)
+	assert.Len(t, got.Users, 2)
+	assert.Equal(t, repository.SpendRow{Key: "Netflix", Total: 1500, Count: 3, PreviousTotal: 1000}, got.Services[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAllocation_ByTag(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	from, to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	prevFrom, prevTo := time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	columns := []string{"key", "total", "count", "previous_total"}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT '',`)).
+		WithArgs(testOrgID, from, to, prevFrom, prevTo, nil).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("", 1500, 3, 1000))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(t.name, ''),`)).
+		WithArgs(testOrgID, from, to, prevFrom, prevTo, nil).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("marketing", 1000, 2, 1000).AddRow("", 500, 1, 0))
+
+	got, err := repo.Allocation(orgCtx(), model.GroupByTag, from, to, prevFrom, prevTo, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), got.Total)
+	assert.Equal(t, int64(1000), got.PreviousTotal)
+	assert.Equal(t, []repository.SpendRow{
+		{Key: "marketing", Total: 1000, Count: 2, PreviousTotal: 1000},
+		{Key: "", Total: 500, Count: 1},
+	}, got.Groups)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChurn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	from, to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	service := "Netflix"
+	mock.ExpectQuery(regexp.QuoteMeta(`s.start_date <= m.last AND (s.end_date IS NULL OR s.end_date >= m.month)`)+`(?s).*`+
+		regexp.QuoteMeta(`lower(trim(s.service_name)) = lower(trim($4::text))`)+`(?s).*`+
+		regexp.QuoteMeta(`GROUP BY 1, lower(trim(s.service_name))`)).
+		WithArgs(testOrgID, from, to, service, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"month", "service_name", "active", "new", "cancelled", "lifetime"}).
+			AddRow(from, "Netflix", 10, 2, 1, 90))
+
+	got, err := repo.Churn(orgCtx(), from, to, &service, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []repository.ChurnRow{{Month: from, ServiceName: "Netflix", ActiveAtStart: 10, New: 2, Cancelled: 1, LifetimeDays: 90}}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActiveSubscriptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	day := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`s.start_date <= $3::date AND (s.end_date IS NULL OR s.end_date >= $3::date)`)).
+		WithArgs(testOrgID, "u1", day).
+		WillReturnRows(subscriptionRows().AddRow("s1", testOrgID, "Netflix", 499, "u1", day, nil, day, day, nil, 1))
+
+	got, err := repo.ActiveSubscriptions(orgCtx(), "u1", day)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "Netflix", got[0].ServiceName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActiveSubscriptions_SharedWithMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGReportRepo(db)
+
+	// u1 is a member of a subscription created by u2; it is selected
+	// through subscription_costs rather than by s.user_id.
+	day := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`EXISTS (SELECT 1 FROM subscription_costs c
+                        WHERE c.subscription_id = s.id AND c.user_id = $2)`)).
+		WithArgs(testOrgID, "u1", day).
+		WillReturnRows(subscriptionRows().AddRow("s1", testOrgID, "Netflix", 999, "u2", day, nil, day, day, nil, 1))
+
+	got, err := repo.ActiveSubscriptions(orgCtx(), "u1", day)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "u2", got[0].UserID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
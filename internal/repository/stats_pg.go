@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ActiveStats aggregates the active subscriptions of all organizations.
+type ActiveStats struct {
+	Active       int64
+	MonthlySpend int64
+}
+
+// StatsRepo serves operational metrics. It reads all organizations and
+// must only return aggregates that identify none of them; service names are
+// free text entered by tenants, so they are not broken out either.
+type StatsRepo interface {
+	Active(ctx context.Context, at time.Time) (ActiveStats, error)
+}
+
+type pgStatsRepo struct {
+	db *sql.DB
+}
+
+func NewPGStatsRepo(db *sql.DB) StatsRepo {
+	return &pgStatsRepo{db: db}
+}
+
+func (p *pgStatsRepo) Active(ctx context.Context, at time.Time) (ActiveStats, error) {
+	q := `SELECT COUNT(*), COALESCE(ROUND(SUM(price::numeric / billing_period)),0)::bigint
+          FROM subscriptions
+          WHERE start_date <= $1
+            AND (end_date IS NULL OR end_date >= $1)`
+	var s ActiveStats
+	err := p.db.QueryRowContext(ctx, q, at).Scan(&s.Active, &s.MonthlySpend)
+	return s, err
+}
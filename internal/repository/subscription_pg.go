@@ -7,15 +7,26 @@ import (
 	"time"
 
 	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+
+	"github.com/lib/pq"
 )
 
 var ErrNotFound = errors.New("not found")
 
+// ErrShares reports that the price of a subscription and the fixed amounts
+// of its members do not fit: the amounts exceed the price, or, when no
+// member pays a percentage, do not add up to it.
+var ErrShares = errors.New("member shares do not add up to the price")
+
 type ListFilter struct {
 	UserID      *string
 	ServiceName *string
-	Limit       int
-	Offset      int
+	CostCenter  *string
+	// Tag selects subscriptions with this (lower-case) tag.
+	Tag    *string
+	Limit  int
+	Offset int
 }
 
 type SubscriptionRepo interface {
@@ -25,112 +36,257 @@ type SubscriptionRepo interface {
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, filter ListFilter) ([]*model.Subscription, error)
 	TotalCostForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error)
+	// FindOverlapping returns other subscriptions of the same user and
+	// service (ignoring case) that are active at some point of s's period.
+	FindOverlapping(ctx context.Context, s *model.Subscription) ([]*model.Subscription, error)
+	// SetMembers replaces the members sharing the cost of subscription id;
+	// an empty list makes its user pay for it alone.
+	SetMembers(ctx context.Context, id string, members []model.Member) error
+	ListMembers(ctx context.Context, id string) ([]model.Member, error)
+	// SetTags replaces the tags of subscription id, creating missing ones.
+	SetTags(ctx context.Context, id string, tags []string) error
+	// TagSubscriptions adds and removes tags on all of ids at once, or
+	// returns ErrNotFound and changes nothing if one of them does not exist.
+	TagSubscriptions(ctx context.Context, ids, add, remove []string) error
+	// ListTags returns the tags of the organization by name, counting the
+	// subscriptions of userID only when it is set.
+	ListTags(ctx context.Context, userID *string) ([]model.Tag, error)
+	DeleteTag(ctx context.Context, name string) error
+	// SetPriceSchedule replaces the future prices of subscription id, or
+	// returns ErrShares if one of them does not fit its members.
+	SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) error
+	ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error)
 }
 
+const subscriptionColumns = `id, org_id, service_name, price, user_id, start_date, end_date, created_at, updated_at, cost_center, billing_period`
+
+// tagsColumn selects the tags of the row of the subscriptions table, for
+// scanTaggedSubscription.
+const tagsColumn = `ARRAY(SELECT t.name FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+                          WHERE st.subscription_id = subscriptions.id ORDER BY t.name)`
+
 type pgRepo struct {
-	db *sql.DB
+	db           *sql.DB
+	replica      *sql.DB
+	queryTimeout time.Duration
+}
+
+type Option func(*pgRepo)
+
+// WithQueryTimeout bounds every query; the caller's context (e.g. a
+// cancelled HTTP request) still cancels it earlier.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(p *pgRepo) {
+		p.queryTimeout = d
+	}
 }
 
-func NewPGRepo(db *sql.DB) SubscriptionRepo {
-	return &pgRepo{db: db}
+func NewPGRepo(db *sql.DB, opts ...Option) SubscriptionRepo {
+	return newPGRepo(db, opts)
 }
 
-func (p *pgRepo) Create(ctx context.Context, s *model.Subscription) error {
+func newPGRepo(db *sql.DB, opts []Option) *pgRepo {
+	p := &pgRepo{db: db}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *pgRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.queryTimeout)
+}
+
+func (p *pgRepo) Create(ctx context.Context, s *model.Subscription) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+	s.OrgID = orgID
+
 	query := `INSERT INTO subscriptions
-      (id, service_name, price, user_id, start_date, end_date, created_at, updated_at)
-      VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
-	_, err := p.db.ExecContext(ctx, query,
-		s.ID, s.ServiceName, s.Price, s.UserID, s.StartDate, s.EndDate, s.CreatedAt, s.UpdatedAt)
-	return err
+      (id, org_id, service_name, price, user_id, start_date, end_date, created_at, updated_at, cost_center, billing_period)
+      VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`
+	ctx, span := startQuerySpan(ctx, "Create", query)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query,
+			s.ID, s.OrgID, s.ServiceName, s.Price, s.UserID, s.StartDate, s.EndDate, s.CreatedAt, s.UpdatedAt, s.CostCenter, s.BillingPeriod); err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, model.EventSubscriptionCreated, s, nil)
+	})
 }
 
-func (p *pgRepo) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
-	q := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
-          FROM subscriptions WHERE id = $1`
-	row := p.db.QueryRowContext(ctx, q, id)
-	s := &model.Subscription{}
-	var end sql.NullTime
-	if err := row.Scan(&s.ID, &s.ServiceName, &s.Price, &s.UserID, &s.StartDate, &end, &s.CreatedAt, &s.UpdatedAt); err != nil {
+func (p *pgRepo) GetByID(ctx context.Context, id string) (s *model.Subscription, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + subscriptionColumns + `, ` + tagsColumn + `
+          FROM subscriptions WHERE id = $1 AND org_id = $2`
+	ctx, span := startQuerySpan(ctx, "GetByID", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		s, err = scanTaggedSubscription(db.QueryRowContext(ctx, q, id, orgID))
+		return err
+	})
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	if end.Valid {
-		s.EndDate = &end.Time
-	}
 	return s, nil
 }
 
-func (p *pgRepo) Update(ctx context.Context, s *model.Subscription) error {
-	q := `UPDATE subscriptions SET service_name=$1, price=$2, user_id=$3, start_date=$4, end_date=$5, updated_at=$6
-          WHERE id=$7`
-	res, err := p.db.ExecContext(ctx, q, s.ServiceName, s.Price, s.UserID, s.StartDate, s.EndDate, s.UpdatedAt, s.ID)
+func (p *pgRepo) Update(ctx context.Context, s *model.Subscription) (err error) {
+	orgID, err := tenant.OrgID(ctx)
 	if err != nil {
 		return err
 	}
-	if ra, _ := res.RowsAffected(); ra == 0 {
-		return ErrNotFound
-	}
-	return nil
+
+	q := `UPDATE subscriptions SET service_name=$1, price=$2, user_id=$3, start_date=$4, end_date=$5, updated_at=$6, cost_center=$7, billing_period=$8
+          WHERE id=$9 AND org_id=$10`
+	ctx, span := startQuerySpan(ctx, "Update", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		prev, err := scanSubscription(tx.QueryRowContext(ctx,
+			`SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1 AND org_id = $2 FOR UPDATE`, s.ID, orgID))
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, q, s.ServiceName, s.Price, s.UserID, s.StartDate, s.EndDate, s.UpdatedAt, s.CostCenter, s.BillingPeriod, s.ID, orgID); err != nil {
+			return err
+		}
+		s.OrgID = orgID
+		if prev.Price != s.Price {
+			if err := checkShares(ctx, tx, s.ID, s.Price); err != nil {
+				return err
+			}
+			if err := recordPriceChange(ctx, tx, prev, s); err != nil {
+				return err
+			}
+		}
+		return insertEvent(ctx, tx, model.EventSubscriptionUpdated, s, prev)
+	})
 }
 
-func (p *pgRepo) Delete(ctx context.Context, id string) error {
-	q := `DELETE FROM subscriptions WHERE id = $1`
-	res, err := p.db.ExecContext(ctx, q, id)
+func (p *pgRepo) Delete(ctx context.Context, id string) (err error) {
+	orgID, err := tenant.OrgID(ctx)
 	if err != nil {
 		return err
 	}
-	if ra, _ := res.RowsAffected(); ra == 0 {
-		return ErrNotFound
-	}
-	return nil
+
+	q := `DELETE FROM subscriptions WHERE id = $1 AND org_id = $2 RETURNING ` + subscriptionColumns
+	ctx, span := startQuerySpan(ctx, "Delete", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		deleted, err := scanSubscription(tx.QueryRowContext(ctx, q, id, orgID))
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, model.EventSubscriptionDeleted, deleted, nil)
+	})
 }
 
-func (p *pgRepo) List(ctx context.Context, filter ListFilter) ([]*model.Subscription, error) {
-	q := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
+func (p *pgRepo) List(ctx context.Context, filter ListFilter) (out []*model.Subscription, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + subscriptionColumns + `, ` + tagsColumn + `
           FROM subscriptions
-          WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
-            AND ($2::text IS NULL OR service_name = $2::text)
+          WHERE org_id = $1
+            AND ($2::uuid IS NULL OR user_id = $2::uuid)
+            AND ($3::text IS NULL OR service_name = $3::text)
+            AND ($4::text IS NULL OR cost_center = $4::text)
+            AND ($5::text IS NULL OR EXISTS (
+              SELECT 1 FROM subscription_tags st JOIN tags t ON t.id = st.tag_id
+              WHERE st.subscription_id = subscriptions.id AND t.name = $5::text))
           ORDER BY created_at DESC
-          LIMIT $3 OFFSET $4`
+          LIMIT $6 OFFSET $7`
+	ctx, span := startQuerySpan(ctx, "List", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	var uid, sname interface{}
+	var uid, sname, center, tag interface{}
 	if filter.UserID != nil {
 		uid = *filter.UserID
 	}
 	if filter.ServiceName != nil {
 		sname = *filter.ServiceName
 	}
-
-	rows, err := p.db.QueryContext(ctx, q, uid, sname, filter.Limit, filter.Offset)
-	if err != nil {
-		return nil, err
+	if filter.CostCenter != nil {
+		center = *filter.CostCenter
+	}
+	if filter.Tag != nil {
+		tag = *filter.Tag
 	}
-	defer rows.Close()
 
-	var out []*model.Subscription
-	for rows.Next() {
-		s := &model.Subscription{}
-		var end sql.NullTime
-		if err := rows.Scan(&s.ID, &s.ServiceName, &s.Price, &s.UserID, &s.StartDate, &end, &s.CreatedAt, &s.UpdatedAt); err != nil {
-			return nil, err
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, uid, sname, center, tag, filter.Limit, filter.Offset)
+		if err != nil {
+			return err
 		}
-		if end.Valid {
-			s.EndDate = &end.Time
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			s, err := scanTaggedSubscription(rows)
+			if err != nil {
+				return err
+			}
+			out = append(out, s)
 		}
-		out = append(out, s)
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 	return out, nil
 }
 
-func (p *pgRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error) {
-	q := `SELECT COALESCE(SUM(price),0)
-          FROM subscriptions
-          WHERE start_date <= $1
-            AND end_date >= $2
-            AND ($3::uuid IS NULL OR user_id = $3::uuid)
-            AND ($4::text IS NULL OR service_name = $4::text)`
+func (p *pgRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (total int64, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	q := `SELECT COALESCE(ROUND(SUM(` + periodCost + `)),0)::bigint
+          FROM subscription_costs s` + scheduledPrice("$3::date") + `
+          WHERE s.org_id = $1
+            AND ` + activeDuring("$3::date", "$2::date") + `
+            AND ($4::uuid IS NULL OR s.user_id = $4::uuid)
+            AND ($5::text IS NULL OR s.service_name = $5::text)`
+	ctx, span := startQuerySpan(ctx, "TotalCostForPeriod", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	var uid, sname interface{}
 	if userID != nil {
@@ -140,7 +296,138 @@ func (p *pgRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, use
 		sname = *serviceName
 	}
 
-	var total int64
-	err := p.db.QueryRowContext(ctx, q, to, from, uid, sname).Scan(&total)
+	err = p.read(ctx, func(db *sql.DB) error {
+		return db.QueryRowContext(ctx, q, orgID, to, from, uid, sname).Scan(&total)
+	})
 	return total, err
 }
+
+// activeDuring is the cost rule shared by totals, reports, budgets and
+// forecasts: a subscription "s" costs periodCost in every period [from, to]
+// it overlaps, and one without end_date runs indefinitely. Costs are read
+// from the subscription_costs view, which splits the price of shared
+// subscriptions between their members.
+func activeDuring(from, to string) string {
+	return `s.start_date <= ` + to + ` AND (s.end_date IS NULL OR s.end_date >= ` + from + `)`
+}
+
+// scheduledPrice joins "sp", the latest price scheduled for subscription
+// "s" at or before the date at, which starts the period being costed.
+func scheduledPrice(at string) string {
+	return `
+          LEFT JOIN LATERAL (
+            SELECT ps.price FROM price_schedule ps
+            WHERE ps.subscription_id = s.subscription_id AND ps.effective_from <= ` + at + `
+            ORDER BY ps.effective_from DESC
+            LIMIT 1
+          ) sp ON true`
+}
+
+// periodCost is the cost of "s" in a period: its scheduled price if one is
+// due by then, or its current price.
+var periodCost = costAt("COALESCE(sp.price, s.price)")
+
+// costAt is the cost column of subscription_costs "s" computed for another
+// price of the subscription, e.g. a scheduled one: fixed amounts of members
+// stay, shares split the rest, and the price is spread over the billing
+// period. costAt("s.price") equals s.cost.
+func costAt(price string) string {
+	return `COALESCE(s.amount::numeric, GREATEST(` + price + ` - s.fixed, 0) * s.share_percent / 100) / s.billing_period`
+}
+
+// recordPriceChange keeps the price history that price anomalies are
+// based on.
+func recordPriceChange(ctx context.Context, tx *sql.Tx, prev, s *model.Subscription) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO subscription_price_changes (org_id, subscription_id, old_price, new_price, changed_at) VALUES ($1,$2,$3,$4,$5)`,
+		s.OrgID, s.ID, prev.Price, s.Price, s.UpdatedAt)
+	return err
+}
+
+func (p *pgRepo) FindOverlapping(ctx context.Context, sub *model.Subscription) (out []*model.Subscription, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + subscriptionColumns + `
+          FROM subscriptions s
+          WHERE s.org_id = $1
+            AND s.user_id = $2
+            AND ` + serviceKey("s.service_name") + ` = ` + serviceKey("$3") + `
+            AND s.id <> $4
+            AND ` + activeDuring("$5", "COALESCE($6, 'infinity'::date)") + `
+          ORDER BY s.start_date`
+	ctx, span := startQuerySpan(ctx, "FindOverlapping", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, sub.UserID, sub.ServiceName, sub.ID, sub.StartDate, sub.EndDate)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			s, err := scanSubscription(rows)
+			if err != nil {
+				return err
+			}
+			out = append(out, s)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// inTx runs fn in a transaction on the primary, so that a mutation and its
+// outbox event are committed together.
+func (p *pgRepo) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row rowScanner, extra ...interface{}) (*model.Subscription, error) {
+	s := &model.Subscription{}
+	var end sql.NullTime
+	var center sql.NullString
+	dest := append([]interface{}{&s.ID, &s.OrgID, &s.ServiceName, &s.Price, &s.UserID, &s.StartDate, &end, &s.CreatedAt, &s.UpdatedAt, &center, &s.BillingPeriod}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if end.Valid {
+		s.EndDate = &end.Time
+	}
+	if center.Valid {
+		s.CostCenter = &center.String
+	}
+	return s, nil
+}
+
+// scanTaggedSubscription scans subscriptionColumns followed by tagsColumn.
+func scanTaggedSubscription(row rowScanner) (*model.Subscription, error) {
+	var tags []string
+	s, err := scanSubscription(row, pq.Array(&tags))
+	if err != nil {
+		return nil, err
+	}
+	s.Tags = tags
+	return s, nil
+}
@@ -9,18 +9,40 @@ import (
 
 	"subscription-service/internal/model"
 	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+var testOrgID = uuid.New().String()
+
 func newMock() (*sql.DB, sqlmock.Sqlmock, repository.SubscriptionRepo) {
 	db, mock, _ := sqlmock.New()
 	repo := repository.NewPGRepo(db)
 	return db, mock, repo
 }
 
+func orgCtx() context.Context {
+	return tenant.WithOrgID(context.Background(), testOrgID)
+}
+
+func subscriptionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "org_id", "service_name", "price", "user_id", "start_date", "end_date", "created_at", "updated_at", "cost_center", "billing_period"})
+}
+
+func taggedSubscriptionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "org_id", "service_name", "price", "user_id", "start_date", "end_date", "created_at", "updated_at", "cost_center", "billing_period", "tags"})
+}
+
+func expectEvent(mock sqlmock.Sqlmock, eventType, subscriptionID string) {
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox`)).
+		WithArgs(sqlmock.AnyArg(), testOrgID, eventType, subscriptionID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func TestCreate_Success(t *testing.T) {
 	db, mock, repo := newMock()
 	defer db.Close()
@@ -36,12 +58,16 @@ func TestCreate_Success(t *testing.T) {
 		UpdatedAt:   time.Now(),
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscriptions`)).
-		WithArgs(sub.ID, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.CreatedAt, sub.UpdatedAt).
+		WithArgs(sub.ID, testOrgID, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.CreatedAt, sub.UpdatedAt, sub.CostCenter, sub.BillingPeriod).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, "subscription.created", sub.ID)
+	mock.ExpectCommit()
 
-	err := repo.Create(context.Background(), sub)
+	err := repo.Create(orgCtx(), sub)
 	assert.NoError(t, err)
+	assert.Equal(t, testOrgID, sub.OrgID)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -52,18 +78,20 @@ func TestGetByID_Found(t *testing.T) {
 	id := uuid.New().String()
 	now := time.Now()
 
-	rows := sqlmock.NewRows([]string{
-		"id", "service_name", "price", "user_id", "start_date", "end_date", "created_at", "updated_at",
-	}).AddRow(id, "Spotify", int64(299), uuid.New().String(), now, now.AddDate(0, 1, 0), now, now)
+	rows := taggedSubscriptionRows().
+		AddRow(id, testOrgID, "Spotify", int64(299), uuid.New().String(), now, now.AddDate(0, 1, 0), now, now, "growth", 1, "{marketing,q3}")
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at FROM subscriptions WHERE id = $1`)).
-		WithArgs(id).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id, service_name, price, user_id, start_date, end_date, created_at, updated_at, cost_center, billing_period, ARRAY(`)).
+		WithArgs(id, testOrgID).
 		WillReturnRows(rows)
 
-	sub, err := repo.GetByID(context.Background(), id)
-	assert.NoError(t, err)
+	sub, err := repo.GetByID(orgCtx(), id)
+	require.NoError(t, err)
 	assert.Equal(t, id, sub.ID)
 	assert.Equal(t, "Spotify", sub.ServiceName)
+	require.NotNil(t, sub.CostCenter)
+	assert.Equal(t, "growth", *sub.CostCenter)
+	assert.Equal(t, []string{"marketing", "q3"}, sub.Tags)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -73,10 +101,10 @@ func TestGetByID_NotFound(t *testing.T) {
 
 	id := uuid.New().String()
 	mock.ExpectQuery("SELECT (.+) FROM subscriptions WHERE id =").
-		WithArgs(id).
+		WithArgs(id, testOrgID).
 		WillReturnError(sql.ErrNoRows)
 
-	sub, err := repo.GetByID(context.Background(), id)
+	sub, err := repo.GetByID(orgCtx(), id)
 	assert.Nil(t, sub)
 	assert.ErrorIs(t, err, repository.ErrNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -96,25 +124,75 @@ func TestUpdate_Success(t *testing.T) {
 		UpdatedAt:   time.Now(),
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE subscriptions SET service_name=$1, price=$2, user_id=$3, start_date=$4, end_date=$5, updated_at=$6 WHERE id=$7`)).
-		WithArgs(sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.UpdatedAt, sub.ID).
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM subscriptions WHERE id = $1 AND org_id = $2 FOR UPDATE`)).
+		WithArgs(sub.ID, testOrgID).
+		WillReturnRows(subscriptionRows().AddRow(sub.ID, testOrgID, "Netflix", 499, sub.UserID, sub.StartDate, nil, sub.StartDate, sub.StartDate, nil, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE subscriptions SET service_name=$1, price=$2, user_id=$3, start_date=$4, end_date=$5, updated_at=$6, cost_center=$7, billing_period=$8 WHERE id=$9 AND org_id=$10`)).
+		WithArgs(sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.UpdatedAt, sub.CostCenter, sub.BillingPeriod, sub.ID, testOrgID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectShares(mock, sub.ID, 0, 0, 0)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscription_price_changes`)).
+		WithArgs(testOrgID, sub.ID, 499, sub.Price, sub.UpdatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(mock, "subscription.updated", sub.ID)
+	mock.ExpectCommit()
 
-	err := repo.Update(context.Background(), sub)
+	err := repo.Update(orgCtx(), sub)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestUpdate_SamePriceKeepsHistory(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	sub := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 499, UserID: uuid.New().String(), StartDate: time.Now(), UpdatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(subscriptionRows().AddRow(sub.ID, testOrgID, "Netflix", 499, sub.UserID, sub.StartDate, nil, sub.StartDate, sub.StartDate, nil, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE subscriptions`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEvent(mock, "subscription.updated", sub.ID)
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Update(orgCtx(), sub))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdate_PriceBelowFixedShares(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	sub := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 500, UserID: uuid.New().String(), StartDate: time.Now(), UpdatedAt: time.Now()}
+
+	// The members are read under the subscription's lock, which SetMembers
+	// takes as well.
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(subscriptionRows().AddRow(sub.ID, testOrgID, "Netflix", 1000, sub.UserID, sub.StartDate, nil, sub.StartDate, sub.StartDate, nil, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE subscriptions`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectShares(mock, sub.ID, 2, 1, 800)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, repo.Update(orgCtx(), sub), repository.ErrShares)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdate_NotFound(t *testing.T) {
 	db, mock, repo := newMock()
 	defer db.Close()
 
 	sub := &model.Subscription{ID: uuid.New().String()}
 
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE subscriptions`)).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(subscriptionRows())
+	mock.ExpectRollback()
 
-	err := repo.Update(context.Background(), sub)
+	err := repo.Update(orgCtx(), sub)
 	assert.ErrorIs(t, err, repository.ErrNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -124,11 +202,14 @@ func TestDelete_Success(t *testing.T) {
 	defer db.Close()
 
 	id := uuid.New().String()
-	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM subscriptions WHERE id = $1`)).
-		WithArgs(id).
-		WillReturnResult(sqlmock.NewResult(0, 1))
-
-	err := repo.Delete(context.Background(), id)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM subscriptions WHERE id = $1 AND org_id = $2 RETURNING`)).
+		WithArgs(id, testOrgID).
+		WillReturnRows(subscriptionRows().AddRow(id, testOrgID, "Netflix", 499, uuid.New().String(), time.Now(), nil, time.Now(), time.Now(), nil, 1))
+	expectEvent(mock, "subscription.deleted", id)
+	mock.ExpectCommit()
+
+	err := repo.Delete(orgCtx(), id)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -138,11 +219,13 @@ func TestDelete_NotFound(t *testing.T) {
 	defer db.Close()
 
 	id := uuid.New().String()
-	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM subscriptions WHERE id = $1`)).
-		WithArgs(id).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM subscriptions WHERE id = $1 AND org_id = $2 RETURNING`)).
+		WithArgs(id, testOrgID).
+		WillReturnRows(subscriptionRows())
+	mock.ExpectRollback()
 
-	err := repo.Delete(context.Background(), id)
+	err := repo.Delete(orgCtx(), id)
 	assert.ErrorIs(t, err, repository.ErrNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -152,18 +235,19 @@ func TestList_Success(t *testing.T) {
 	defer db.Close()
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{
-		"id", "service_name", "price", "user_id", "start_date", "end_date", "created_at", "updated_at",
-	}).AddRow(uuid.New().String(), "Netflix", int64(499), uuid.New().String(), now, now, now, now)
+	rows := taggedSubscriptionRows().
+		AddRow(uuid.New().String(), testOrgID, "Netflix", int64(499), uuid.New().String(), now, now, now, now, nil, 1, "{}")
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at FROM subscriptions`)).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 10, 0).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id, service_name, price, user_id, start_date, end_date, created_at, updated_at, cost_center, billing_period, ARRAY(`)).
+		WithArgs(testOrgID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 10, 0).
 		WillReturnRows(rows)
 
-	list, err := repo.List(context.Background(), repository.ListFilter{Limit: 10, Offset: 0})
+	list, err := repo.List(orgCtx(), repository.ListFilter{Limit: 10, Offset: 0})
 	assert.NoError(t, err)
 	assert.Len(t, list, 1)
 	assert.Equal(t, "Netflix", list[0].ServiceName)
+	assert.Nil(t, list[0].CostCenter)
+	assert.Empty(t, list[0].Tags)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -175,12 +259,95 @@ func TestTotalCostForPeriod_Success(t *testing.T) {
 	to := time.Now()
 	var total int64 = 1500
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(price),0)`)).
-		WithArgs(to, from, nil, nil).
+	// The forecast and budgets use the same predicate and scheduled prices.
+	mock.ExpectQuery(regexp.QuoteMeta(`ps.effective_from <= $3::date`)+`(?s).*`+
+		regexp.QuoteMeta(`s.start_date <= $2::date AND (s.end_date IS NULL OR s.end_date >= $3::date)`)).
+		WithArgs(testOrgID, to, from, nil, nil).
 		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(total))
 
-	got, err := repo.TotalCostForPeriod(context.Background(), from, to, nil, nil)
+	got, err := repo.TotalCostForPeriod(orgCtx(), from, to, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, total, got)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestTotalCostForPeriod_AttributesShares(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	from, to := time.Now().AddDate(0, -1, 0), time.Now()
+	userID := uuid.New().String()
+	mock.ExpectQuery(regexp.QuoteMeta(`s.share_percent / 100) / s.billing_period)),0)::bigint FROM subscription_costs s`)).
+		WithArgs(testOrgID, to, from, userID, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(250))
+
+	got, err := repo.TotalCostForPeriod(orgCtx(), from, to, &userID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := repository.NewPGRepo(db, repository.WithQueryTimeout(10*time.Millisecond))
+
+	id := uuid.New().String()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, org_id`)).
+		WithArgs(id, testOrgID).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repo.GetByID(orgCtx(), id)
+	assert.Error(t, err, "query is cancelled once the timeout elapses")
+}
+
+func TestQueries_RequireTenant(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	ctx := context.Background()
+	id := uuid.New().String()
+
+	_, err := repo.GetByID(ctx, id)
+	assert.ErrorIs(t, err, tenant.ErrMissing)
+	_, err = repo.List(ctx, repository.ListFilter{Limit: 10})
+	assert.ErrorIs(t, err, tenant.ErrMissing)
+	_, err = repo.TotalCostForPeriod(ctx, time.Now().AddDate(0, -1, 0), time.Now(), nil, nil)
+	assert.ErrorIs(t, err, tenant.ErrMissing)
+	assert.ErrorIs(t, repo.Delete(ctx, id), tenant.ErrMissing)
+	assert.ErrorIs(t, repo.Update(ctx, &model.Subscription{ID: id}), tenant.ErrMissing)
+	assert.ErrorIs(t, repo.Create(ctx, &model.Subscription{ID: id}), tenant.ErrMissing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreate_RollsBackWhenEventFails(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	sub := &model.Subscription{ID: uuid.New().String(), StartDate: time.Now()}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscriptions`)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox`)).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	assert.Error(t, repo.Create(orgCtx(), sub))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindOverlapping(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	sub := &model.Subscription{ID: "new", UserID: "u1", ServiceName: "Netflix", StartDate: start}
+	mock.ExpectQuery(regexp.QuoteMeta(`s.start_date <= COALESCE($6, 'infinity'::date) AND (s.end_date IS NULL OR s.end_date >= $5)`)).
+		WithArgs(testOrgID, "u1", "Netflix", "new", start, nil).
+		WillReturnRows(subscriptionRows().AddRow("old", testOrgID, "netflix", 499, "u1", start, nil, start, start, nil, 1))
+
+	got, err := repo.FindOverlapping(orgCtx(), sub)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "old", got[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+
+	"github.com/lib/pq"
+)
+
+func (p *pgRepo) SetTags(ctx context.Context, id string, tags []string) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO subscription_tags (subscription_id, tag_id)
+          SELECT $1, t.id FROM tags t WHERE t.org_id = $2 AND t.name = ANY($3)`
+	ctx, span := startQuerySpan(ctx, "SetTags", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if err := lockSubscriptions(ctx, tx, orgID, []string{id}); err != nil {
+			return err
+		}
+		if err := createTags(ctx, tx, orgID, tags); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM subscription_tags WHERE subscription_id = $1`, id); err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, q, id, orgID, pq.Array(tags))
+		return err
+	})
+}
+
+func (p *pgRepo) TagSubscriptions(ctx context.Context, ids, add, remove []string) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO subscription_tags (subscription_id, tag_id)
+          SELECT s.id, t.id FROM unnest($1::uuid[]) AS s(id)
+          CROSS JOIN tags t
+          WHERE t.org_id = $2 AND t.name = ANY($3)
+          ON CONFLICT DO NOTHING`
+	ctx, span := startQuerySpan(ctx, "TagSubscriptions", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if err := lockSubscriptions(ctx, tx, orgID, ids); err != nil {
+			return err
+		}
+		if len(add) > 0 {
+			if err := createTags(ctx, tx, orgID, add); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, q, pq.Array(ids), orgID, pq.Array(add)); err != nil {
+				return err
+			}
+		}
+		if len(remove) > 0 {
+			if _, err := tx.ExecContext(ctx,
+				`DELETE FROM subscription_tags st USING tags t
+                 WHERE t.id = st.tag_id AND t.org_id = $1 AND st.subscription_id = ANY($2::uuid[]) AND t.name = ANY($3)`,
+				orgID, pq.Array(ids), pq.Array(remove)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *pgRepo) ListTags(ctx context.Context, userID *string) (out []model.Tag, err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT t.name, COUNT(s.id)
+          FROM tags t
+          LEFT JOIN subscription_tags st ON st.tag_id = t.id
+          LEFT JOIN subscriptions s ON s.id = st.subscription_id AND ($2::uuid IS NULL OR s.user_id = $2::uuid)
+          WHERE t.org_id = $1
+          GROUP BY t.name
+          ORDER BY t.name`
+	ctx, span := startQuerySpan(ctx, "ListTags", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var uid interface{}
+	if userID != nil {
+		uid = *userID
+	}
+
+	err = p.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, q, orgID, uid)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out = nil
+		for rows.Next() {
+			var t model.Tag
+			if err := rows.Scan(&t.Name, &t.Subscriptions); err != nil {
+				return err
+			}
+			out = append(out, t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *pgRepo) DeleteTag(ctx context.Context, name string) (err error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	q := `DELETE FROM tags WHERE org_id = $1 AND name = $2`
+	ctx, span := startQuerySpan(ctx, "DeleteTag", q)
+	defer func() { endQuerySpan(span, err) }()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, q, orgID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// lockSubscriptions locks ids for the rest of tx, or returns ErrNotFound if
+// one of them is not a subscription of orgID.
+func lockSubscriptions(ctx context.Context, tx *sql.Tx, orgID string, ids []string) error {
+	var n int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM (SELECT id FROM subscriptions WHERE org_id = $1 AND id = ANY($2::uuid[]) FOR UPDATE) l`,
+		orgID, pq.Array(ids)).Scan(&n); err != nil {
+		return err
+	}
+	if n != len(ids) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func createTags(ctx context.Context, tx *sql.Tx, orgID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO tags (org_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (org_id, name) DO NOTHING`,
+		orgID, pq.Array(tags))
+	return err
+}
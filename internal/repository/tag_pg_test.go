@@ -0,0 +1,107 @@
+package repository_test
+
+import (
+	"regexp"
+	"testing"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetTags(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	id := uuid.New().String()
+	tags := []string{"marketing", "q3"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WithArgs(testOrgID, pq.Array([]string{id})).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO tags (org_id, name)`)).
+		WithArgs(testOrgID, pq.Array(tags)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM subscription_tags WHERE subscription_id = $1`)).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscription_tags`)).
+		WithArgs(id, testOrgID, pq.Array(tags)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.SetTags(orgCtx(), id, tags))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagSubscriptions(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	ids := []string{uuid.New().String(), uuid.New().String()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WithArgs(testOrgID, pq.Array(ids)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO tags (org_id, name)`)).
+		WithArgs(testOrgID, pq.Array([]string{"marketing"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO subscription_tags`)).
+		WithArgs(pq.Array(ids), testOrgID, pq.Array([]string{"marketing"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM subscription_tags st USING tags t`)).
+		WithArgs(testOrgID, pq.Array(ids), pq.Array([]string{"sales"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.TagSubscriptions(orgCtx(), ids, []string{"marketing"}, []string{"sales"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagSubscriptions_MissingSubscription(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	err := repo.TagSubscriptions(orgCtx(), []string{uuid.New().String(), uuid.New().String()}, []string{"marketing"}, nil)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListTags(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	userID := uuid.New().String()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM tags t`)).
+		WithArgs(testOrgID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count"}).AddRow("marketing", 2).AddRow("q3", 0))
+
+	got, err := repo.ListTags(orgCtx(), &userID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Tag{{Name: "marketing", Subscriptions: 2}, {Name: "q3", Subscriptions: 0}}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTag_NotFound(t *testing.T) {
+	db, mock, repo := newMock()
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM tags WHERE org_id = $1 AND name = $2`)).
+		WithArgs(testOrgID, "marketing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, repo.DeleteTag(orgCtx(), "marketing"), repository.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
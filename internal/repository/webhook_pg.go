@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tenant"
+
+	"github.com/lib/pq"
+)
+
+// DeliveryResult is the outcome of one delivery attempt. StatusCode is 0
+// when no response was received.
+type DeliveryResult struct {
+	StatusCode int
+	Err        error
+}
+
+// DeliveryPolicy decides what happens after a failed attempt: the delivery
+// is retried after RetryIn(attempts) until MaxAttempts, and the webhook is
+// disabled once DisableAfter attempts in a row have failed. Lease is how long
+// a batch is reserved for one dispatcher while it is sent.
+type DeliveryPolicy struct {
+	MaxAttempts  int
+	DisableAfter int
+	RetryIn      func(attempts int) time.Duration
+	Lease        time.Duration
+}
+
+type DeliverFunc func(ctx context.Context, w *model.Webhook, d *model.WebhookDelivery) DeliveryResult
+
+type WebhookRepo interface {
+	Create(ctx context.Context, w *model.Webhook) error
+	GetByID(ctx context.Context, id string) (*model.Webhook, error)
+	ListDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error)
+	// ListSubscribed returns the active webhooks of the organization that
+	// subscribed to eventType.
+	ListSubscribed(ctx context.Context, eventType string) ([]*model.Webhook, error)
+	// Enqueue stores pending deliveries, ignoring ones already enqueued for
+	// the same webhook, event and type.
+	Enqueue(ctx context.Context, deliveries []*model.WebhookDelivery) error
+	// ProcessDeliveries is not tenant scoped: it leases due deliveries of all
+	// organizations, calls deliver for each outside of any transaction and
+	// then records the results. Deliveries of a dispatcher that stops
+	// mid-batch are due again once the lease expires.
+	ProcessDeliveries(ctx context.Context, limit int, deliver DeliverFunc, policy DeliveryPolicy) (int, error)
+}
+
+const webhookColumns = `id, org_id, url, secret, event_types, active, consecutive_failures, disabled_at, created_by, created_at`
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+type pgWebhookRepo struct {
+	db *sql.DB
+}
+
+func NewPGWebhookRepo(db *sql.DB) WebhookRepo {
+	return &pgWebhookRepo{db: db}
+}
+
+func (p *pgWebhookRepo) Create(ctx context.Context, w *model.Webhook) error {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+	w.OrgID = orgID
+
+	q := `INSERT INTO webhooks (id, org_id, url, secret, event_types, active, created_by, created_at)
+          VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+	_, err = p.db.ExecContext(ctx, q,
+		w.ID, w.OrgID, w.URL, w.Secret, pq.Array(w.EventTypes), w.Active, w.CreatedBy, w.CreatedAt)
+	return err
+}
+
+func (p *pgWebhookRepo) GetByID(ctx context.Context, id string) (*model.Webhook, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND org_id = $2`
+	w, err := scanWebhook(p.db.QueryRowContext(ctx, q, id, orgID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func (p *pgWebhookRepo) ListDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + deliveryColumns + `
+          FROM webhook_deliveries
+          WHERE webhook_id = $1 AND org_id = $2
+          ORDER BY created_at DESC
+          LIMIT $3 OFFSET $4`
+	rows, err := p.db.QueryContext(ctx, q, webhookID, orgID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgWebhookRepo) ListSubscribed(ctx context.Context, eventType string) ([]*model.Webhook, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + webhookColumns + `
+          FROM webhooks
+          WHERE org_id = $1 AND active AND $2 = ANY(event_types)`
+	rows, err := p.db.QueryContext(ctx, q, orgID, eventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*model.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgWebhookRepo) Enqueue(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO webhook_deliveries (id, webhook_id, org_id, event_id, event_type, payload, created_at)
+          VALUES ($1,$2,$3,$4,$5,$6,$7)
+          ON CONFLICT (webhook_id, event_id, event_type) DO NOTHING`
+	for _, d := range deliveries {
+		if _, err := tx.ExecContext(ctx, q, d.ID, d.WebhookID, orgID, d.EventID, d.EventType, []byte(d.Payload), d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *pgWebhookRepo) ProcessDeliveries(ctx context.Context, limit int, deliver DeliverFunc, policy DeliveryPolicy) (int, error) {
+	batch, err := p.claimDeliveries(ctx, limit, policy.Lease)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+
+	// Receivers may be slow; sending holds no locks or connections.
+	results := make([]DeliveryResult, 0, len(batch))
+	for i := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, deliver(ctx, &batch[i].w, &batch[i].d))
+	}
+
+	// Record what was sent even when ctx was cancelled meanwhile, so a
+	// shutdown does not send the batch again.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for i, res := range results {
+		if err := recordAttempt(ctx, tx, &batch[i].d, res, policy); err != nil {
+			return 0, err
+		}
+	}
+	return len(results), tx.Commit()
+}
+
+type dueDelivery struct {
+	w model.Webhook
+	d model.WebhookDelivery
+}
+
+// claimDeliveries leases due deliveries of active webhooks in one statement;
+// SKIP LOCKED and the lease let every instance run a dispatcher.
+func (p *pgWebhookRepo) claimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]dueDelivery, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`UPDATE webhook_deliveries d SET leased_until = now() + make_interval(secs => $2)
+         FROM (
+           SELECT d.id, w.org_id, w.url, w.secret
+           FROM webhook_deliveries d
+           JOIN webhooks w ON w.id = d.webhook_id
+           WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND w.active
+             AND (d.leased_until IS NULL OR d.leased_until <= now())
+           ORDER BY d.next_attempt_at
+           LIMIT $1
+           FOR UPDATE OF d SKIP LOCKED
+         ) due
+         WHERE d.id = due.id
+         RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at, d.next_attempt_at,
+                   due.org_id, due.url, due.secret`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []dueDelivery
+	for rows.Next() {
+		var x dueDelivery
+		var payload []byte
+		var at time.Time
+		if err := rows.Scan(&x.d.ID, &x.d.WebhookID, &x.d.EventID, &x.d.EventType, &payload, &x.d.Attempts, &x.d.CreatedAt, &at,
+			&x.w.OrgID, &x.w.URL, &x.w.Secret); err != nil {
+			return nil, err
+		}
+		x.d.Payload = payload
+		x.d.NextAttemptAt = &at
+		x.w.ID = x.d.WebhookID
+		batch = append(batch, x)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the subquery's order.
+	slices.SortStableFunc(batch, func(a, b dueDelivery) int { return a.d.NextAttemptAt.Compare(*b.d.NextAttemptAt) })
+	return batch, nil
+}
+
+func recordAttempt(ctx context.Context, tx *sql.Tx, d *model.WebhookDelivery, res DeliveryResult, policy DeliveryPolicy) error {
+	var code sql.NullInt64
+	if res.StatusCode != 0 {
+		code = sql.NullInt64{Int64: int64(res.StatusCode), Valid: true}
+	}
+
+	if res.Err == nil {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE webhook_deliveries SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2,
+                 last_error = NULL, delivered_at = now(), leased_until = NULL WHERE id = $1`, d.ID, code); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1`, d.WebhookID)
+		return err
+	}
+
+	attempts := d.Attempts + 1
+	status := model.DeliveryPending
+	if attempts >= policy.MaxAttempts {
+		status = model.DeliveryFailed
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4, last_error = $5,
+             next_attempt_at = $6, leased_until = NULL WHERE id = $1`,
+		d.ID, status, attempts, code, res.Err.Error(), time.Now().Add(policy.RetryIn(attempts))); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`UPDATE webhooks SET consecutive_failures = consecutive_failures + 1,
+             active = active AND consecutive_failures + 1 < $2,
+             disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $2 THEN now() ELSE disabled_at END
+         WHERE id = $1`, d.WebhookID, policy.DisableAfter)
+	return err
+}
+
+func scanWebhook(row rowScanner) (*model.Webhook, error) {
+	w := &model.Webhook{}
+	var disabled sql.NullTime
+	if err := row.Scan(&w.ID, &w.OrgID, &w.URL, &w.Secret, pq.Array(&w.EventTypes), &w.Active, &w.ConsecutiveFailures,
+		&disabled, &w.CreatedBy, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	if disabled.Valid {
+		w.DisabledAt = &disabled.Time
+	}
+	return w, nil
+}
+
+func scanDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	d := &model.WebhookDelivery{}
+	var (
+		payload   []byte
+		next      time.Time
+		code      sql.NullInt64
+		lastErr   sql.NullString
+		delivered sql.NullTime
+	)
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &next,
+		&code, &lastErr, &d.CreatedAt, &delivered); err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	if d.Status == model.DeliveryPending {
+		d.NextAttemptAt = &next
+	}
+	if code.Valid {
+		c := int(code.Int64)
+		d.LastStatusCode = &c
+	}
+	d.LastError = lastErr.String
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return d, nil
+}
@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessDeliveries_FailureDisablesWebhook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGWebhookRepo(db)
+
+	// The lease is committed on its own; the delivery is sent before the
+	// transaction that records the outcome begins.
+	mock.ExpectQuery(regexp.QuoteMeta(`SET leased_until = now() + make_interval(secs => $2)`)).
+		WithArgs(5, 120.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event_type", "payload", "attempts", "created_at", "next_attempt_at", "org_id", "url", "secret"}).
+			AddRow("d1", "w1", "e1", "subscription.created", []byte(`{}`), 2, time.Now(), time.Now(), testOrgID, "https://example.com", "whsec_x"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status = $2, attempts = $3`)+`(?s).*`+regexp.QuoteMeta(`leased_until = NULL`)).
+		WithArgs("d1", model.DeliveryFailed, 3, 502, "status 502", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE webhooks SET consecutive_failures = consecutive_failures + 1`)).
+		WithArgs("w1", 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.ProcessDeliveries(context.Background(), 5,
+		func(_ context.Context, w *model.Webhook, d *model.WebhookDelivery) repository.DeliveryResult {
+			assert.Equal(t, "whsec_x", w.Secret)
+			return repository.DeliveryResult{StatusCode: 502, Err: errors.New("status 502")}
+		},
+		repository.DeliveryPolicy{MaxAttempts: 3, DisableAfter: 10, RetryIn: func(int) time.Duration { return time.Minute }, Lease: 2 * time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListSubscribed_RequiresTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPGWebhookRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`$2 = ANY(event_types)`)).
+		WithArgs(testOrgID, "subscription.created").
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	_, err = repo.ListSubscribed(context.Background(), "subscription.created")
+	assert.Error(t, err)
+	_, err = repo.ListSubscribed(orgCtx(), "subscription.created")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
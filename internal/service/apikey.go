@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type APIKeyService interface {
+	CreateKey(ctx context.Context, in CreateAPIKeyInput) (*model.APIKey, string, error)
+	ListKeys(ctx context.Context) ([]*model.APIKey, error)
+	RevokeKey(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+type CreateAPIKeyInput struct {
+	Name   string
+	Scopes []string
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepo
+}
+
+func NewAPIKeyService(r repository.APIKeyRepo) APIKeyService {
+	return &apiKeyService{repo: r}
+}
+
+func (s *apiKeyService) CreateKey(ctx context.Context, in CreateAPIKeyInput) (*model.APIKey, string, error) {
+	if strings.TrimSpace(in.Name) == "" || len(in.Scopes) == 0 {
+		return nil, "", ErrInvalid
+	}
+	for _, scope := range in.Scopes {
+		if !auth.IsKnownScope(scope) {
+			return nil, "", ErrInvalid
+		}
+	}
+
+	raw, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	k := &model.APIKey{
+		ID:        uuid.New().String(),
+		Name:      in.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    in.Scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		k.CreatedBy = p.Subject
+	}
+
+	if err := s.repo.Create(ctx, k); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("repo.Create api key failed")
+		return nil, "", err
+	}
+	return k, raw, nil
+}
+
+func (s *apiKeyService) ListKeys(ctx context.Context) ([]*model.APIKey, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *apiKeyService) RevokeKey(ctx context.Context, id string) error {
+	return s.repo.Revoke(ctx, id)
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*auth.Principal, error) {
+	if key == "" {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	k, err := s.repo.GetActiveByHash(ctx, auth.HashAPIKey(key))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, auth.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	scopes := k.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return &auth.Principal{
+		Subject:  "apikey:" + k.ID,
+		OrgID:    k.OrgID,
+		APIKeyID: k.ID,
+		Scopes:   scopes,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAPIKeyRepo struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeyRepo) Create(ctx context.Context, k *model.APIKey) error {
+	return m.Called(ctx, k).Error(0)
+}
+func (m *mockAPIKeyRepo) List(ctx context.Context) ([]*model.APIKey, error) {
+	args := m.Called(ctx)
+	if keys, ok := args.Get(0).([]*model.APIKey); ok {
+		return keys, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockAPIKeyRepo) Revoke(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+func (m *mockAPIKeyRepo) GetActiveByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if k, ok := args.Get(0).(*model.APIKey); ok {
+		return k, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestCreateKey_StoresHashOnly(t *testing.T) {
+	repo := new(mockAPIKeyRepo)
+	svc := service.NewAPIKeyService(repo)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "admin-1", Role: auth.RoleAdmin})
+
+	var stored *model.APIKey
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.APIKey")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*model.APIKey) }).
+		Return(nil)
+
+	k, raw, err := svc.CreateKey(ctx, service.CreateAPIKeyInput{Name: "batch", Scopes: []string{auth.ScopeReportsRead}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, raw)
+	assert.Equal(t, auth.HashAPIKey(raw), stored.KeyHash)
+	assert.NotContains(t, stored.KeyHash, raw)
+	assert.Equal(t, "admin-1", k.CreatedBy)
+	assert.Contains(t, raw, k.Prefix)
+}
+
+func TestCreateKey_Invalid(t *testing.T) {
+	svc := service.NewAPIKeyService(new(mockAPIKeyRepo))
+
+	_, _, err := svc.CreateKey(context.Background(), service.CreateAPIKeyInput{Name: "batch"})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, _, err = svc.CreateKey(context.Background(), service.CreateAPIKeyInput{Name: "batch", Scopes: []string{"everything"}})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, _, err = svc.CreateKey(context.Background(), service.CreateAPIKeyInput{Scopes: []string{auth.ScopeReportsRead}})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	repo := new(mockAPIKeyRepo)
+	svc := service.NewAPIKeyService(repo)
+
+	key := "sk_valid"
+	repo.On("GetActiveByHash", mock.Anything, auth.HashAPIKey(key)).Return(&model.APIKey{
+		ID:     "k1",
+		OrgID:  "org-1",
+		Scopes: []string{auth.ScopeSubscriptionsRead},
+	}, nil)
+	repo.On("GetActiveByHash", mock.Anything, auth.HashAPIKey("sk_revoked")).Return(nil, repository.ErrNotFound)
+
+	p, err := svc.Authenticate(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, "org-1", p.OrgID)
+	assert.True(t, p.IsAPIKey())
+	assert.True(t, p.HasScope(auth.ScopeSubscriptionsRead))
+	assert.False(t, p.HasScope(auth.ScopeSubscriptionsWrite))
+
+	_, err = svc.Authenticate(context.Background(), "sk_revoked")
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"subscription-service/internal/auth"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Access rules shared by all transports:
+//   - admins (and API keys, which are limited by their scopes instead) manage
+//     every subscription of the organization;
+//   - finance additionally reads aggregates for everyone and maintains the
+//     price catalog;
+//   - users only see and mutate their own subscriptions.
+
+func principalFrom(ctx context.Context) (*auth.Principal, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, ErrForbidden
+	}
+	return p, nil
+}
+
+func canManageAll(p *auth.Principal) bool {
+	return p.IsAPIKey() || p.Role == auth.RoleAdmin
+}
+
+func canReadAggregates(p *auth.Principal) bool {
+	return canManageAll(p) || p.Role == auth.RoleFinance
+}
+
+func authorizeOwner(ctx context.Context, userID string) error {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return err
+	}
+	if canManageAll(p) || p.Subject == userID {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// scopeToCaller narrows an optional user filter to the caller unless they may
+// see everyone; asking for somebody else's data is forbidden.
+func scopeToCaller(p *auth.Principal, allowed bool, userID *string) (*string, error) {
+	if allowed {
+		return userID, nil
+	}
+	if userID != nil && *userID != p.Subject {
+		return nil, ErrForbidden
+	}
+	self := p.Subject
+	return &self, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type BudgetService interface {
+	CreateBudget(ctx context.Context, in CreateBudgetInput) (*model.Budget, error)
+	// Status compares the budget with the projected spend of the month
+	// starting at month.
+	Status(ctx context.Context, id string, month time.Time) (*model.BudgetStatus, error)
+}
+
+// CreateBudgetInput describes a monthly budget of UserID, or of the whole
+// organization when UserID is nil.
+type CreateBudgetInput struct {
+	UserID *string
+	Amount int64
+}
+
+type budgetService struct {
+	repo repository.BudgetRepo
+}
+
+func NewBudgetService(r repository.BudgetRepo) BudgetService {
+	return &budgetService{repo: r}
+}
+
+func (s *budgetService) CreateBudget(ctx context.Context, in CreateBudgetInput) (*model.Budget, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.Amount <= 0 {
+		return nil, ErrInvalid
+	}
+	if in.UserID == nil {
+		if !canManageAll(p) {
+			return nil, ErrForbidden
+		}
+	} else {
+		if _, err := uuid.Parse(*in.UserID); err != nil {
+			return nil, ErrInvalid
+		}
+		if err := authorizeOwner(ctx, *in.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	b := &model.Budget{
+		ID:        uuid.New().String(),
+		UserID:    in.UserID,
+		Amount:    in.Amount,
+		CreatedBy: p.Subject,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, b); err != nil {
+		if err != repository.ErrConflict {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("repo.Create budget failed")
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *budgetService) Status(ctx context.Context, id string, month time.Time) (*model.BudgetStatus, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	owner := b.UserID != nil && *b.UserID == p.Subject
+	if !owner && !canReadAggregates(p) {
+		return nil, ErrForbidden
+	}
+
+	used, err := s.repo.MonthlySpend(ctx, month, b.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return b.Status(month, used), nil
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockBudgetRepo struct {
+	mock.Mock
+}
+
+func (m *mockBudgetRepo) Create(ctx context.Context, b *model.Budget) error {
+	return m.Called(ctx, b).Error(0)
+}
+func (m *mockBudgetRepo) GetByID(ctx context.Context, id string) (*model.Budget, error) {
+	args := m.Called(ctx, id)
+	if b, ok := args.Get(0).(*model.Budget); ok {
+		return b, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockBudgetRepo) MonthlySpend(ctx context.Context, month time.Time, userID *string) (int64, error) {
+	args := m.Called(ctx, month, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *mockBudgetRepo) ListAfter(ctx context.Context, afterID string, limit int) ([]*model.Budget, error) {
+	args := m.Called(ctx, afterID, limit)
+	return args.Get(0).([]*model.Budget), args.Error(1)
+}
+func (m *mockBudgetRepo) ClaimAlert(ctx context.Context, a *model.BudgetAlert) (bool, error) {
+	args := m.Called(ctx, a)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockBudgetRepo) ReleaseAlert(ctx context.Context, a *model.BudgetAlert) error {
+	return m.Called(ctx, a).Error(0)
+}
+
+func TestCreateBudget(t *testing.T) {
+	repo := new(mockBudgetRepo)
+	svc := service.NewBudgetService(repo)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Budget")).Return(nil)
+
+	userID := uuid.New().String()
+	b, err := svc.CreateBudget(asUser(userID), service.CreateBudgetInput{UserID: &userID, Amount: 3000})
+	require.NoError(t, err)
+	assert.Equal(t, userID, *b.UserID)
+	assert.Equal(t, userID, b.CreatedBy)
+
+	b, err = svc.CreateBudget(asRole(auth.RoleAdmin), service.CreateBudgetInput{Amount: 100000})
+	require.NoError(t, err)
+	assert.Nil(t, b.UserID)
+}
+
+func TestCreateBudget_Rejected(t *testing.T) {
+	svc := service.NewBudgetService(new(mockBudgetRepo))
+	userID := uuid.New().String()
+	other := uuid.New().String()
+	bad := "not-a-uuid"
+
+	_, err := svc.CreateBudget(asUser(userID), service.CreateBudgetInput{Amount: 1000})
+	assert.ErrorIs(t, err, service.ErrForbidden, "organization budget by a user")
+	_, err = svc.CreateBudget(asUser(userID), service.CreateBudgetInput{UserID: &other, Amount: 1000})
+	assert.ErrorIs(t, err, service.ErrForbidden, "budget of another user")
+	_, err = svc.CreateBudget(asUser(userID), service.CreateBudgetInput{UserID: &userID})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, err = svc.CreateBudget(asRole(auth.RoleAdmin), service.CreateBudgetInput{UserID: &bad, Amount: 1000})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestBudgetStatus(t *testing.T) {
+	repo := new(mockBudgetRepo)
+	svc := service.NewBudgetService(repo)
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	userID := uuid.New().String()
+	b := &model.Budget{ID: uuid.New().String(), UserID: &userID, Amount: 1000}
+	repo.On("GetByID", mock.Anything, b.ID).Return(b, nil)
+	repo.On("MonthlySpend", mock.Anything, month, &userID).Return(int64(1250), nil)
+
+	st, err := svc.Status(asUser(userID), b.ID, month)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1250), st.Used)
+	assert.Equal(t, int64(-250), st.Remaining)
+	assert.Equal(t, int64(125), st.Percent)
+
+	_, err = svc.Status(asRole(auth.RoleFinance), b.ID, month)
+	assert.NoError(t, err)
+
+	_, err = svc.Status(asUser(uuid.New().String()), b.ID, month)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/rs/zerolog"
+)
+
+// CatalogService maintains the reference prices the price anomaly report
+// compares subscriptions with. Everyone may read the catalog.
+type CatalogService interface {
+	SetPrice(ctx context.Context, serviceName string, price int) (*model.CatalogEntry, error)
+	List(ctx context.Context, limit, offset int) ([]*model.CatalogEntry, error)
+	Delete(ctx context.Context, serviceName string) error
+}
+
+type catalogService struct {
+	repo repository.CatalogRepo
+}
+
+func NewCatalogService(r repository.CatalogRepo) CatalogService {
+	return &catalogService{repo: r}
+}
+
+func (s *catalogService) SetPrice(ctx context.Context, serviceName string, price int) (*model.CatalogEntry, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !canReadAggregates(p) {
+		return nil, ErrForbidden
+	}
+	serviceName = strings.TrimSpace(serviceName)
+	if serviceName == "" || price < 0 {
+		return nil, ErrInvalid
+	}
+
+	e := &model.CatalogEntry{
+		ServiceName: serviceName,
+		Price:       price,
+		UpdatedBy:   p.Subject,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := s.repo.Put(ctx, e); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("repo.Put catalog entry failed")
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *catalogService) List(ctx context.Context, limit, offset int) ([]*model.CatalogEntry, error) {
+	if _, err := principalFrom(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, limit, offset)
+}
+
+func (s *catalogService) Delete(ctx context.Context, serviceName string) error {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return err
+	}
+	if !canReadAggregates(p) {
+		return ErrForbidden
+	}
+	return s.repo.Delete(ctx, serviceName)
+}
@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCatalogRepo struct {
+	mock.Mock
+}
+
+func (m *mockCatalogRepo) Put(ctx context.Context, e *model.CatalogEntry) error {
+	return m.Called(ctx, e).Error(0)
+}
+func (m *mockCatalogRepo) List(ctx context.Context, limit, offset int) ([]*model.CatalogEntry, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]*model.CatalogEntry), args.Error(1)
+}
+func (m *mockCatalogRepo) Delete(ctx context.Context, serviceName string) error {
+	return m.Called(ctx, serviceName).Error(0)
+}
+
+func TestSetCatalogPrice(t *testing.T) {
+	repo := new(mockCatalogRepo)
+	svc := service.NewCatalogService(repo)
+	repo.On("Put", mock.Anything, mock.AnythingOfType("*model.CatalogEntry")).Return(nil)
+
+	e, err := svc.SetPrice(asRole(auth.RoleFinance), " Netflix ", 499)
+	require.NoError(t, err)
+	assert.Equal(t, "Netflix", e.ServiceName)
+	assert.Equal(t, 499, e.Price)
+
+	_, err = svc.SetPrice(asRole(auth.RoleAdmin), "Netflix", -1)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+
+	_, err = svc.SetPrice(asUser(uuid.New().String()), "Netflix", 499)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	assert.ErrorIs(t, svc.Delete(asUser(uuid.New().String()), "Netflix"), service.ErrForbidden)
+	repo.AssertNumberOfCalls(t, "Put", 1)
+}
@@ -0,0 +1,464 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+)
+
+// MaxForecastMonths bounds the forecast horizon.
+const MaxForecastMonths = 60
+
+// Bounds of PriceAnomalyInput.
+const (
+	MaxAnomalyThreshold = 1000
+	MaxPriceHikeDays    = 3650
+)
+
+// MaxChurnMonths bounds the range of the churn report.
+const MaxChurnMonths = 60
+
+// Shape of UserSummary.
+const (
+	summaryRenewalDays = 30
+	summaryTopServices = 5
+)
+
+// MaxTopLimit bounds how many users and services Top ranks.
+const MaxTopLimit = 100
+
+// anomalyMinPeers is how many other users must pay for a service before
+// their median price is used as a reference. Users who may not read
+// aggregates need more peers and see the median only when it is used, so
+// it does not give away what a colleague or two pay.
+const (
+	anomalyMinPeers     = 2
+	anomalyMinUserPeers = 5
+)
+
+// PriceAnomalyInput flags subscriptions costing more than Threshold percent
+// above their reference prices, or raised within the last Days days.
+type PriceAnomalyInput struct {
+	UserID    *string
+	Threshold int
+	Days      int
+	Limit     int
+	Offset    int
+}
+
+type ReportService interface {
+	// Forecast projects the spend of the next months, starting with the
+	// current one. Users only see their own subscriptions.
+	Forecast(ctx context.Context, months int, userID *string) (*model.Forecast, error)
+	// Duplicates lists overlapping subscriptions of the same user and
+	// service. Users only see their own.
+	Duplicates(ctx context.Context, userID *string, limit, offset int) ([]model.Overlap, error)
+	// PriceAnomalies compares the prices of active subscriptions with the
+	// catalog and with other users of the same service. Users only see their
+	// own subscriptions, compared with everyone's, and the peer median only
+	// when enough peers pay for the service.
+	PriceAnomalies(ctx context.Context, in PriceAnomalyInput) ([]model.PriceAnomaly, error)
+	// Top ranks the limit biggest users and services by spend in from..to
+	// against the period of the same length before it. Users only see
+	// their own spend.
+	Top(ctx context.Context, from, to time.Time, limit int) (*model.TopReport, error)
+	// Churn reports new and ended subscriptions per service for the months
+	// from..to (first days of months), services with the highest churn
+	// first. Users only see their own subscriptions.
+	Churn(ctx context.Context, from, to time.Time, serviceName, userID *string) ([]model.ServiceChurn, error)
+	// UserSummary collects the dashboard of one user. It is readable by the
+	// user, finance and admin.
+	UserSummary(ctx context.Context, userID string) (*model.UserSummary, error)
+	// Allocation splits the spend in from..to by cost center or tag
+	// (model.GroupByCostCenter or GroupByTag) against the period of the same
+	// length before it. Users only see their own spend.
+	Allocation(ctx context.Context, from, to time.Time, groupBy string) (*model.AllocationReport, error)
+}
+
+type reportService struct {
+	repo repository.ReportRepo
+	now  func() time.Time
+}
+
+func NewReportService(r repository.ReportRepo) ReportService {
+	return &reportService{repo: r, now: time.Now}
+}
+
+func (s *reportService) Forecast(ctx context.Context, months int, userID *string) (*model.Forecast, error) {
+	if months < 1 || months > MaxForecastMonths {
+		return nil, ErrInvalid
+	}
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err = scopeToCaller(p, canReadAggregates(p), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	rows, err := s.repo.Forecast(ctx, from, months, userID)
+	if err != nil {
+		return nil, err
+	}
+	return buildForecast(from, months, rows), nil
+}
+
+func buildForecast(from time.Time, months int, rows []repository.ForecastRow) *model.Forecast {
+	f := &model.Forecast{Months: make([]model.ForecastMonth, months)}
+	index := make(map[time.Time]int, months)
+	for i := range f.Months {
+		m := from.AddDate(0, i, 0)
+		f.Months[i] = model.ForecastMonth{Month: m, ByService: []model.ServiceCost{}, ByUser: []model.UserCost{}}
+		index[m] = i
+	}
+
+	byService := make([]map[string]int64, months)
+	byUser := make([]map[string]int64, months)
+	for _, r := range rows {
+		m := time.Date(r.Month.Year(), r.Month.Month(), 1, 0, 0, 0, 0, time.UTC)
+		i, ok := index[m]
+		if !ok {
+			continue
+		}
+		if byService[i] == nil {
+			byService[i], byUser[i] = map[string]int64{}, map[string]int64{}
+		}
+		byService[i][r.ServiceName] += r.Total
+		byUser[i][r.UserID] += r.Total
+		f.Months[i].Total += r.Total
+		f.Total += r.Total
+	}
+
+	for i := range f.Months {
+		for name, total := range byService[i] {
+			f.Months[i].ByService = append(f.Months[i].ByService, model.ServiceCost{ServiceName: name, Total: total})
+		}
+		for id, total := range byUser[i] {
+			f.Months[i].ByUser = append(f.Months[i].ByUser, model.UserCost{UserID: id, Total: total})
+		}
+		slices.SortFunc(f.Months[i].ByService, func(a, b model.ServiceCost) int {
+			return cmp.Or(cmp.Compare(b.Total, a.Total), strings.Compare(a.ServiceName, b.ServiceName))
+		})
+		slices.SortFunc(f.Months[i].ByUser, func(a, b model.UserCost) int {
+			return cmp.Or(cmp.Compare(b.Total, a.Total), strings.Compare(a.UserID, b.UserID))
+		})
+	}
+	return f
+}
+
+func (s *reportService) Duplicates(ctx context.Context, userID *string, limit, offset int) ([]model.Overlap, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err = scopeToCaller(p, canReadAggregates(p), userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Duplicates(ctx, userID, limit, offset)
+}
+
+func (s *reportService) PriceAnomalies(ctx context.Context, in PriceAnomalyInput) ([]model.PriceAnomaly, error) {
+	if in.Threshold < 1 || in.Threshold > MaxAnomalyThreshold || in.Days < 1 || in.Days > MaxPriceHikeDays {
+		return nil, ErrInvalid
+	}
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	privileged := canReadAggregates(p)
+	userID, err := scopeToCaller(p, privileged, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	minPeers := anomalyMinPeers
+	if !privileged {
+		minPeers = anomalyMinUserPeers
+	}
+
+	now := s.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	out, err := s.repo.PriceAnomalies(ctx, repository.PriceAnomalyFilter{
+		UserID:    userID,
+		Date:      today,
+		Since:     now.AddDate(0, 0, -in.Days),
+		Threshold: in.Threshold,
+		MinPeers:  minPeers,
+		Limit:     in.Limit,
+		Offset:    in.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		a := &out[i]
+		a.OverCatalogPercent = overPercent(a.MonthlyPrice, a.CatalogPrice)
+		if a.PeerCount >= minPeers {
+			a.OverPeersPercent = overPercent(a.MonthlyPrice, a.PeerMedian)
+		} else if !privileged {
+			a.PeerMedian, a.PeerCount = nil, 0
+		}
+	}
+	return out, nil
+}
+
+// overPercent is how many percent price exceeds ref, negative if it is
+// cheaper; nil without a usable reference.
+func overPercent(price int, ref *int) *int64 {
+	if ref == nil || *ref <= 0 {
+		return nil
+	}
+	v := (int64(price) - int64(*ref)) * 100 / int64(*ref)
+	return &v
+}
+
+func (s *reportService) Top(ctx context.Context, from, to time.Time, limit int) (*model.TopReport, error) {
+	if from.After(to) || limit < 1 || limit > MaxTopLimit {
+		return nil, ErrInvalid
+	}
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := scopeToCaller(p, canReadAggregates(p), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	prevFrom, prevTo := previousPeriod(from, to)
+	spend, err := s.repo.Top(ctx, from, to, prevFrom, prevTo, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &model.TopReport{
+		From:          from,
+		To:            to,
+		PreviousFrom:  prevFrom,
+		PreviousTo:    prevTo,
+		Total:         spend.Total,
+		PreviousTotal: spend.PreviousTotal,
+		Users:         make([]model.TopUser, 0, len(spend.Users)),
+		Services:      make([]model.TopService, 0, len(spend.Services)),
+	}
+	for _, row := range spend.Users {
+		r.Users = append(r.Users, model.TopUser{UserID: row.Key, SpendStats: spendStats(row, spend.Total)})
+	}
+	for _, row := range spend.Services {
+		r.Services = append(r.Services, model.TopService{ServiceName: row.Key, SpendStats: spendStats(row, spend.Total)})
+	}
+	return r, nil
+}
+
+func (s *reportService) Allocation(ctx context.Context, from, to time.Time, groupBy string) (*model.AllocationReport, error) {
+	if from.After(to) || (groupBy != model.GroupByCostCenter && groupBy != model.GroupByTag) {
+		return nil, ErrInvalid
+	}
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := scopeToCaller(p, canReadAggregates(p), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	prevFrom, prevTo := previousPeriod(from, to)
+	spend, err := s.repo.Allocation(ctx, groupBy, from, to, prevFrom, prevTo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &model.AllocationReport{
+		From:          from,
+		To:            to,
+		PreviousFrom:  prevFrom,
+		PreviousTo:    prevTo,
+		GroupBy:       groupBy,
+		Total:         spend.Total,
+		PreviousTotal: spend.PreviousTotal,
+		Groups:        make([]model.AllocationGroup, 0, len(spend.Groups)),
+	}
+	for _, row := range spend.Groups {
+		r.Groups = append(r.Groups, model.AllocationGroup{Name: row.Key, SpendStats: spendStats(row, spend.Total)})
+	}
+	return r, nil
+}
+
+// previousPeriod is the period of the same length as from..to that ends the
+// day before from.
+func previousPeriod(from, to time.Time) (time.Time, time.Time) {
+	days := int(to.Sub(from).Hours()/24) + 1
+	prevTo := from.AddDate(0, 0, -1)
+	return prevTo.AddDate(0, 0, 1-days), prevTo
+}
+
+func spendStats(row repository.SpendRow, total int64) model.SpendStats {
+	st := model.SpendStats{
+		Total:         row.Total,
+		Count:         row.Count,
+		PreviousTotal: row.PreviousTotal,
+		Change:        row.Total - row.PreviousTotal,
+	}
+	if row.Count > 0 {
+		st.AveragePrice = row.Total / int64(row.Count)
+	}
+	if total > 0 {
+		st.SharePercent = roundPercent(row.Total, total)
+	}
+	if row.PreviousTotal > 0 {
+		v := roundPercent(st.Change, row.PreviousTotal)
+		st.ChangePercent = &v
+	}
+	return st
+}
+
+// roundPercent is part/whole in percent, rounded to one decimal.
+func roundPercent(part, whole int64) float64 {
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
+
+func (s *reportService) Churn(ctx context.Context, from, to time.Time, serviceName, userID *string) ([]model.ServiceChurn, error) {
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+	if months < 1 || months > MaxChurnMonths {
+		return nil, ErrInvalid
+	}
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err = scopeToCaller(p, canReadAggregates(p), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.Churn(ctx, from, to, serviceName, userID)
+	if err != nil {
+		return nil, err
+	}
+	return buildChurn(from, months, rows), nil
+}
+
+func buildChurn(from time.Time, months int, rows []repository.ChurnRow) []model.ServiceChurn {
+	out := []model.ServiceChurn{}
+	index := map[string]int{}
+	lifetime := map[string]int64{}
+	for _, r := range rows {
+		i, ok := index[r.ServiceName]
+		if !ok {
+			i = len(out)
+			index[r.ServiceName] = i
+			sc := model.ServiceChurn{ServiceName: r.ServiceName, Months: make([]model.ChurnMonth, months)}
+			for m := range sc.Months {
+				sc.Months[m].Month = from.AddDate(0, m, 0)
+			}
+			out = append(out, sc)
+		}
+		m := (r.Month.Year()-from.Year())*12 + int(r.Month.Month()-from.Month())
+		if m < 0 || m >= months {
+			continue
+		}
+		cm := &out[i].Months[m]
+		cm.ActiveAtStart, cm.New, cm.Cancelled, cm.Net = r.ActiveAtStart, r.New, r.Cancelled, r.New-r.Cancelled
+		cm.ChurnRate = churnRate(r.Cancelled, r.ActiveAtStart+r.New)
+		cm.AvgLifetimeDays = avgDays(r.LifetimeDays, r.Cancelled)
+		lifetime[r.ServiceName] += r.LifetimeDays
+	}
+
+	for i := range out {
+		sc := &out[i]
+		for _, m := range sc.Months {
+			sc.New += m.New
+			sc.Cancelled += m.Cancelled
+		}
+		sc.Net = sc.New - sc.Cancelled
+		sc.ChurnRate = churnRate(sc.Cancelled, sc.Months[0].ActiveAtStart+sc.New)
+		sc.AvgLifetimeDays = avgDays(lifetime[sc.ServiceName], sc.Cancelled)
+	}
+	slices.SortFunc(out, func(a, b model.ServiceChurn) int {
+		var ra, rb float64
+		if a.ChurnRate != nil {
+			ra = *a.ChurnRate
+		}
+		if b.ChurnRate != nil {
+			rb = *b.ChurnRate
+		}
+		return cmp.Or(cmp.Compare(rb, ra), cmp.Compare(b.Cancelled, a.Cancelled), strings.Compare(a.ServiceName, b.ServiceName))
+	})
+	return out
+}
+
+func churnRate(cancelled, base int) *float64 {
+	if base == 0 {
+		return nil
+	}
+	v := roundPercent(int64(cancelled), int64(base))
+	return &v
+}
+
+func avgDays(total int64, n int) *float64 {
+	if n == 0 {
+		return nil
+	}
+	v := math.Round(float64(total)*10/float64(n)) / 10
+	return &v
+}
+
+func (s *reportService) UserSummary(ctx context.Context, userID string) (*model.UserSummary, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.Subject != userID && !canReadAggregates(p) {
+		return nil, ErrForbidden
+	}
+
+	now := s.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	active, err := s.repo.ActiveSubscriptions(ctx, userID, today)
+	if err != nil {
+		return nil, err
+	}
+	from := time.Date(now.Year(), now.Month()-11, 1, 0, 0, 0, 0, time.UTC)
+	rows, err := s.repo.History(ctx, from, 12, &userID)
+	if err != nil {
+		return nil, err
+	}
+	history := buildForecast(from, 12, rows)
+
+	sum := &model.UserSummary{
+		UserID:              userID,
+		AsOf:                today,
+		ActiveSubscriptions: active,
+		UpcomingRenewals:    []*model.Subscription{},
+		Last12Months:        make([]model.MonthSpend, 0, len(history.Months)),
+	}
+	horizon := today.AddDate(0, 0, summaryRenewalDays)
+	if sum.ActiveSubscriptions == nil {
+		sum.ActiveSubscriptions = []*model.Subscription{}
+	}
+	for _, sub := range active {
+		if sub.EndDate != nil && !sub.EndDate.After(horizon) {
+			sum.UpcomingRenewals = append(sum.UpcomingRenewals, sub)
+		}
+	}
+	slices.SortStableFunc(sum.UpcomingRenewals, func(a, b *model.Subscription) int {
+		return a.EndDate.Compare(*b.EndDate)
+	})
+	for _, m := range history.Months {
+		sum.Last12Months = append(sum.Last12Months, model.MonthSpend{Month: m.Month, Total: m.Total})
+	}
+	current := history.Months[len(history.Months)-1]
+	sum.MonthlySpend = current.Total
+	sum.TopServices = current.ByService[:min(len(current.ByService), summaryTopServices)]
+	return sum, nil
+}
@@ -0,0 +1,330 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReportRepo struct {
+	mock.Mock
+}
+
+func (m *mockReportRepo) Forecast(ctx context.Context, from time.Time, months int, userID *string) ([]repository.ForecastRow, error) {
+	args := m.Called(ctx, from, months, userID)
+	return args.Get(0).([]repository.ForecastRow), args.Error(1)
+}
+
+func (m *mockReportRepo) History(ctx context.Context, from time.Time, months int, userID *string) ([]repository.ForecastRow, error) {
+	args := m.Called(ctx, from, months, userID)
+	return args.Get(0).([]repository.ForecastRow), args.Error(1)
+}
+
+func (m *mockReportRepo) Duplicates(ctx context.Context, userID *string, limit, offset int) ([]model.Overlap, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	return args.Get(0).([]model.Overlap), args.Error(1)
+}
+
+func (m *mockReportRepo) PriceAnomalies(ctx context.Context, f repository.PriceAnomalyFilter) ([]model.PriceAnomaly, error) {
+	args := m.Called(ctx, f)
+	return args.Get(0).([]model.PriceAnomaly), args.Error(1)
+}
+
+func (m *mockReportRepo) Top(ctx context.Context, from, to, prevFrom, prevTo time.Time, userID *string, limit int) (*repository.TopSpend, error) {
+	args := m.Called(ctx, from, to, prevFrom, prevTo, userID, limit)
+	return args.Get(0).(*repository.TopSpend), args.Error(1)
+}
+
+func (m *mockReportRepo) Churn(ctx context.Context, from, to time.Time, serviceName, userID *string) ([]repository.ChurnRow, error) {
+	args := m.Called(ctx, from, to, serviceName, userID)
+	return args.Get(0).([]repository.ChurnRow), args.Error(1)
+}
+
+func (m *mockReportRepo) ActiveSubscriptions(ctx context.Context, userID string, day time.Time) ([]*model.Subscription, error) {
+	args := m.Called(ctx, userID, day)
+	return args.Get(0).([]*model.Subscription), args.Error(1)
+}
+
+func (m *mockReportRepo) Allocation(ctx context.Context, groupBy string, from, to, prevFrom, prevTo time.Time, userID *string) (*repository.AllocationSpend, error) {
+	args := m.Called(ctx, groupBy, from, to, prevFrom, prevTo, userID)
+	if out, ok := args.Get(0).(*repository.AllocationSpend); ok {
+		return out, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestForecast(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	next := from.AddDate(0, 1, 0)
+	repo.On("Forecast", mock.Anything, from, 3, (*string)(nil)).Return([]repository.ForecastRow{
+		{Month: from, ServiceName: "Netflix", UserID: "u1", Total: 500},
+		{Month: from, ServiceName: "Netflix", UserID: "u2", Total: 500},
+		{Month: from, ServiceName: "Spotify", UserID: "u1", Total: 200},
+		{Month: next, ServiceName: "Spotify", UserID: "u1", Total: 200},
+	}, nil)
+
+	f, err := svc.Forecast(asRole(auth.RoleFinance), 3, nil)
+	require.NoError(t, err)
+	require.Len(t, f.Months, 3)
+	assert.Equal(t, int64(1400), f.Total)
+
+	first := f.Months[0]
+	assert.Equal(t, int64(1200), first.Total)
+	assert.Equal(t, "Netflix", first.ByService[0].ServiceName)
+	assert.Equal(t, int64(1000), first.ByService[0].Total)
+	assert.Equal(t, "u1", first.ByUser[0].UserID)
+	assert.Equal(t, int64(700), first.ByUser[0].Total)
+
+	assert.Equal(t, int64(200), f.Months[1].Total)
+	assert.Equal(t, int64(0), f.Months[2].Total)
+	assert.NotNil(t, f.Months[2].ByService)
+	assert.Equal(t, from.AddDate(0, 2, 0), f.Months[2].Month)
+}
+
+func TestForecast_ScopedToCaller(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+	userID := uuid.New().String()
+	repo.On("Forecast", mock.Anything, mock.Anything, 12, &userID).Return([]repository.ForecastRow{}, nil)
+
+	_, err := svc.Forecast(asUser(userID), 12, nil)
+	require.NoError(t, err)
+
+	other := uuid.New().String()
+	_, err = svc.Forecast(asUser(userID), 12, &other)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	_, err = svc.Forecast(asRole(auth.RoleAdmin), 0, nil)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, err = svc.Forecast(asRole(auth.RoleAdmin), service.MaxForecastMonths+1, nil)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestDuplicates_ScopedToCaller(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+	userID := uuid.New().String()
+	repo.On("Duplicates", mock.Anything, &userID, 50, 0).Return([]model.Overlap{{UserID: userID}}, nil)
+	repo.On("Duplicates", mock.Anything, (*string)(nil), 50, 0).Return([]model.Overlap{}, nil)
+
+	got, err := svc.Duplicates(asUser(userID), nil, 50, 0)
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+
+	_, err = svc.Duplicates(asRole(auth.RoleFinance), nil, 50, 0)
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestPriceAnomalies(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+
+	catalog, median := 400, 500
+	repo.On("PriceAnomalies", mock.Anything, mock.MatchedBy(func(f repository.PriceAnomalyFilter) bool {
+		return f.UserID == nil && f.Threshold == 20 && f.MinPeers == 2 && f.Limit == 50 &&
+			f.Date.Sub(f.Since) > 89*24*time.Hour && f.Date.Sub(f.Since) <= 90*24*time.Hour
+	})).Return([]model.PriceAnomaly{
+		{SubscriptionID: "s1", Price: 1800, MonthlyPrice: 600, CatalogPrice: &catalog, PeerMedian: &median, PeerCount: 2},
+		{SubscriptionID: "s2", Price: 600, MonthlyPrice: 600, PeerMedian: &median, PeerCount: 1},
+	}, nil)
+
+	got, err := svc.PriceAnomalies(asRole(auth.RoleFinance), service.PriceAnomalyInput{Threshold: 20, Days: 90, Limit: 50})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, int64(50), *got[0].OverCatalogPercent)
+	assert.Equal(t, int64(20), *got[0].OverPeersPercent)
+	assert.Nil(t, got[1].OverCatalogPercent)
+	assert.Nil(t, got[1].OverPeersPercent)
+	assert.Equal(t, 1, got[1].PeerCount)
+}
+
+func TestPriceAnomalies_UserNeedsMorePeers(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+	userID := uuid.New().String()
+
+	median := 500
+	repo.On("PriceAnomalies", mock.Anything, mock.MatchedBy(func(f repository.PriceAnomalyFilter) bool {
+		return f.UserID != nil && *f.UserID == userID && f.MinPeers == 5
+	})).Return([]model.PriceAnomaly{
+		{SubscriptionID: "s1", MonthlyPrice: 600, PeerMedian: &median, PeerCount: 5},
+		{SubscriptionID: "s2", MonthlyPrice: 600, PeerMedian: &median, PeerCount: 4},
+	}, nil)
+
+	got, err := svc.PriceAnomalies(asUser(userID), service.PriceAnomalyInput{Threshold: 20, Days: 90, Limit: 50})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, int64(20), *got[0].OverPeersPercent)
+	assert.Equal(t, 5, got[0].PeerCount)
+	assert.Nil(t, got[1].PeerMedian)
+	assert.Zero(t, got[1].PeerCount)
+	assert.Nil(t, got[1].OverPeersPercent)
+}
+
+func TestPriceAnomalies_Validation(t *testing.T) {
+	svc := service.NewReportService(new(mockReportRepo))
+	userID := uuid.New().String()
+
+	_, err := svc.PriceAnomalies(asRole(auth.RoleAdmin), service.PriceAnomalyInput{Threshold: 0, Days: 90})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, err = svc.PriceAnomalies(asRole(auth.RoleAdmin), service.PriceAnomalyInput{Threshold: 20, Days: service.MaxPriceHikeDays + 1})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+
+	other := uuid.New().String()
+	_, err = svc.PriceAnomalies(asUser(userID), service.PriceAnomalyInput{UserID: &other, Threshold: 20, Days: 90})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+}
+
+func TestTop(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+
+	from, to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	prevFrom, prevTo := time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	repo.On("Top", mock.Anything, from, to, prevFrom, prevTo, (*string)(nil), 10).Return(&repository.TopSpend{
+		Total:         1500,
+		PreviousTotal: 1000,
+		Users:         []repository.SpendRow{{Key: "u1", Total: 1000, Count: 2, PreviousTotal: 800}, {Key: "u2", Total: 500, Count: 1}},
+		Services:      []repository.SpendRow{{Key: "Netflix", Total: 1500, Count: 3, PreviousTotal: 1000}},
+	}, nil)
+
+	r, err := svc.Top(asRole(auth.RoleFinance), from, to, 10)
+	require.NoError(t, err)
+	assert.Equal(t, prevFrom, r.PreviousFrom)
+	require.Len(t, r.Users, 2)
+	u1 := r.Users[0]
+	assert.Equal(t, int64(500), u1.AveragePrice)
+	assert.Equal(t, 66.7, u1.SharePercent)
+	assert.Equal(t, int64(200), u1.Change)
+	assert.Equal(t, 25.0, *u1.ChangePercent)
+	assert.Nil(t, r.Users[1].ChangePercent)
+	assert.Equal(t, 100.0, r.Services[0].SharePercent)
+}
+
+func TestTop_ScopedToCaller(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+	userID := uuid.New().String()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo.On("Top", mock.Anything, day, day, day.AddDate(0, 0, -1), day.AddDate(0, 0, -1), &userID, 5).Return(&repository.TopSpend{}, nil)
+
+	r, err := svc.Top(asUser(userID), day, day, 5)
+	require.NoError(t, err)
+	assert.NotNil(t, r.Users)
+
+	_, err = svc.Top(asUser(userID), day, day.AddDate(0, 0, -1), 5)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, err = svc.Top(asUser(userID), day, day, service.MaxTopLimit+1)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestAllocation(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+
+	from, to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	prevFrom, prevTo := time.Date(2025, 1, 29, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+	repo.On("Allocation", mock.Anything, model.GroupByTag, from, to, prevFrom, prevTo, (*string)(nil)).Return(&repository.AllocationSpend{
+		Total:         1500,
+		PreviousTotal: 1000,
+		Groups:        []repository.SpendRow{{Key: "marketing", Total: 1200, Count: 2, PreviousTotal: 1000}, {Key: "", Total: 500, Count: 1}},
+	}, nil)
+
+	r, err := svc.Allocation(asRole(auth.RoleFinance), from, to, model.GroupByTag)
+	require.NoError(t, err)
+	assert.Equal(t, model.GroupByTag, r.GroupBy)
+	assert.Equal(t, prevTo, r.PreviousTo)
+	require.Len(t, r.Groups, 2)
+	assert.Equal(t, 80.0, r.Groups[0].SharePercent)
+	assert.Equal(t, 20.0, *r.Groups[0].ChangePercent)
+	assert.Equal(t, "", r.Groups[1].Name)
+
+	_, err = svc.Allocation(asRole(auth.RoleFinance), from, to, "service")
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestChurn(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+
+	jan, feb, mar := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo.On("Churn", mock.Anything, jan, mar, (*string)(nil), (*string)(nil)).Return([]repository.ChurnRow{
+		{Month: jan, ServiceName: "Netflix", ActiveAtStart: 8, New: 2, Cancelled: 1, LifetimeDays: 100},
+		{Month: jan, ServiceName: "Spotify", ActiveAtStart: 4, New: 0, Cancelled: 2, LifetimeDays: 30},
+		{Month: feb, ServiceName: "Netflix", ActiveAtStart: 9, New: 1, Cancelled: 1, LifetimeDays: 200},
+	}, nil)
+
+	got, err := svc.Churn(asRole(auth.RoleFinance), jan, mar, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+
+	spotify, netflix := got[0], got[1]
+	assert.Equal(t, "Spotify", spotify.ServiceName)
+	assert.Equal(t, 50.0, *spotify.ChurnRate)
+	assert.Equal(t, 15.0, *spotify.AvgLifetimeDays)
+
+	assert.Equal(t, 3, netflix.New)
+	assert.Equal(t, 2, netflix.Cancelled)
+	assert.Equal(t, 1, netflix.Net)
+	assert.Equal(t, 18.2, *netflix.ChurnRate)
+	assert.Equal(t, 150.0, *netflix.AvgLifetimeDays)
+	require.Len(t, netflix.Months, 3)
+	assert.Equal(t, 10.0, *netflix.Months[0].ChurnRate)
+	assert.Equal(t, mar, netflix.Months[2].Month)
+	assert.Nil(t, netflix.Months[2].ChurnRate)
+
+	_, err = svc.Churn(asRole(auth.RoleFinance), mar, jan, nil, nil)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestUserSummary(t *testing.T) {
+	repo := new(mockReportRepo)
+	svc := service.NewReportService(repo)
+	userID := uuid.New().String()
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	soon, later, sooner := today.AddDate(0, 0, 20), today.AddDate(0, 0, 40), today.AddDate(0, 0, 3)
+	repo.On("ActiveSubscriptions", mock.Anything, userID, today).Return([]*model.Subscription{
+		{ID: "s1", ServiceName: "Netflix", Price: 999, EndDate: &soon},
+		{ID: "s2", ServiceName: "Spotify", Price: 299, EndDate: &later},
+		{ID: "s3", ServiceName: "Yandex Plus", Price: 199, EndDate: &sooner},
+		{ID: "s4", ServiceName: "iCloud", Price: 99},
+	}, nil)
+	repo.On("History", mock.Anything, month.AddDate(0, -11, 0), 12, &userID).Return([]repository.ForecastRow{
+		{Month: month.AddDate(0, -1, 0), ServiceName: "Netflix", UserID: userID, Total: 999},
+		{Month: month, ServiceName: "Netflix", UserID: userID, Total: 999},
+		{Month: month, ServiceName: "Spotify", UserID: userID, Total: 299},
+	}, nil)
+
+	sum, err := svc.UserSummary(asUser(userID), userID)
+	require.NoError(t, err)
+	assert.Len(t, sum.ActiveSubscriptions, 4)
+	require.Len(t, sum.UpcomingRenewals, 2)
+	assert.Equal(t, "s3", sum.UpcomingRenewals[0].ID)
+	assert.Equal(t, "s1", sum.UpcomingRenewals[1].ID)
+	assert.Equal(t, int64(1298), sum.MonthlySpend)
+	require.Len(t, sum.Last12Months, 12)
+	assert.Equal(t, int64(999), sum.Last12Months[10].Total)
+	assert.Equal(t, month, sum.Last12Months[11].Month)
+	assert.Equal(t, "Netflix", sum.TopServices[0].ServiceName)
+
+	_, err = svc.UserSummary(asUser(uuid.New().String()), userID)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+}
@@ -3,32 +3,111 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"subscription-service/internal/model"
 	"subscription-service/internal/repository"
 
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
-var ErrInvalid = errors.New("invalid input")
+var (
+	ErrInvalid = errors.New("invalid input")
+	// ErrOverlap rejects a subscription that overlaps another one of the
+	// same user and service under DuplicatesReject.
+	ErrOverlap = errors.New("overlapping subscription")
+	// ErrShares rejects member shares that do not split the whole price.
+	ErrShares = repository.ErrShares
+)
+
+// DuplicatePolicy decides what happens when a created or updated
+// subscription overlaps another one of the same user and service.
+type DuplicatePolicy string
+
+const (
+	DuplicatesAllow  DuplicatePolicy = "allow"
+	DuplicatesWarn   DuplicatePolicy = "warn"
+	DuplicatesReject DuplicatePolicy = "reject"
+)
 
 type SubscriptionService interface {
 	CreateSubscription(ctx context.Context, in CreateInput) (*model.Subscription, error)
 	GetByID(ctx context.Context, id string) (*model.Subscription, error)
 	UpdateSubscription(ctx context.Context, id string, in UpdateInput) (*model.Subscription, error)
-	DeleteSubscription(ctx context.Context, id string) error
+	// DeleteSubscription returns the subscription it deleted.
+	DeleteSubscription(ctx context.Context, id string) (*model.Subscription, error)
 	ListSubscriptions(ctx context.Context, filter repository.ListFilter) ([]*model.Subscription, error)
 	SumForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error)
+	// SetMembers replaces the members sharing the subscription's cost. Fixed
+	// amounts may not exceed the price; percentages split the rest and must
+	// add up to 100, otherwise the amounts must add up to the price. Only
+	// finance and admin may list users other than themselves.
+	SetMembers(ctx context.Context, id string, members []model.Member) ([]model.Member, error)
+	ListMembers(ctx context.Context, id string) ([]model.Member, error)
+	// SetTags replaces the tags of a subscription. Tags are lower-cased;
+	// the subscription's user, finance and admin may change them.
+	SetTags(ctx context.Context, id string, tags []string) ([]string, error)
+	// TagSubscriptions adds and removes tags on several subscriptions at
+	// once, all or nothing.
+	TagSubscriptions(ctx context.Context, in TagInput) error
+	// ListTags lists the tags of the organization. Users only count their
+	// own subscriptions.
+	ListTags(ctx context.Context) ([]model.Tag, error)
+	// DeleteTag removes a tag from all subscriptions; finance and admin only.
+	DeleteTag(ctx context.Context, name string) error
+	// SetPriceSchedule replaces the future prices of a subscription. Each
+	// starts with a month after the current one and before the end of the
+	// subscription, and has to fit its members like the current price.
+	SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) ([]model.ScheduledPrice, error)
+	ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error)
+}
+
+// Bounds of tags and tag operations.
+const (
+	MaxTags              = 20
+	MaxTagLength         = 50
+	MaxCostCenterLength  = 100
+	MaxBulkSubscriptions = 100
+)
+
+// MaxScheduledPrices bounds the price schedule of a subscription.
+const MaxScheduledPrices = 24
+
+// TagInput adds the tags Add to and removes Remove from every subscription
+// of SubscriptionIDs.
+type TagInput struct {
+	SubscriptionIDs []string
+	Add             []string
+	Remove          []string
 }
 
 type serviceImpl struct {
-	repo repository.SubscriptionRepo
+	repo       repository.SubscriptionRepo
+	duplicates DuplicatePolicy
 }
 
-func NewSubscriptionService(r repository.SubscriptionRepo) SubscriptionService {
-	return &serviceImpl{repo: r}
+type Option func(*serviceImpl)
+
+// WithDuplicatePolicy enables overlap detection; overlaps are allowed by
+// default.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(s *serviceImpl) {
+		s.duplicates = p
+	}
+}
+
+func NewSubscriptionService(r repository.SubscriptionRepo, opts ...Option) SubscriptionService {
+	s := &serviceImpl{repo: r, duplicates: DuplicatesAllow}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 type CreateInput struct {
@@ -37,6 +116,9 @@ type CreateInput struct {
 	UserID      string
 	StartDate   time.Time
 	EndDate     *time.Time
+	CostCenter  *string
+	// BillingPeriod is in months; zero means monthly.
+	BillingPeriod int
 }
 
 type UpdateInput struct {
@@ -45,6 +127,10 @@ type UpdateInput struct {
 	UserID      string     `json:"user_id"`
 	StartDate   time.Time  `json:"start_date"`
 	EndDate     *time.Time `json:"end_date,omitempty"`
+	// CostCenter keeps the current one when nil; blank removes it.
+	CostCenter *string `json:"cost_center,omitempty"`
+	// BillingPeriod is in months; zero keeps the current one.
+	BillingPeriod int `json:"billing_period,omitempty"`
 }
 
 func (s *serviceImpl) CreateSubscription(ctx context.Context, in CreateInput) (*model.Subscription, error) {
@@ -54,6 +140,17 @@ func (s *serviceImpl) CreateSubscription(ctx context.Context, in CreateInput) (*
 	if _, err := uuid.Parse(in.UserID); err != nil {
 		return nil, ErrInvalid
 	}
+	costCenter, err := normalizeCostCenter(in.CostCenter)
+	if err != nil {
+		return nil, err
+	}
+	period, err := billingPeriod(in.BillingPeriod)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeOwner(ctx, in.UserID); err != nil {
+		return nil, err
+	}
 
 	start := in.StartDate
 	var end time.Time
@@ -69,39 +166,68 @@ func (s *serviceImpl) CreateSubscription(ctx context.Context, in CreateInput) (*
 	now := time.Now().UTC()
 	id := uuid.New().String()
 	sub := &model.Subscription{
-		ID:          id,
-		ServiceName: in.ServiceName,
-		Price:       in.Price,
-		UserID:      in.UserID,
-		StartDate:   start,
-		EndDate:     &end,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:            id,
+		ServiceName:   in.ServiceName,
+		Price:         in.Price,
+		UserID:        in.UserID,
+		StartDate:     start,
+		EndDate:       &end,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		CostCenter:    costCenter,
+		BillingPeriod: period,
 	}
 
 	if sub.StartDate.IsZero() {
 		sub.StartDate = now
 	}
 
+	warnings, err := s.checkOverlaps(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
 	if err := s.repo.Create(ctx, sub); err != nil {
-		log.Error().Err(err).Msg("repo.Create failed")
+		zerolog.Ctx(ctx).Error().Err(err).Msg("repo.Create failed")
 		return nil, err
 	}
+	sub.Warnings = warnings
 	return sub, nil
 }
 
 func (s *serviceImpl) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
-	return s.repo.GetByID(ctx, id)
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+	return sub, nil
 }
 
 func (s *serviceImpl) UpdateSubscription(ctx context.Context, id string, in UpdateInput) (*model.Subscription, error) {
-	existing, err := s.repo.GetByID(ctx, id)
+	ctx = repository.WithPrimary(ctx)
+	existing, err := s.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := authorizeOwner(ctx, in.UserID); err != nil {
+		return nil, err
+	}
 	if in.EndDate != nil && in.EndDate.Before(in.StartDate) {
 		return nil, ErrInvalid
 	}
+	// Clients unaware of the optional fields must not reset them.
+	if in.CostCenter != nil {
+		if existing.CostCenter, err = normalizeCostCenter(in.CostCenter); err != nil {
+			return nil, err
+		}
+	}
+	if in.BillingPeriod != 0 {
+		if existing.BillingPeriod, err = billingPeriod(in.BillingPeriod); err != nil {
+			return nil, err
+		}
+	}
 	existing.ServiceName = in.ServiceName
 	existing.Price = in.Price
 	existing.UserID = in.UserID
@@ -113,17 +239,183 @@ func (s *serviceImpl) UpdateSubscription(ctx context.Context, id string, in Upda
 		existing.EndDate = in.EndDate
 	}
 	existing.UpdatedAt = time.Now().UTC()
+	warnings, err := s.checkOverlaps(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
 	if err := s.repo.Update(ctx, existing); err != nil {
 		return nil, err
 	}
+	existing.Warnings = warnings
 	return existing, nil
 }
 
-func (s *serviceImpl) DeleteSubscription(ctx context.Context, id string) error {
-	return s.repo.Delete(ctx, id)
+// checkOverlaps applies the duplicate policy to sub. The check reads the
+// primary but is not atomic with the write, so concurrent requests can
+// still create an overlap; the duplicates report finds those.
+func (s *serviceImpl) checkOverlaps(ctx context.Context, sub *model.Subscription) ([]string, error) {
+	if s.duplicates == DuplicatesAllow {
+		return nil, nil
+	}
+	overlaps, err := s.repo.FindOverlapping(repository.WithPrimary(ctx), sub)
+	if err != nil || len(overlaps) == 0 {
+		return nil, err
+	}
+	if s.duplicates == DuplicatesReject {
+		return nil, ErrOverlap
+	}
+	warnings := make([]string, 0, len(overlaps))
+	for _, o := range overlaps {
+		warnings = append(warnings, fmt.Sprintf("overlaps subscription %s of the same user and service", o.ID))
+	}
+	return warnings, nil
+}
+
+func (s *serviceImpl) SetMembers(ctx context.Context, id string, members []model.Member) ([]model.Member, error) {
+	ctx = repository.WithPrimary(ctx)
+	sub, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if _, err := uuid.Parse(m.UserID); err != nil || (m.SharePercent == nil) == (m.Amount == nil) {
+			return nil, ErrInvalid
+		}
+	}
+	if err := validateShares(members, sub.Price); err != nil {
+		return nil, err
+	}
+	// Members pay for a subscription they cannot read, so only finance and
+	// admin may charge users other than the caller.
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !canReadAggregates(p) {
+		for _, m := range members {
+			if m.UserID != p.Subject {
+				return nil, ErrForbidden
+			}
+		}
+	}
+	if err := s.repo.SetMembers(ctx, id, members); err != nil {
+		return nil, err
+	}
+	if members == nil {
+		members = []model.Member{}
+	}
+	return members, nil
+}
+
+func (s *serviceImpl) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, id)
+}
+
+func (s *serviceImpl) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) ([]model.ScheduledPrice, error) {
+	ctx = repository.WithPrimary(ctx)
+	sub, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) > MaxScheduledPrices {
+		return nil, ErrInvalid
+	}
+
+	now := time.Now().UTC()
+	nextMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	out := slices.Clone(prices)
+	for i, p := range out {
+		from := p.EffectiveFrom.UTC()
+		if p.Price < 0 || from.Day() != 1 || from.Before(nextMonth) ||
+			(sub.EndDate != nil && from.After(*sub.EndDate)) {
+			return nil, ErrInvalid
+		}
+		out[i].EffectiveFrom = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	slices.SortFunc(out, func(a, b model.ScheduledPrice) int { return a.EffectiveFrom.Compare(b.EffectiveFrom) })
+	for i := 1; i < len(out); i++ {
+		if out[i].EffectiveFrom.Equal(out[i-1].EffectiveFrom) {
+			return nil, ErrInvalid
+		}
+	}
+
+	if err := s.repo.SetPriceSchedule(ctx, id, out); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = []model.ScheduledPrice{}
+	}
+	return out, nil
+}
+
+func (s *serviceImpl) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPriceSchedule(ctx, id)
+}
+
+// validateShares checks that members split price completely. Percentages
+// are kept to hundredths, as stored.
+func validateShares(members []model.Member, price int) error {
+	if len(members) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(members))
+	fixed, hundredths, percents := 0, int64(0), false
+	for _, m := range members {
+		if seen[m.UserID] {
+			return ErrShares
+		}
+		seen[m.UserID] = true
+		if m.Amount != nil {
+			if *m.Amount < 0 {
+				return ErrShares
+			}
+			fixed += *m.Amount
+			continue
+		}
+		h := math.Round(*m.SharePercent * 100)
+		if h <= 0 || h > 10000 || math.Abs(h-*m.SharePercent*100) > 1e-6 {
+			return ErrShares
+		}
+		hundredths += int64(h)
+		percents = true
+	}
+	if fixed > price || (percents && hundredths != 10000) || (!percents && fixed != price) {
+		return ErrShares
+	}
+	return nil
+}
+
+func (s *serviceImpl) DeleteSubscription(ctx context.Context, id string) (*model.Subscription, error) {
+	ctx = repository.WithPrimary(ctx)
+	sub, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+	return sub, nil
 }
 
 func (s *serviceImpl) ListSubscriptions(ctx context.Context, filter repository.ListFilter) ([]*model.Subscription, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter.UserID, err = scopeToCaller(p, canManageAll(p), filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Tag != nil {
+		tag := strings.ToLower(strings.TrimSpace(*filter.Tag))
+		filter.Tag = &tag
+	}
 	return s.repo.List(ctx, filter)
 }
 
@@ -131,5 +423,160 @@ func (s *serviceImpl) SumForPeriod(ctx context.Context, from, to time.Time, user
 	if to.Before(from) {
 		return 0, ErrInvalid
 	}
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return 0, err
+	}
+	userID, err = scopeToCaller(p, canReadAggregates(p), userID)
+	if err != nil {
+		return 0, err
+	}
 	return s.repo.TotalCostForPeriod(ctx, from, to, userID, serviceName)
 }
+
+func (s *serviceImpl) SetTags(ctx context.Context, id string, tags []string) ([]string, error) {
+	ctx = repository.WithPrimary(ctx)
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeTagging(ctx, []string{id}); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetTags(ctx, id, tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *serviceImpl) TagSubscriptions(ctx context.Context, in TagInput) error {
+	ctx = repository.WithPrimary(ctx)
+	if len(in.SubscriptionIDs) == 0 || len(in.SubscriptionIDs) > MaxBulkSubscriptions {
+		return ErrInvalid
+	}
+	ids := make([]string, 0, len(in.SubscriptionIDs))
+	for _, id := range in.SubscriptionIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return ErrInvalid
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	add, err := normalizeTags(in.Add)
+	if err != nil {
+		return err
+	}
+	remove, err := normalizeTags(in.Remove)
+	if err != nil {
+		return err
+	}
+	if len(add)+len(remove) == 0 {
+		return ErrInvalid
+	}
+	for _, t := range add {
+		if slices.Contains(remove, t) {
+			return ErrInvalid
+		}
+	}
+	if err := s.authorizeTagging(ctx, ids); err != nil {
+		return err
+	}
+	return s.repo.TagSubscriptions(ctx, ids, add, remove)
+}
+
+func (s *serviceImpl) ListTags(ctx context.Context) ([]model.Tag, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := scopeToCaller(p, canReadAggregates(p), nil)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.repo.ListTags(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		tags = []model.Tag{}
+	}
+	return tags, nil
+}
+
+func (s *serviceImpl) DeleteTag(ctx context.Context, name string) error {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return err
+	}
+	if !canReadAggregates(p) {
+		return ErrForbidden
+	}
+	return s.repo.DeleteTag(ctx, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// authorizeTagging lets finance and admin tag any subscription and users
+// only their own.
+func (s *serviceImpl) authorizeTagging(ctx context.Context, ids []string) error {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return err
+	}
+	if canReadAggregates(p) {
+		return nil
+	}
+	for _, id := range ids {
+		sub, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if sub.UserID != p.Subject {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// normalizeTags lower-cases and deduplicates tags, keeping their order.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > MaxTags {
+		return nil, ErrInvalid
+	}
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || utf8.RuneCountInString(t) > MaxTagLength {
+			return nil, ErrInvalid
+		}
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// billingPeriod validates a billing period in months; zero is monthly.
+func billingPeriod(months int) (int, error) {
+	if months == 0 {
+		return 1, nil
+	}
+	if !slices.Contains(model.BillingPeriods, months) {
+		return 0, ErrInvalid
+	}
+	return months, nil
+}
+
+// normalizeCostCenter trims c; a blank cost center is none.
+func normalizeCostCenter(c *string) (*string, error) {
+	if c == nil {
+		return nil, nil
+	}
+	v := strings.TrimSpace(*c)
+	if v == "" {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(v) > MaxCostCenterLength {
+		return nil, ErrInvalid
+	}
+	return &v, nil
+}
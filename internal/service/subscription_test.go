@@ -2,9 +2,11 @@ package service_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"subscription-service/internal/auth"
 	"subscription-service/internal/model"
 	"subscription-service/internal/repository"
 	"subscription-service/internal/service"
@@ -12,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockRepo struct {
@@ -48,13 +51,72 @@ func (m *mockRepo) TotalCostForPeriod(ctx context.Context, from, to time.Time, u
 	args := m.Called(ctx, from, to, userID, serviceName)
 	return args.Get(0).(int64), args.Error(1)
 }
+func (m *mockRepo) FindOverlapping(ctx context.Context, s *model.Subscription) ([]*model.Subscription, error) {
+	args := m.Called(ctx, s)
+	if subs, ok := args.Get(0).([]*model.Subscription); ok {
+		return subs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) SetMembers(ctx context.Context, id string, members []model.Member) error {
+	return m.Called(ctx, id, members).Error(0)
+}
+
+func (m *mockRepo) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	args := m.Called(ctx, id)
+	if members, ok := args.Get(0).([]model.Member); ok {
+		return members, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) SetTags(ctx context.Context, id string, tags []string) error {
+	return m.Called(ctx, id, tags).Error(0)
+}
+
+func (m *mockRepo) TagSubscriptions(ctx context.Context, ids, add, remove []string) error {
+	return m.Called(ctx, ids, add, remove).Error(0)
+}
+
+func (m *mockRepo) ListTags(ctx context.Context, userID *string) ([]model.Tag, error) {
+	args := m.Called(ctx, userID)
+	if tags, ok := args.Get(0).([]model.Tag); ok {
+		return tags, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) DeleteTag(ctx context.Context, name string) error {
+	return m.Called(ctx, name).Error(0)
+}
+
+func (m *mockRepo) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) error {
+	return m.Called(ctx, id, prices).Error(0)
+}
+
+func (m *mockRepo) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	args := m.Called(ctx, id)
+	if prices, ok := args.Get(0).([]model.ScheduledPrice); ok {
+		return prices, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func asUser(userID string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: userID, Role: auth.RoleUser})
+}
+
+func asRole(role string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: uuid.New().String(), Role: role})
+}
 
 func TestCreateSubscription_Success(t *testing.T) {
 	repo := new(mockRepo)
 	svc := service.NewSubscriptionService(repo)
-	ctx := context.Background()
 
 	userID := uuid.New().String()
+	ctx := asUser(userID)
 	start := time.Now().UTC()
 
 	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return(nil)
@@ -76,6 +138,29 @@ func TestCreateSubscription_Success(t *testing.T) {
 	repo.AssertCalled(t, "Create", mock.Anything, mock.AnythingOfType("*model.Subscription"))
 }
 
+func TestCreateSubscription_CostCenter(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return(nil)
+
+	userID := uuid.New().String()
+	in := service.CreateInput{ServiceName: "Netflix", Price: 499, UserID: userID, StartDate: time.Now()}
+	for center, want := range map[string]*string{
+		" growth ": func() *string { s := "growth"; return &s }(),
+		"  ":       nil,
+	} {
+		in.CostCenter = &center
+		sub, err := svc.CreateSubscription(asUser(userID), in)
+		require.NoError(t, err)
+		assert.Equal(t, want, sub.CostCenter, center)
+	}
+
+	long := strings.Repeat("x", service.MaxCostCenterLength+1)
+	in.CostCenter = &long
+	_, err := svc.CreateSubscription(asUser(userID), in)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
 func TestCreateSubscription_InvalidUserID(t *testing.T) {
 	repo := new(mockRepo)
 	svc := service.NewSubscriptionService(repo)
@@ -86,7 +171,7 @@ func TestCreateSubscription_InvalidUserID(t *testing.T) {
 		UserID:      "invalid-uuid",
 		StartDate:   time.Now(),
 	}
-	_, err := svc.CreateSubscription(context.Background(), in)
+	_, err := svc.CreateSubscription(asRole(auth.RoleAdmin), in)
 	assert.ErrorIs(t, err, service.ErrInvalid)
 }
 
@@ -105,7 +190,7 @@ func TestCreateSubscription_EndBeforeStart(t *testing.T) {
 		EndDate:     &end,
 	}
 
-	_, err := svc.CreateSubscription(context.Background(), in)
+	_, err := svc.CreateSubscription(asRole(auth.RoleAdmin), in)
 	assert.ErrorIs(t, err, service.ErrInvalid)
 }
 
@@ -133,13 +218,55 @@ func TestUpdateSubscription_Success(t *testing.T) {
 		EndDate:     &newEnd,
 	}
 
-	out, err := svc.UpdateSubscription(context.Background(), existing.ID, in)
+	out, err := svc.UpdateSubscription(asUser(existing.UserID), existing.ID, in)
 	assert.NoError(t, err)
 	assert.Equal(t, "Netflix Premium", out.ServiceName)
 	assert.Equal(t, 799, out.Price)
 	repo.AssertCalled(t, "Update", mock.Anything, mock.AnythingOfType("*model.Subscription"))
 }
 
+func TestUpdateSubscription_KeepsOmittedFields(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	marketing := "marketing"
+	existing := &model.Subscription{
+		ID:            uuid.New().String(),
+		ServiceName:   "Netflix",
+		Price:         5988,
+		UserID:        uuid.New().String(),
+		StartDate:     time.Now(),
+		CostCenter:    &marketing,
+		BillingPeriod: 12,
+	}
+	repo.On("GetByID", mock.Anything, existing.ID).Return(existing, nil)
+	repo.On("Update", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return(nil)
+
+	out, err := svc.UpdateSubscription(asUser(existing.UserID), existing.ID, service.UpdateInput{
+		ServiceName: "Netflix",
+		Price:       7188,
+		UserID:      existing.UserID,
+		StartDate:   existing.StartDate,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 12, out.BillingPeriod)
+	require.NotNil(t, out.CostCenter)
+	assert.Equal(t, "marketing", *out.CostCenter)
+
+	blank := " "
+	out, err = svc.UpdateSubscription(asUser(existing.UserID), existing.ID, service.UpdateInput{
+		ServiceName:   "Netflix",
+		Price:         599,
+		UserID:        existing.UserID,
+		StartDate:     existing.StartDate,
+		CostCenter:    &blank,
+		BillingPeriod: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, out.BillingPeriod)
+	assert.Nil(t, out.CostCenter)
+}
+
 func TestSumForPeriod_InvalidDates(t *testing.T) {
 	repo := new(mockRepo)
 	svc := service.NewSubscriptionService(repo)
@@ -147,7 +274,7 @@ func TestSumForPeriod_InvalidDates(t *testing.T) {
 	from := time.Now()
 	to := from.AddDate(0, 0, -1)
 
-	_, err := svc.SumForPeriod(context.Background(), from, to, nil, nil)
+	_, err := svc.SumForPeriod(asRole(auth.RoleFinance), from, to, nil, nil)
 	assert.ErrorIs(t, err, service.ErrInvalid)
 }
 
@@ -160,7 +287,361 @@ func TestSumForPeriod_Success(t *testing.T) {
 
 	repo.On("TotalCostForPeriod", mock.Anything, from, to, (*string)(nil), (*string)(nil)).Return(int64(999), nil)
 
-	total, err := svc.SumForPeriod(context.Background(), from, to, nil, nil)
+	total, err := svc.SumForPeriod(asRole(auth.RoleFinance), from, to, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(999), total)
 }
+
+func TestCreateSubscription_ForOtherUserForbidden(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	in := service.CreateInput{
+		ServiceName: "Netflix",
+		Price:       499,
+		UserID:      uuid.New().String(),
+		StartDate:   time.Now(),
+	}
+
+	_, err := svc.CreateSubscription(asUser(uuid.New().String()), in)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = svc.CreateSubscription(asRole(auth.RoleFinance), in)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = svc.CreateSubscription(context.Background(), in)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOwnerOnlyAccess(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	sub := &model.Subscription{
+		ID:          uuid.New().String(),
+		ServiceName: "Netflix",
+		Price:       499,
+		UserID:      uuid.New().String(),
+		StartDate:   time.Now(),
+	}
+	repo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	repo.On("Delete", mock.Anything, sub.ID).Return(nil)
+
+	stranger := asUser(uuid.New().String())
+	_, err := svc.GetByID(stranger, sub.ID)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = svc.UpdateSubscription(stranger, sub.ID, service.UpdateInput{
+		ServiceName: "Netflix",
+		UserID:      sub.UserID,
+		StartDate:   sub.StartDate,
+	})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = svc.DeleteSubscription(stranger, sub.ID)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+	got, err := svc.GetByID(asUser(sub.UserID), sub.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, sub.ID, got.ID)
+	deleted, err := svc.DeleteSubscription(asRole(auth.RoleAdmin), sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.UserID, deleted.UserID)
+}
+
+func TestUpdateSubscription_CannotReassignToOtherUser(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	sub := &model.Subscription{ID: uuid.New().String(), UserID: uuid.New().String(), StartDate: time.Now()}
+	repo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+
+	_, err := svc.UpdateSubscription(asUser(sub.UserID), sub.ID, service.UpdateInput{
+		ServiceName: "Netflix",
+		UserID:      uuid.New().String(),
+		StartDate:   sub.StartDate,
+	})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestListSubscriptions_UserSeesOnlyOwn(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	userID := uuid.New().String()
+	other := uuid.New().String()
+	repo.On("List", mock.Anything, repository.ListFilter{UserID: &userID, Limit: 50}).Return([]*model.Subscription{}, nil)
+	repo.On("List", mock.Anything, repository.ListFilter{Limit: 50}).Return([]*model.Subscription{}, nil)
+
+	_, err := svc.ListSubscriptions(asUser(userID), repository.ListFilter{Limit: 50})
+	assert.NoError(t, err)
+	_, err = svc.ListSubscriptions(asUser(userID), repository.ListFilter{UserID: &other, Limit: 50})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = svc.ListSubscriptions(asRole(auth.RoleAdmin), repository.ListFilter{Limit: 50})
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestSumForPeriod_Aggregates(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	from := time.Now().AddDate(0, -1, 0)
+	to := time.Now()
+	userID := uuid.New().String()
+	other := uuid.New().String()
+
+	repo.On("TotalCostForPeriod", mock.Anything, from, to, &userID, (*string)(nil)).Return(int64(100), nil)
+	repo.On("TotalCostForPeriod", mock.Anything, from, to, &other, (*string)(nil)).Return(int64(200), nil)
+
+	total, err := svc.SumForPeriod(asUser(userID), from, to, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), total)
+
+	_, err = svc.SumForPeriod(asUser(userID), from, to, &other, nil)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	total, err = svc.SumForPeriod(asRole(auth.RoleFinance), from, to, &other, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), total)
+}
+
+func TestCreateSubscription_DuplicatePolicy(t *testing.T) {
+	userID := uuid.New().String()
+	in := service.CreateInput{ServiceName: "Netflix", Price: 499, UserID: userID, StartDate: time.Now()}
+	existing := &model.Subscription{ID: uuid.New().String(), ServiceName: "netflix", UserID: userID}
+
+	repo := new(mockRepo)
+	repo.On("FindOverlapping", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return([]*model.Subscription{existing}, nil)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return(nil)
+
+	sub, err := service.NewSubscriptionService(repo, service.WithDuplicatePolicy(service.DuplicatesWarn)).CreateSubscription(asUser(userID), in)
+	require.NoError(t, err)
+	require.Len(t, sub.Warnings, 1)
+	assert.Contains(t, sub.Warnings[0], existing.ID)
+
+	_, err = service.NewSubscriptionService(repo, service.WithDuplicatePolicy(service.DuplicatesReject)).CreateSubscription(asUser(userID), in)
+	assert.ErrorIs(t, err, service.ErrOverlap)
+	repo.AssertNumberOfCalls(t, "Create", 1)
+
+	sub, err = service.NewSubscriptionService(repo).CreateSubscription(asUser(userID), in)
+	require.NoError(t, err)
+	assert.Empty(t, sub.Warnings)
+	repo.AssertNumberOfCalls(t, "FindOverlapping", 2)
+}
+
+func TestSetMembers(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	owner, partner := uuid.New().String(), uuid.New().String()
+	sub := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 1000, UserID: owner}
+	repo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	repo.On("SetMembers", mock.Anything, sub.ID, mock.Anything).Return(nil)
+
+	pct := func(v float64) *float64 { return &v }
+	amount := func(v int) *int { return &v }
+	for name, tc := range map[string]struct {
+		members []model.Member
+		want    error
+	}{
+		"none":              {nil, nil},
+		"halves":            {[]model.Member{{UserID: owner, SharePercent: pct(50)}, {UserID: partner, SharePercent: pct(50)}}, nil},
+		"fixed and rest":    {[]model.Member{{UserID: owner, SharePercent: pct(100)}, {UserID: partner, Amount: amount(300)}}, nil},
+		"fixed only":        {[]model.Member{{UserID: owner, Amount: amount(700)}, {UserID: partner, Amount: amount(300)}}, nil},
+		"short of 100":      {[]model.Member{{UserID: owner, SharePercent: pct(33.33)}, {UserID: partner, SharePercent: pct(33.33)}}, service.ErrShares},
+		"fixed over price":  {[]model.Member{{UserID: owner, SharePercent: pct(100)}, {UserID: partner, Amount: amount(1001)}}, service.ErrShares},
+		"fixed under price": {[]model.Member{{UserID: owner, Amount: amount(300)}}, service.ErrShares},
+		"duplicate user":    {[]model.Member{{UserID: owner, SharePercent: pct(50)}, {UserID: owner, SharePercent: pct(50)}}, service.ErrShares},
+		"both kinds":        {[]model.Member{{UserID: owner, SharePercent: pct(100), Amount: amount(1)}}, service.ErrInvalid},
+		"bad user":          {[]model.Member{{UserID: "x", SharePercent: pct(100)}}, service.ErrInvalid},
+	} {
+		_, err := svc.SetMembers(asRole(auth.RoleAdmin), sub.ID, tc.members)
+		assert.ErrorIs(t, err, tc.want, name)
+	}
+	repo.AssertNumberOfCalls(t, "SetMembers", 4)
+
+	_, err := svc.SetMembers(asUser(partner), sub.ID, nil)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+}
+
+func TestUpdateSubscription_PriceBelowFixedShares(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	userID := uuid.New().String()
+	existing := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 1000, UserID: userID, StartDate: time.Now()}
+	repo.On("GetByID", mock.Anything, existing.ID).Return(existing, nil)
+	// The members are checked in the update's transaction.
+	repo.On("Update", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return(repository.ErrShares)
+
+	_, err := svc.UpdateSubscription(asUser(userID), existing.ID, service.UpdateInput{
+		ServiceName: "Netflix", Price: 500, UserID: userID, StartDate: existing.StartDate,
+	})
+	assert.ErrorIs(t, err, service.ErrShares)
+}
+
+func TestSetMembers_OtherUsersNeedFinance(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	owner, partner := uuid.New().String(), uuid.New().String()
+	sub := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 1000, UserID: owner}
+	repo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	repo.On("SetMembers", mock.Anything, sub.ID, mock.Anything).Return(nil)
+
+	pct := func(v float64) *float64 { return &v }
+	amount := func(v int) *int { return &v }
+	// The partner could not even read the subscription they would pay for.
+	for name, members := range map[string][]model.Member{
+		"partner pays all": {{UserID: partner, Amount: amount(1000)}},
+		"small share":      {{UserID: owner, SharePercent: pct(99.99)}, {UserID: partner, SharePercent: pct(0.01)}},
+	} {
+		_, err := svc.SetMembers(asUser(owner), sub.ID, members)
+		assert.ErrorIs(t, err, service.ErrForbidden, name)
+	}
+	repo.AssertNotCalled(t, "SetMembers", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := svc.SetMembers(asUser(owner), sub.ID, []model.Member{{UserID: owner, SharePercent: pct(100)}})
+	require.NoError(t, err)
+	_, err = svc.SetMembers(asUser(owner), sub.ID, nil)
+	require.NoError(t, err)
+
+	// Admins may assign the cost to anyone.
+	_, err = svc.SetMembers(asRole(auth.RoleAdmin), sub.ID, []model.Member{{UserID: partner, Amount: amount(1000)}})
+	require.NoError(t, err)
+}
+
+func TestSetPriceSchedule(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	owner := uuid.New().String()
+	now := time.Now().UTC()
+	month := func(offset int) time.Time {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, offset, 0)
+	}
+	end := month(6)
+	sub := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 1000, UserID: owner, EndDate: &end}
+	repo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	repo.On("SetPriceSchedule", mock.Anything, sub.ID, mock.Anything).Return(nil)
+
+	got, err := svc.SetPriceSchedule(asUser(owner), sub.ID, []model.ScheduledPrice{
+		{Price: 1200, EffectiveFrom: month(3)},
+		{Price: 1100, EffectiveFrom: month(1)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []model.ScheduledPrice{{Price: 1100, EffectiveFrom: month(1)}, {Price: 1200, EffectiveFrom: month(3)}}, got)
+
+	for name, prices := range map[string][]model.ScheduledPrice{
+		"this month":     {{Price: 1100, EffectiveFrom: month(0)}},
+		"mid month":      {{Price: 1100, EffectiveFrom: month(1).AddDate(0, 0, 14)}},
+		"after end_date": {{Price: 1100, EffectiveFrom: month(7)}},
+		"negative":       {{Price: -1, EffectiveFrom: month(1)}},
+		"same month":     {{Price: 1100, EffectiveFrom: month(2)}, {Price: 1200, EffectiveFrom: month(2)}},
+	} {
+		_, err := svc.SetPriceSchedule(asUser(owner), sub.ID, prices)
+		assert.ErrorIs(t, err, service.ErrInvalid, name)
+	}
+	repo.AssertNumberOfCalls(t, "SetPriceSchedule", 1)
+
+	_, err = svc.SetPriceSchedule(asUser(uuid.New().String()), sub.ID, nil)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+}
+
+func TestCreateSubscription_BillingPeriod(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Subscription")).Return(nil)
+
+	userID := uuid.New().String()
+	in := service.CreateInput{ServiceName: "Netflix", Price: 5988, UserID: userID, StartDate: time.Now().UTC(), BillingPeriod: 12}
+	sub, err := svc.CreateSubscription(asUser(userID), in)
+	require.NoError(t, err)
+	assert.Equal(t, 12, sub.BillingPeriod)
+
+	in.BillingPeriod = 0
+	sub, err = svc.CreateSubscription(asUser(userID), in)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sub.BillingPeriod, "monthly by default")
+
+	in.BillingPeriod = 2
+	_, err = svc.CreateSubscription(asUser(userID), in)
+	assert.ErrorIs(t, err, service.ErrInvalid)
+}
+
+func TestSetTags(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	owner := uuid.New().String()
+	sub := &model.Subscription{ID: uuid.New().String(), ServiceName: "Netflix", Price: 1000, UserID: owner}
+	repo.On("GetByID", mock.Anything, sub.ID).Return(sub, nil)
+	repo.On("SetTags", mock.Anything, sub.ID, []string{"marketing", "q3"}).Return(nil)
+
+	got, err := svc.SetTags(asUser(owner), sub.ID, []string{" Marketing", "q3", "MARKETING"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"marketing", "q3"}, got)
+
+	_, err = svc.SetTags(asUser(owner), sub.ID, []string{""})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+	_, err = svc.SetTags(asUser(owner), sub.ID, []string{strings.Repeat("x", service.MaxTagLength+1)})
+	assert.ErrorIs(t, err, service.ErrInvalid)
+
+	_, err = svc.SetTags(asUser(uuid.New().String()), sub.ID, []string{"marketing"})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	// Finance allocates spend of everyone's subscriptions.
+	_, err = svc.SetTags(asRole(auth.RoleFinance), sub.ID, []string{"marketing", "q3"})
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "SetTags", 2)
+}
+
+func TestTagSubscriptions(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	a, b := uuid.New().String(), uuid.New().String()
+	repo.On("TagSubscriptions", mock.Anything, []string{a, b}, []string{"marketing"}, []string{}).Return(nil)
+
+	err := svc.TagSubscriptions(asRole(auth.RoleFinance), service.TagInput{SubscriptionIDs: []string{a, b, a}, Add: []string{"Marketing"}})
+	require.NoError(t, err)
+	repo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+
+	for name, in := range map[string]service.TagInput{
+		"no subscriptions": {Add: []string{"marketing"}},
+		"bad id":           {SubscriptionIDs: []string{"x"}, Add: []string{"marketing"}},
+		"no tags":          {SubscriptionIDs: []string{a}},
+		"add and remove":   {SubscriptionIDs: []string{a}, Add: []string{"marketing"}, Remove: []string{"Marketing"}},
+	} {
+		assert.ErrorIs(t, svc.TagSubscriptions(asRole(auth.RoleFinance), in), service.ErrInvalid, name)
+	}
+
+	owner := uuid.New().String()
+	repo.On("GetByID", mock.Anything, a).Return(&model.Subscription{ID: a, UserID: owner}, nil)
+	repo.On("GetByID", mock.Anything, b).Return(&model.Subscription{ID: b, UserID: uuid.New().String()}, nil)
+	err = svc.TagSubscriptions(asUser(owner), service.TagInput{SubscriptionIDs: []string{a, b}, Add: []string{"marketing"}})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	repo.AssertNumberOfCalls(t, "TagSubscriptions", 1)
+}
+
+func TestListTags_ScopedToCaller(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+
+	userID := uuid.New().String()
+	repo.On("ListTags", mock.Anything, &userID).Return(nil, nil)
+
+	got, err := svc.ListTags(asUser(userID))
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+}
+
+func TestDeleteTag(t *testing.T) {
+	repo := new(mockRepo)
+	svc := service.NewSubscriptionService(repo)
+	repo.On("DeleteTag", mock.Anything, "marketing").Return(nil)
+
+	assert.ErrorIs(t, svc.DeleteTag(asUser(uuid.New().String()), "marketing"), service.ErrForbidden)
+	assert.NoError(t, svc.DeleteTag(asRole(auth.RoleFinance), " Marketing "))
+	repo.AssertNumberOfCalls(t, "DeleteTag", 1)
+}
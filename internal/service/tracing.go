@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "subscription-service/internal/service"
+
+type tracedService struct {
+	next SubscriptionService
+}
+
+func NewTracedService(next SubscriptionService) SubscriptionService {
+	return &tracedService{next: next}
+}
+
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, "SubscriptionService."+method, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *tracedService) CreateSubscription(ctx context.Context, in CreateInput) (*model.Subscription, error) {
+	ctx, span := startSpan(ctx, "CreateSubscription", attribute.String("subscription.service_name", in.ServiceName))
+	sub, err := t.next.CreateSubscription(ctx, in)
+	if sub != nil {
+		span.SetAttributes(attribute.String("subscription.id", sub.ID))
+	}
+	endSpan(span, err)
+	return sub, err
+}
+
+func (t *tracedService) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	ctx, span := startSpan(ctx, "GetByID", attribute.String("subscription.id", id))
+	sub, err := t.next.GetByID(ctx, id)
+	endSpan(span, err)
+	return sub, err
+}
+
+func (t *tracedService) UpdateSubscription(ctx context.Context, id string, in UpdateInput) (*model.Subscription, error) {
+	ctx, span := startSpan(ctx, "UpdateSubscription", attribute.String("subscription.id", id))
+	sub, err := t.next.UpdateSubscription(ctx, id, in)
+	endSpan(span, err)
+	return sub, err
+}
+
+func (t *tracedService) DeleteSubscription(ctx context.Context, id string) (*model.Subscription, error) {
+	ctx, span := startSpan(ctx, "DeleteSubscription", attribute.String("subscription.id", id))
+	sub, err := t.next.DeleteSubscription(ctx, id)
+	endSpan(span, err)
+	return sub, err
+}
+
+func (t *tracedService) ListSubscriptions(ctx context.Context, filter repository.ListFilter) ([]*model.Subscription, error) {
+	ctx, span := startSpan(ctx, "ListSubscriptions",
+		attribute.Int("list.limit", filter.Limit),
+		attribute.Int("list.offset", filter.Offset),
+	)
+	subs, err := t.next.ListSubscriptions(ctx, filter)
+	span.SetAttributes(attribute.Int("list.count", len(subs)))
+	endSpan(span, err)
+	return subs, err
+}
+
+func (t *tracedService) SumForPeriod(ctx context.Context, from, to time.Time, userID, serviceName *string) (int64, error) {
+	ctx, span := startSpan(ctx, "SumForPeriod",
+		attribute.String("period.from", from.Format("2006-01-02")),
+		attribute.String("period.to", to.Format("2006-01-02")),
+	)
+	total, err := t.next.SumForPeriod(ctx, from, to, userID, serviceName)
+	endSpan(span, err)
+	return total, err
+}
+
+func (t *tracedService) SetMembers(ctx context.Context, id string, members []model.Member) ([]model.Member, error) {
+	ctx, span := startSpan(ctx, "SetMembers",
+		attribute.String("subscription.id", id),
+		attribute.Int("members.count", len(members)),
+	)
+	out, err := t.next.SetMembers(ctx, id, members)
+	endSpan(span, err)
+	return out, err
+}
+
+func (t *tracedService) ListMembers(ctx context.Context, id string) ([]model.Member, error) {
+	ctx, span := startSpan(ctx, "ListMembers", attribute.String("subscription.id", id))
+	out, err := t.next.ListMembers(ctx, id)
+	endSpan(span, err)
+	return out, err
+}
+
+func (t *tracedService) SetTags(ctx context.Context, id string, tags []string) ([]string, error) {
+	ctx, span := startSpan(ctx, "SetTags",
+		attribute.String("subscription.id", id),
+		attribute.Int("tags.count", len(tags)),
+	)
+	out, err := t.next.SetTags(ctx, id, tags)
+	endSpan(span, err)
+	return out, err
+}
+
+func (t *tracedService) TagSubscriptions(ctx context.Context, in TagInput) error {
+	ctx, span := startSpan(ctx, "TagSubscriptions",
+		attribute.Int("subscriptions.count", len(in.SubscriptionIDs)),
+		attribute.StringSlice("tags.add", in.Add),
+		attribute.StringSlice("tags.remove", in.Remove),
+	)
+	err := t.next.TagSubscriptions(ctx, in)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedService) ListTags(ctx context.Context) ([]model.Tag, error) {
+	ctx, span := startSpan(ctx, "ListTags")
+	out, err := t.next.ListTags(ctx)
+	span.SetAttributes(attribute.Int("list.count", len(out)))
+	endSpan(span, err)
+	return out, err
+}
+
+func (t *tracedService) DeleteTag(ctx context.Context, name string) error {
+	ctx, span := startSpan(ctx, "DeleteTag", attribute.String("tag.name", name))
+	err := t.next.DeleteTag(ctx, name)
+	endSpan(span, err)
+	return err
+}
+
+func (t *tracedService) SetPriceSchedule(ctx context.Context, id string, prices []model.ScheduledPrice) ([]model.ScheduledPrice, error) {
+	ctx, span := startSpan(ctx, "SetPriceSchedule",
+		attribute.String("subscription.id", id),
+		attribute.Int("prices.count", len(prices)),
+	)
+	out, err := t.next.SetPriceSchedule(ctx, id, prices)
+	endSpan(span, err)
+	return out, err
+}
+
+func (t *tracedService) ListPriceSchedule(ctx context.Context, id string) ([]model.ScheduledPrice, error) {
+	ctx, span := startSpan(ctx, "ListPriceSchedule", attribute.String("subscription.id", id))
+	out, err := t.next.ListPriceSchedule(ctx, id)
+	endSpan(span, err)
+	return out, err
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/webhook"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type WebhookService interface {
+	// CreateWebhook returns the webhook and its signing secret, which is
+	// only shown once.
+	CreateWebhook(ctx context.Context, in CreateWebhookInput) (*model.Webhook, string, error)
+	ListDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error)
+}
+
+type CreateWebhookInput struct {
+	URL        string
+	EventTypes []string
+}
+
+// ErrPrivateURL rejects webhook URLs whose host is not a public address.
+var ErrPrivateURL = webhook.ErrPrivateAddress
+
+type webhookService struct {
+	repo         repository.WebhookRepo
+	resolver     webhook.Resolver
+	allowPrivate bool
+}
+
+type WebhookOption func(*webhookService)
+
+// WithResolver sets how webhook hosts are resolved for the address check;
+// net.DefaultResolver by default.
+func WithResolver(r webhook.Resolver) WebhookOption {
+	return func(s *webhookService) {
+		s.resolver = r
+	}
+}
+
+// WithPrivateWebhookURLs accepts URLs on loopback and private addresses,
+// for local development only.
+func WithPrivateWebhookURLs(allow bool) WebhookOption {
+	return func(s *webhookService) {
+		s.allowPrivate = allow
+	}
+}
+
+func NewWebhookService(r repository.WebhookRepo, opts ...WebhookOption) WebhookService {
+	s := &webhookService{repo: r, resolver: net.DefaultResolver}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *webhookService) CreateWebhook(ctx context.Context, in CreateWebhookInput) (*model.Webhook, string, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if !canManageAll(p) {
+		return nil, "", ErrForbidden
+	}
+
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", ErrInvalid
+	}
+	if len(in.EventTypes) == 0 {
+		return nil, "", ErrInvalid
+	}
+	types := slices.Clone(in.EventTypes)
+	slices.Sort(types)
+	types = slices.Compact(types)
+	for _, t := range types {
+		if !slices.Contains(model.WebhookEventTypes, t) {
+			return nil, "", ErrInvalid
+		}
+	}
+
+	// Checked again on every delivery, DNS may change meanwhile.
+	if !s.allowPrivate {
+		if err := webhook.CheckURL(ctx, s.resolver, u); errors.Is(err, webhook.ErrPrivateAddress) {
+			return nil, "", ErrPrivateURL
+		} else if err != nil {
+			zerolog.Ctx(ctx).Info().Err(err).Msg("webhook host not resolved")
+			return nil, "", ErrInvalid
+		}
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	w := &model.Webhook{
+		ID:         uuid.New().String(),
+		URL:        in.URL,
+		Secret:     secret,
+		EventTypes: types,
+		Active:     true,
+		CreatedBy:  p.Subject,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, w); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("repo.Create webhook failed")
+		return nil, "", err
+	}
+	return w, secret, nil
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error) {
+	p, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageAll(p) {
+		return nil, ErrForbidden
+	}
+	if _, err := s.repo.GetByID(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(ctx, webhookID, limit, offset)
+}
@@ -0,0 +1,121 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"subscription-service/internal/auth"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWebhookRepo struct {
+	mock.Mock
+}
+
+func (m *mockWebhookRepo) Create(ctx context.Context, w *model.Webhook) error {
+	return m.Called(ctx, w).Error(0)
+}
+func (m *mockWebhookRepo) GetByID(ctx context.Context, id string) (*model.Webhook, error) {
+	args := m.Called(ctx, id)
+	if w, ok := args.Get(0).(*model.Webhook); ok {
+		return w, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+func (m *mockWebhookRepo) ListDeliveries(ctx context.Context, webhookID string, limit, offset int) ([]*model.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit, offset)
+	return args.Get(0).([]*model.WebhookDelivery), args.Error(1)
+}
+func (m *mockWebhookRepo) ListSubscribed(ctx context.Context, eventType string) ([]*model.Webhook, error) {
+	args := m.Called(ctx, eventType)
+	return args.Get(0).([]*model.Webhook), args.Error(1)
+}
+func (m *mockWebhookRepo) Enqueue(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	return m.Called(ctx, deliveries).Error(0)
+}
+func (m *mockWebhookRepo) ProcessDeliveries(ctx context.Context, limit int, deliver repository.DeliverFunc, policy repository.DeliveryPolicy) (int, error) {
+	args := m.Called(ctx, limit, deliver, policy)
+	return args.Int(0), args.Error(1)
+}
+
+// hosts resolves names from a fixed table.
+type hosts map[string]string
+
+func (h hosts) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	ip, ok := h[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []netip.Addr{netip.MustParseAddr(ip)}, nil
+}
+
+var testHosts = service.WithResolver(hosts{"example.com": "93.184.216.34", "metadata.internal": "169.254.169.254"})
+
+func TestCreateWebhook(t *testing.T) {
+	repo := new(mockWebhookRepo)
+	svc := service.NewWebhookService(repo, testHosts)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Webhook")).Return(nil)
+
+	w, secret, err := svc.CreateWebhook(asRole(auth.RoleAdmin), service.CreateWebhookInput{
+		URL:        "https://example.com/hooks",
+		EventTypes: []string{model.WebhookSubscriptionRenewed, model.WebhookSubscriptionCreated, model.WebhookSubscriptionRenewed},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, secret, w.Secret)
+	assert.True(t, w.Active)
+	assert.Equal(t, []string{"subscription.created", "subscription.renewed"}, w.EventTypes)
+}
+
+func TestCreateWebhook_Rejected(t *testing.T) {
+	svc := service.NewWebhookService(new(mockWebhookRepo), testHosts)
+
+	_, _, err := svc.CreateWebhook(asRole(auth.RoleUser), service.CreateWebhookInput{
+		URL: "https://example.com", EventTypes: []string{model.WebhookSubscriptionCreated},
+	})
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	for _, in := range []service.CreateWebhookInput{
+		{URL: "ftp://example.com", EventTypes: []string{model.WebhookSubscriptionCreated}},
+		{URL: "https://example.com"},
+		{URL: "https://example.com", EventTypes: []string{"subscription.exploded"}},
+		{URL: "https://unknown.example", EventTypes: []string{model.WebhookSubscriptionCreated}},
+	} {
+		_, _, err := svc.CreateWebhook(asRole(auth.RoleAdmin), in)
+		assert.ErrorIs(t, err, service.ErrInvalid, in)
+	}
+
+	for _, u := range []string{"http://127.0.0.1:8080/hooks", "http://10.0.0.5/", "http://169.254.169.254/latest/meta-data", "http://[::1]/", "https://metadata.internal/"} {
+		_, _, err := svc.CreateWebhook(asRole(auth.RoleAdmin), service.CreateWebhookInput{URL: u, EventTypes: []string{model.WebhookSubscriptionCreated}})
+		assert.ErrorIs(t, err, service.ErrPrivateURL, u)
+	}
+}
+
+func TestCreateWebhook_PrivateAllowed(t *testing.T) {
+	repo := new(mockWebhookRepo)
+	svc := service.NewWebhookService(repo, testHosts, service.WithPrivateWebhookURLs(true))
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.Webhook")).Return(nil)
+
+	_, _, err := svc.CreateWebhook(asRole(auth.RoleAdmin), service.CreateWebhookInput{
+		URL: "http://127.0.0.1:8080/hooks", EventTypes: []string{model.WebhookSubscriptionCreated},
+	})
+	assert.NoError(t, err)
+}
+
+func TestListDeliveries_UnknownWebhook(t *testing.T) {
+	repo := new(mockWebhookRepo)
+	svc := service.NewWebhookService(repo)
+	repo.On("GetByID", mock.Anything, "w1").Return(nil, repository.ErrNotFound)
+
+	_, err := svc.ListDeliveries(asRole(auth.RoleAdmin), "w1", 50, 0)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	repo.AssertNotCalled(t, "ListDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,22 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrMissing = errors.New("tenant not set")
+
+type ctxKey struct{}
+
+func WithOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, orgID)
+}
+
+func OrgID(ctx context.Context) (string, error) {
+	orgID, ok := ctx.Value(ctxKey{}).(string)
+	if !ok || orgID == "" {
+		return "", ErrMissing
+	}
+	return orgID, nil
+}
@@ -0,0 +1,69 @@
+package webhook_test
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"subscription-service/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":          true,
+		"2606:2800:220:1::":      true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false, // cloud metadata
+		"100.100.100.200":        false, // cloud metadata in carrier-grade NAT space
+		"0.0.0.0":                false,
+		"::1":                    false,
+		"fe80::1":                false,
+		"fd00:ec2::254":          false, // cloud metadata over IPv6
+		"::ffff:127.0.0.1":       false,
+		"64:ff9b::a9fe:a9fe":     false,
+		"224.0.0.1":              false,
+		"::ffff:93.184.216.34":   true,
+		"100.63.255.255":         true,
+		"::":                     false,
+		"172.32.0.1":             true,
+		"::ffff:169.254.169.254": false,
+	} {
+		assert.Equal(t, want, webhook.IsPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+type staticResolver map[string][]netip.Addr
+
+func (r staticResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	return r[host], nil
+}
+
+func TestCheckURL(t *testing.T) {
+	r := staticResolver{
+		"hooks.example.com": {netip.MustParseAddr("93.184.216.34")},
+		"internal.example":  {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.5")},
+	}
+	for raw, wantErr := range map[string]bool{
+		"https://hooks.example.com/x":      false,
+		"https://93.184.216.34/x":          false,
+		"https://internal.example/x":       true,
+		"http://127.0.0.1:8080/x":          true,
+		"http://169.254.169.254/latest":    true,
+		"http://[::1]/x":                   true,
+		"http://[::ffff:169.254.169.254]/": true,
+	} {
+		u, _ := url.Parse(raw)
+		err := webhook.CheckURL(context.Background(), r, u)
+		if wantErr {
+			assert.ErrorIs(t, err, webhook.ErrPrivateAddress, raw)
+		} else {
+			assert.NoError(t, err, raw)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+
+	"github.com/rs/zerolog"
+)
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	Timeout      time.Duration
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	MaxAttempts  int
+	DisableAfter int
+	// Lease is how long a batch is reserved for this dispatcher while it is
+	// sent; it must outlast sending a whole batch.
+	Lease time.Duration
+	// AllowPrivate lets deliveries reach loopback and private addresses,
+	// for local development only.
+	AllowPrivate bool
+}
+
+// Dispatcher sends pending deliveries, signing each request with the
+// webhook's secret. Unless AllowPrivate is set it only connects to public
+// addresses, checked on every dial so that DNS changes and redirects cannot
+// reach internal services.
+type Dispatcher struct {
+	repo   repository.WebhookRepo
+	client *http.Client
+	cfg    Config
+	now    func() time.Time
+}
+
+func NewDispatcher(repo repository.WebhookRepo, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// No proxy: the dialer must see the receiver's address.
+			Transport: &http.Transport{
+				DialContext:         dialer(cfg.AllowPrivate).DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.Drain(ctx); err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("webhook dispatch failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	policy := repository.DeliveryPolicy{
+		MaxAttempts:  d.cfg.MaxAttempts,
+		DisableAfter: d.cfg.DisableAfter,
+		RetryIn:      d.retryIn,
+		Lease:        d.cfg.Lease,
+	}
+	for {
+		n, err := d.repo.ProcessDeliveries(ctx, d.cfg.BatchSize, d.deliver, policy)
+		if err != nil || n < d.cfg.BatchSize {
+			return err
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, w *model.Webhook, del *model.WebhookDelivery) repository.DeliveryResult {
+	res := d.post(ctx, w, del)
+	metrics.ObserveWebhookAttempt(del.EventType, res.Err)
+	if res.Err != nil {
+		zerolog.Ctx(ctx).Warn().Err(res.Err).
+			Str("webhook_id", w.ID).
+			Str("delivery_id", del.ID).
+			Int("attempt", del.Attempts+1).
+			Msg("webhook delivery failed")
+	}
+	return res
+}
+
+func (d *Dispatcher) post(ctx context.Context, w *model.Webhook, del *model.WebhookDelivery) repository.DeliveryResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return repository.DeliveryResult{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "subscription-service-webhooks")
+	req.Header.Set("X-Webhook-ID", w.ID)
+	req.Header.Set("X-Webhook-Delivery", del.ID)
+	req.Header.Set("X-Webhook-Event", del.EventType)
+	req.Header.Set(SignatureHeader, Sign(w.Secret, d.now(), del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return repository.DeliveryResult{Err: err}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return repository.DeliveryResult{StatusCode: resp.StatusCode, Err: fmt.Errorf("status %d", resp.StatusCode)}
+	}
+	return repository.DeliveryResult{StatusCode: resp.StatusCode}
+}
+
+func (d *Dispatcher) retryIn(attempts int) time.Duration {
+	delay := d.cfg.RetryBase
+	for i := 1; i < attempts && delay < d.cfg.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.RetryMax)
+}
@@ -0,0 +1,152 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+	"subscription-service/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memRepo keeps one webhook and its deliveries and applies DeliveryPolicy the
+// way the SQL implementation does.
+type memRepo struct {
+	hook       model.Webhook
+	deliveries []*model.WebhookDelivery
+	failures   int
+}
+
+func (m *memRepo) Create(context.Context, *model.Webhook) error { return nil }
+func (m *memRepo) GetByID(context.Context, string) (*model.Webhook, error) {
+	return &m.hook, nil
+}
+func (m *memRepo) ListDeliveries(context.Context, string, int, int) ([]*model.WebhookDelivery, error) {
+	return m.deliveries, nil
+}
+
+func (m *memRepo) ListSubscribed(ctx context.Context, eventType string) ([]*model.Webhook, error) {
+	orgID, err := tenant.OrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range m.hook.EventTypes {
+		if t == eventType && m.hook.Active && m.hook.OrgID == orgID {
+			return []*model.Webhook{&m.hook}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memRepo) Enqueue(_ context.Context, ds []*model.WebhookDelivery) error {
+	for _, d := range ds {
+		d.Status = model.DeliveryPending
+	}
+	m.deliveries = append(m.deliveries, ds...)
+	return nil
+}
+
+func (m *memRepo) ProcessDeliveries(ctx context.Context, limit int, deliver repository.DeliverFunc, policy repository.DeliveryPolicy) (int, error) {
+	n := 0
+	for _, d := range m.deliveries {
+		if n == limit || !m.hook.Active {
+			break
+		}
+		if d.Status != model.DeliveryPending {
+			continue
+		}
+		res := deliver(ctx, &m.hook, d)
+		d.Attempts++
+		n++
+		if res.Err == nil {
+			d.Status = model.DeliverySucceeded
+			m.failures = 0
+			continue
+		}
+		m.failures++
+		if d.Attempts >= policy.MaxAttempts {
+			d.Status = model.DeliveryFailed
+		}
+		if m.failures >= policy.DisableAfter {
+			m.hook.Active = false
+		}
+	}
+	return n, nil
+}
+
+const orgID = "00000000-0000-0000-0000-00000000000a"
+
+func TestSinkAndDispatcher(t *testing.T) {
+	var gotSig, gotEvent string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(webhook.SignatureHeader)
+		gotEvent = r.Header.Get("X-Webhook-Event")
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	repo := &memRepo{hook: model.Webhook{ID: "w1", OrgID: orgID, URL: srv.URL, Secret: "whsec_test", Active: true,
+		EventTypes: []string{model.WebhookSubscriptionPriceChanged}}}
+
+	prev := &model.Subscription{ID: "s1", Price: 100}
+	cur := &model.Subscription{ID: "s1", Price: 120}
+	e := updateEvent(t, prev, cur)
+	e.OrgID = orgID
+
+	sink := webhook.NewSink(repo)
+	require.NoError(t, sink.Publish(context.Background(), e))
+	require.Len(t, repo.deliveries, 1, "only subscribed types are enqueued")
+
+	d := webhook.NewDispatcher(repo, webhook.Config{BatchSize: 10, Timeout: time.Second, RetryBase: time.Second, RetryMax: time.Minute, MaxAttempts: 3, DisableAfter: 5, AllowPrivate: true})
+	require.NoError(t, d.Drain(context.Background()))
+
+	assert.Equal(t, model.DeliverySucceeded, repo.deliveries[0].Status)
+	assert.Equal(t, "subscription.price_changed", gotEvent)
+	assert.Contains(t, string(gotBody), `"type":"subscription.price_changed"`)
+	assert.True(t, webhook.Verify("whsec_test", gotSig, gotBody, time.Now(), time.Minute))
+}
+
+func TestDispatcher_DisablesFailingWebhook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	repo := &memRepo{hook: model.Webhook{ID: "w1", OrgID: orgID, URL: srv.URL, Secret: "s", Active: true}}
+	for i := 0; i < 3; i++ {
+		repo.deliveries = append(repo.deliveries, &model.WebhookDelivery{ID: string(rune('a' + i)), Status: model.DeliveryPending, Payload: []byte(`{}`)})
+	}
+
+	d := webhook.NewDispatcher(repo, webhook.Config{BatchSize: 10, Timeout: time.Second, RetryBase: time.Second, RetryMax: time.Minute, MaxAttempts: 1, DisableAfter: 2, AllowPrivate: true})
+	require.NoError(t, d.Drain(context.Background()))
+
+	assert.False(t, repo.hook.Active)
+	assert.Equal(t, model.DeliveryFailed, repo.deliveries[0].Status)
+	assert.Equal(t, model.DeliveryPending, repo.deliveries[2].Status, "nothing is sent to a disabled webhook")
+}
+
+func TestDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	repo := &memRepo{hook: model.Webhook{ID: "w1", OrgID: orgID, URL: srv.URL, Secret: "s", Active: true}}
+	repo.deliveries = []*model.WebhookDelivery{{ID: "a", Status: model.DeliveryPending, Payload: []byte(`{}`)}}
+
+	d := webhook.NewDispatcher(repo, webhook.Config{BatchSize: 10, Timeout: time.Second, RetryBase: time.Second, RetryMax: time.Minute, MaxAttempts: 1, DisableAfter: 5})
+	require.NoError(t, d.Drain(context.Background()))
+
+	assert.False(t, called, "loopback is not a public address")
+	assert.Equal(t, model.DeliveryFailed, repo.deliveries[0].Status)
+}
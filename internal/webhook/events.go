@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"encoding/json"
+	"slices"
+
+	"subscription-service/internal/model"
+)
+
+// Derive maps an outbox event to the webhook event types it triggers. An
+// update may at once be a renewal (end date moved later) and a price change.
+func Derive(e *model.Event) ([]string, error) {
+	if e.Type != model.EventSubscriptionUpdated {
+		if slices.Contains(model.WebhookEventTypes, e.Type) {
+			return []string{e.Type}, nil
+		}
+		return nil, nil
+	}
+
+	var change model.SubscriptionChange
+	if err := json.Unmarshal(e.Data, &change); err != nil {
+		return nil, err
+	}
+	types := []string{model.WebhookSubscriptionUpdated}
+	cur, prev := change.Subscription, change.Previous
+	if cur == nil || prev == nil {
+		return types, nil
+	}
+	if cur.Price != prev.Price {
+		types = append(types, model.WebhookSubscriptionPriceChanged)
+	}
+	if cur.EndDate != nil && (prev.EndDate == nil || cur.EndDate.After(*prev.EndDate)) {
+		types = append(types, model.WebhookSubscriptionRenewed)
+	}
+	return types, nil
+}
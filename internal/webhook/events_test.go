@@ -0,0 +1,48 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func updateEvent(t *testing.T, prev, cur *model.Subscription) *model.Event {
+	data, err := json.Marshal(model.SubscriptionChange{Subscription: cur, Previous: prev})
+	require.NoError(t, err)
+	return &model.Event{ID: "e1", Type: model.EventSubscriptionUpdated, OrgID: "org", Data: data}
+}
+
+func TestDerive(t *testing.T) {
+	jan := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)
+
+	types, err := webhook.Derive(&model.Event{Type: model.EventSubscriptionCreated})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subscription.created"}, types)
+
+	types, err = webhook.Derive(&model.Event{Type: model.EventBudgetThresholdCrossed})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"budget.threshold_crossed"}, types)
+
+	types, err = webhook.Derive(updateEvent(t,
+		&model.Subscription{Price: 100, EndDate: &jan},
+		&model.Subscription{Price: 100, EndDate: &jan}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subscription.updated"}, types)
+
+	types, err = webhook.Derive(updateEvent(t,
+		&model.Subscription{Price: 100, EndDate: &jan},
+		&model.Subscription{Price: 150, EndDate: &feb}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subscription.updated", "subscription.price_changed", "subscription.renewed"}, types)
+
+	types, err = webhook.Derive(&model.Event{Type: "something.else"})
+	require.NoError(t, err)
+	assert.Empty(t, types)
+}
@@ -0,0 +1,29 @@
+package webhook_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-service/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, err := webhook.GenerateSecret()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":"e1"}`)
+	header := webhook.Sign(secret, now, body)
+	assert.True(t, strings.HasPrefix(header, "t=1700000000,v1="))
+
+	assert.True(t, webhook.Verify(secret, header, body, now.Add(time.Minute), 5*time.Minute))
+	assert.False(t, webhook.Verify(secret, header, []byte(`{"id":"e2"}`), now, 5*time.Minute), "tampered body")
+	assert.False(t, webhook.Verify("whsec_other", header, body, now, 5*time.Minute), "wrong secret")
+	assert.False(t, webhook.Verify(secret, header, body, now.Add(time.Hour), 5*time.Minute), "replayed")
+	assert.False(t, webhook.Verify(secret, "garbage", body, now, 5*time.Minute))
+}
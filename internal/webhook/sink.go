@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/repository"
+	"subscription-service/internal/tenant"
+
+	"github.com/google/uuid"
+)
+
+// Sink is an outbox sink that turns events into pending deliveries for the
+// organization's subscribed webhooks; the Dispatcher sends them.
+type Sink struct {
+	repo repository.WebhookRepo
+}
+
+func NewSink(repo repository.WebhookRepo) *Sink {
+	return &Sink{repo: repo}
+}
+
+func (s *Sink) Publish(ctx context.Context, e *model.Event) error {
+	types, err := Derive(e)
+	if err != nil {
+		return err
+	}
+	ctx = tenant.WithOrgID(ctx, e.OrgID)
+
+	var deliveries []*model.WebhookDelivery
+	for _, t := range types {
+		hooks, err := s.repo.ListSubscribed(ctx, t)
+		if err != nil {
+			return err
+		}
+		if len(hooks) == 0 {
+			continue
+		}
+		derived := *e
+		derived.Type = t
+		payload, err := json.Marshal(derived)
+		if err != nil {
+			return err
+		}
+		for _, w := range hooks {
+			deliveries = append(deliveries, &model.WebhookDelivery{
+				ID:        uuid.NewString(),
+				WebhookID: w.ID,
+				EventID:   e.ID,
+				EventType: t,
+				Payload:   payload,
+				CreatedAt: time.Now().UTC(),
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return s.repo.Enqueue(ctx, deliveries)
+}